@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"concert-ticket/common/constant"
+	"concert-ticket/common/seating"
+	inboundCron "concert-ticket/inbound/cron"
 	"concert-ticket/inbound/event"
 	"concert-ticket/outbound/sqlgen"
 	"context"
@@ -29,15 +31,25 @@ func runQueueAssignTicketCmd(ctx context.Context) {
 	js := newJs(natsConn)
 	createStreamWorkQueue(ctx, js)
 
+	startCategoryRegistry(ctx, &inboundCron.CategoryCron{
+		Cfg:     cfg,
+		Cache:   cacheClient,
+		Querier: querier,
+	})
+
 	st, err := js.Stream(ctx, constant.QueueStreamName)
 	if err != nil {
 		log.Fatalln("failed to get stream", err)
 	}
 
+	seatAllocators := &seating.Allocators{Cfg: cfg}
+	seatAllocators.Init()
+
 	orderEvent := event.OrderEvent{
 		Db:                   db,
 		Querier:              querier,
 		Publisher:            js,
+		SeatAllocators:       seatAllocators,
 		IdrCurrencyFormatter: message.NewPrinter(language.Indonesian),
 		Timeout:              cfg.GetDuration("queue.order.timeout"),
 	}
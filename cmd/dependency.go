@@ -3,6 +3,7 @@ package cmd
 import (
 	"concert-ticket/common/constant"
 	"concert-ticket/common/otel"
+	inboundCron "concert-ticket/inbound/cron"
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -117,3 +118,14 @@ func createStreamWorkQueue(ctx context.Context, js jetstream.JetStream) jetstrea
 
 	return st
 }
+
+// startCategoryRegistry loads the events and categories before any handler
+// reads them and keeps them refreshed until ctx is done.
+func startCategoryRegistry(ctx context.Context, categoryCron *inboundCron.CategoryCron) {
+	err := categoryCron.Refresh(ctx)
+	if err != nil {
+		log.Fatalln("unable to load categories", err)
+	}
+
+	go categoryCron.Start(ctx)
+}
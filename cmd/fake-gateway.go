@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	paymentOutbound "concert-ticket/outbound/payment"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+func runFakeGatewayCmd(ctx context.Context) {
+	cfg := newCfg("env")
+
+	fakeGateway := &paymentOutbound.FakeGateway{
+		Cfg:     cfg,
+		TimeNow: time.Now,
+	}
+	fakeGateway.Init()
+
+	mux := http.NewServeMux()
+	fakeGateway.Register(mux)
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.GetInt("fake_gateway.port")),
+		Handler:           mux,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      20 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalln("unable to start fake gateway server", err)
+		}
+	}()
+
+	slog.Info("fake gateway server started")
+
+	<-ctx.Done()
+
+	ctxShutDown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctxShutDown); err != nil {
+		log.Fatalln("unable to shutdown fake gateway server", err)
+	}
+
+	slog.Info("fake gateway server stopped")
+}
@@ -3,10 +3,12 @@ package cmd
 import (
 	inboundCron "concert-ticket/inbound/cron"
 	inboundHttp "concert-ticket/inbound/http"
+	paymentOutbound "concert-ticket/outbound/payment"
 	"concert-ticket/outbound/sqlgen"
 	"context"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/oklog/ulid/v2"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 	"log"
@@ -34,6 +36,9 @@ func runHttpServerCmd(ctx context.Context) {
 
 	querier := sqlgen.New(db)
 
+	paymentGateway := &paymentOutbound.HttpPaymentGateway{Cfg: cfg}
+	paymentGateway.Init()
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		slog.DebugContext(r.Context(), "health check")
@@ -43,8 +48,11 @@ func runHttpServerCmd(ctx context.Context) {
 	timeoutMiddleware := inboundHttp.TimeoutMiddleware(20 * time.Second)
 
 	inboundHttp.RegisterCategoryHttp(mux, querier, cacheClient)
-	inboundHttp.RegisterOrderHttp(mux, cfg, querier, cacheClient, js, validate, message.NewPrinter(language.Indonesian))
-	inboundHttp.RegisterPaymentHttp(mux, js, validate)
+	inboundHttp.RegisterEventHttp(mux)
+	inboundHttp.RegisterOrderHttp(mux, cfg, db, querier, cacheClient, js, paymentGateway, validate, message.NewPrinter(language.Indonesian))
+	inboundHttp.RegisterPaymentHttp(mux, cfg, querier, cacheClient, js, validate)
+	inboundHttp.RegisterSeatHttp(mux, cfg, db, querier, cacheClient, validate)
+	inboundHttp.RegisterWaitlistHttp(mux, cacheClient, validate)
 
 	categoryCron := &inboundCron.CategoryCron{
 		Cfg:     cfg,
@@ -52,11 +60,33 @@ func runHttpServerCmd(ctx context.Context) {
 		Querier: querier,
 	}
 
+	orderExpiryCron := &inboundCron.OrderExpiryCron{
+		Cfg:                  cfg,
+		Cache:                cacheClient,
+		Querier:              querier,
+		Publisher:            js,
+		PaymentGateway:       paymentGateway,
+		IdrCurrencyFormatter: message.NewPrinter(language.Indonesian),
+		InstanceId:           ulid.Make().String(),
+		TimeNow:              time.Now,
+	}
+
+	inventoryCron := &inboundCron.InventoryCron{
+		Cfg:        cfg,
+		Cache:      cacheClient,
+		Querier:    querier,
+		Publisher:  js,
+		InstanceId: ulid.Make().String(),
+		TimeNow:    time.Now,
+	}
+
 	err := categoryCron.InitQuantityCache(ctx)
 	if err != nil {
 		log.Fatalln("unable to init category cache", err)
 	}
 
+	startCategoryRegistry(ctx, categoryCron)
+
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.GetInt("server.port")),
 		Handler:           timeoutMiddleware(inboundHttp.CorsMiddleware(mux)),
@@ -76,7 +106,11 @@ func runHttpServerCmd(ctx context.Context) {
 	slog.Info("http server started")
 
 	go func() {
-		categoryCron.Start(ctx)
+		orderExpiryCron.Start(ctx)
+	}()
+
+	go func() {
+		inventoryCron.Start(ctx)
 	}()
 
 	<-ctx.Done()
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	inboundCron "concert-ticket/inbound/cron"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+func runReconcileInventoryCmd(ctx context.Context, repair bool) {
+	cfg := newCfg("env")
+
+	db := newDb(cfg)
+	defer db.Close()
+
+	cacheClient := newRedis(cfg)
+	defer cacheClient.Close()
+
+	natsConn := newNats(cfg)
+	defer natsConn.Close()
+
+	js := newJs(natsConn)
+
+	inventoryCron := inboundCron.InventoryCron{
+		Cfg:       cfg,
+		Cache:     cacheClient,
+		Querier:   sqlgen.New(db),
+		Publisher: js,
+		TimeNow:   time.Now,
+	}
+
+	report, err := inventoryCron.Reconcile(ctx, repair)
+	if err != nil {
+		log.Fatalln("unable to reconcile inventory", err)
+	}
+
+	// Drifted categories seen for the first time are repaired on a second pass
+	// once they have stayed idle.
+	if repair && hasUnrepaired(report) {
+		idle := cfg.GetDuration("cron.inventory.reconcile.idle")
+		log.Printf("waiting %s for drifted categories to stay idle", idle)
+
+		select {
+		case <-time.After(idle):
+		case <-ctx.Done():
+			log.Fatalln("reconcile inventory interrupted")
+		}
+
+		report, err = inventoryCron.Reconcile(ctx, repair)
+		if err != nil {
+			log.Fatalln("unable to reconcile inventory", err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalln("unable to write inventory report", err)
+	}
+}
+
+func hasUnrepaired(report model.InventoryReport) bool {
+	for _, entry := range report.Categories {
+		if (entry.CacheDrift != 0 || entry.DbDrift != 0) && !entry.Repaired {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"concert-ticket/common/constant"
+	inboundCron "concert-ticket/inbound/cron"
 	"concert-ticket/inbound/event"
+	paymentOutbound "concert-ticket/outbound/payment"
 	"concert-ticket/outbound/sqlgen"
 	"context"
 	"github.com/nats-io/nats.go/jetstream"
@@ -10,6 +12,7 @@ import (
 	"golang.org/x/text/message"
 	"log"
 	"log/slog"
+	"time"
 )
 
 func runQueueOrderCmd(ctx context.Context) {
@@ -29,17 +32,29 @@ func runQueueOrderCmd(ctx context.Context) {
 	js := newJs(natsConn)
 	createStreamWorkQueue(ctx, js)
 
+	startCategoryRegistry(ctx, &inboundCron.CategoryCron{
+		Cfg:     cfg,
+		Cache:   cacheClient,
+		Querier: querier,
+	})
+
 	st, err := js.Stream(ctx, constant.QueueStreamName)
 	if err != nil {
 		log.Fatalln("failed to get stream", err)
 	}
 
+	paymentGateway := &paymentOutbound.HttpPaymentGateway{Cfg: cfg}
+	paymentGateway.Init()
+
 	orderEvent := event.OrderEvent{
 		Db:                   db,
 		Querier:              querier,
+		Cache:                cacheClient,
 		Publisher:            js,
+		PaymentGateway:       paymentGateway,
 		IdrCurrencyFormatter: message.NewPrinter(language.Indonesian),
 		Timeout:              cfg.GetDuration("queue.order.timeout"),
+		TimeNow:              time.Now,
 	}
 
 	cons, err := st.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
@@ -79,6 +94,8 @@ func runQueueOrderCmd(ctx context.Context) {
 					eventErr = orderEvent.CreateHandler(ctx, msg.Data())
 				case constant.SubjectCallbackPayment:
 					eventErr = orderEvent.CompleteHandler(ctx, msg.Data())
+				case constant.SubjectRefundPayment:
+					eventErr = orderEvent.RefundHandler(ctx, msg.Data())
 				}
 
 				if eventErr != nil {
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	inboundCron "concert-ticket/inbound/cron"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+)
+
+func runOutboxCmd(ctx context.Context) {
+	cfg := newCfg("env")
+
+	db := newDb(cfg)
+	defer db.Close()
+
+	natsConn := newNats(cfg)
+	defer natsConn.Close()
+
+	js := newJs(natsConn)
+	createStreamWorkQueue(ctx, js)
+
+	outboxCron := inboundCron.OutboxCron{
+		Cfg:       cfg,
+		Db:        db,
+		Querier:   sqlgen.New(db),
+		Publisher: js,
+	}
+
+	outboxCron.Start(ctx)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"concert-ticket/common/otel"
+	inboundCli "concert-ticket/inbound/cli"
 	"context"
 	"fmt"
 	"github.com/spf13/cobra"
@@ -117,17 +118,38 @@ func Start() {
 			},
 		},
 		{
-			Use:   "serve-client",
-			Short: "Run client server",
+			Use:   "serve-queue:waitlist",
+			Short: "Run queue waitlist server",
 			Run: func(cmd *cobra.Command, args []string) {
 				if cfg.GetString("env") == "dev" {
-					cleanup, err := setupProfiling(ctx, "serve-client")
+					cleanup, err := setupProfiling(ctx, "serve-queue-waitlist")
 					if err != nil {
 						log.Fatal(err)
 					}
 					defer cleanup()
 				}
-				runClientCmd(ctx)
+				runQueueWaitlistCmd(ctx)
+			},
+		},
+		{
+			Use:   "serve-outbox",
+			Short: "Run outbox relay server",
+			Run: func(cmd *cobra.Command, args []string) {
+				if cfg.GetString("env") == "dev" {
+					cleanup, err := setupProfiling(ctx, "serve-outbox")
+					if err != nil {
+						log.Fatal(err)
+					}
+					defer cleanup()
+				}
+				runOutboxCmd(ctx)
+			},
+		},
+		{
+			Use:   "serve-fake-gateway",
+			Short: "Run fake payment gateway server, for local development",
+			Run: func(cmd *cobra.Command, args []string) {
+				runFakeGatewayCmd(ctx)
 			},
 		},
 		{
@@ -179,14 +201,28 @@ func Start() {
 				}()
 				go func() {
 					if cfg.GetString("env") == "dev" {
-						cleanup, err := setupProfiling(ctx, "dev-client")
+						cleanup, err := setupProfiling(ctx, "dev-queue-waitlist")
 						if err != nil {
-							log.Printf("Failed to setup profiling for client: %v", err)
+							log.Printf("Failed to setup profiling for waitlist queue: %v", err)
 							return
 						}
 						defer cleanup()
 					}
-					runClientCmd(ctx)
+					runQueueWaitlistCmd(ctx)
+				}()
+				go func() {
+					if cfg.GetString("env") == "dev" {
+						cleanup, err := setupProfiling(ctx, "dev-outbox")
+						if err != nil {
+							log.Printf("Failed to setup profiling for outbox: %v", err)
+							return
+						}
+						defer cleanup()
+					}
+					runOutboxCmd(ctx)
+				}()
+				go func() {
+					runFakeGatewayCmd(ctx)
 				}()
 				//go func() {
 				//	runQueueEmailCmd(ctx)
@@ -195,6 +231,48 @@ func Start() {
 		},
 	}
 
+	var settlementFile, settlementDate, settlementFormat, settlementOutput string
+	var settlementPublishMissing bool
+	reconcileSettlementCmd := &cobra.Command{
+		Use:   "reconcile-settlement",
+		Short: "Reconcile a bank settlement CSV against orders",
+		Run: func(cmd *cobra.Command, args []string) {
+			runReconcileSettlementCmd(ctx, settlementFile, settlementDate, settlementFormat, settlementOutput, settlementPublishMissing)
+		},
+	}
+	reconcileSettlementCmd.Flags().StringVar(&settlementFile, "file", "", "settlement CSV file")
+	reconcileSettlementCmd.Flags().StringVar(&settlementDate, "date", "", "day the settled orders were completed on, YYYY-MM-DD (default yesterday)")
+	reconcileSettlementCmd.Flags().StringVar(&settlementFormat, "format", inboundCli.SettlementFormatJson, "report format, json or csv")
+	reconcileSettlementCmd.Flags().StringVar(&settlementOutput, "output", "", "report file (default stdout)")
+	reconcileSettlementCmd.Flags().BoolVar(&settlementPublishMissing, "publish-missing", false, "publish the payment callback of paid orders that are not completed")
+	reconcileSettlementCmd.MarkFlagRequired("file")
+	cmd = append(cmd, reconcileSettlementCmd)
+
+	var inventoryRepair bool
+	reconcileInventoryCmd := &cobra.Command{
+		Use:   "reconcile-inventory",
+		Short: "Reconcile category stock in Redis and the database against orders",
+		Run: func(cmd *cobra.Command, args []string) {
+			runReconcileInventoryCmd(ctx, inventoryRepair)
+		},
+	}
+	reconcileInventoryCmd.Flags().BoolVar(&inventoryRepair, "repair", false, "correct the drifted Redis counters and category quantities once they stay idle")
+	cmd = append(cmd, reconcileInventoryCmd)
+
+	var venueFile string
+	var venueDryRun bool
+	importVenueCmd := &cobra.Command{
+		Use:   "import-venue",
+		Short: "Import the categories and seats of an event from a JSON or CSV venue layout",
+		Run: func(cmd *cobra.Command, args []string) {
+			runImportVenueCmd(ctx, venueFile, venueDryRun)
+		},
+	}
+	importVenueCmd.Flags().StringVar(&venueFile, "file", "", "venue layout file, .json or .csv")
+	importVenueCmd.Flags().BoolVar(&venueDryRun, "dry-run", false, "validate the layout and print the capacity summary without importing it")
+	importVenueCmd.MarkFlagRequired("file")
+	cmd = append(cmd, importVenueCmd)
+
 	rootCmd.AddCommand(cmd...)
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalln(err)
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	inboundCli "concert-ticket/inbound/cli"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+func runReconcileSettlementCmd(ctx context.Context, file, date, format, output string, publishMissing bool) {
+	cfg := newCfg("env")
+
+	settledOn := time.Now().AddDate(0, 0, -1)
+	if date != "" {
+		var err error
+		settledOn, err = time.ParseInLocation(time.DateOnly, date, time.Local)
+		if err != nil {
+			log.Fatalln("invalid date", err)
+		}
+	}
+
+	out := os.Stdout
+	if output != "" {
+		var err error
+		out, err = os.Create(output)
+		if err != nil {
+			log.Fatalln("unable to create report file", err)
+		}
+		defer out.Close()
+	}
+
+	db := newDb(cfg)
+	defer db.Close()
+
+	reconciler := inboundCli.SettlementReconciler{
+		Cfg:     cfg,
+		Querier: sqlgen.New(db),
+		TimeNow: time.Now,
+	}
+
+	if publishMissing {
+		natsConn := newNats(cfg)
+		defer natsConn.Close()
+
+		js := newJs(natsConn)
+		createStreamWorkQueue(ctx, js)
+		reconciler.Publisher = js
+	}
+
+	err := reconciler.Run(ctx, inboundCli.SettlementOptions{
+		File:           file,
+		Date:           settledOn,
+		Format:         format,
+		Output:         out,
+		PublishMissing: publishMissing,
+	})
+	if err != nil {
+		log.Fatalln("unable to reconcile settlement", err)
+	}
+}
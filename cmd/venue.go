@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	inboundCli "concert-ticket/inbound/cli"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"log"
+	"os"
+)
+
+func runImportVenueCmd(ctx context.Context, file string, dryRun bool) {
+	cfg := newCfg("env")
+
+	db := newDb(cfg)
+	defer db.Close()
+
+	cacheClient := newRedis(cfg)
+	defer cacheClient.Close()
+
+	importer := inboundCli.VenueImporter{
+		Db:      db,
+		Querier: sqlgen.New(db),
+		Cache:   cacheClient,
+	}
+
+	err := importer.Run(ctx, inboundCli.VenueImportOptions{
+		File:   file,
+		DryRun: dryRun,
+		Output: os.Stdout,
+	})
+	if err != nil {
+		log.Fatalln("unable to import venue layout", err)
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"concert-ticket/common/constant"
+	inboundCron "concert-ticket/inbound/cron"
+	"concert-ticket/inbound/event"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"github.com/nats-io/nats.go/jetstream"
+	"log"
+	"log/slog"
+	"time"
+)
+
+func runQueueWaitlistCmd(ctx context.Context) {
+	cfg := newCfg("env")
+
+	db := newDb(cfg)
+	defer db.Close()
+
+	querier := sqlgen.New(db)
+
+	cacheClient := newRedis(cfg)
+	defer cacheClient.Close()
+
+	natsConn := newNats(cfg)
+	defer natsConn.Close()
+
+	js := newJs(natsConn)
+	createStreamWorkQueue(ctx, js)
+
+	// The claim email names the event and category of the reserved ticket.
+	startCategoryRegistry(ctx, &inboundCron.CategoryCron{
+		Cfg:     cfg,
+		Cache:   cacheClient,
+		Querier: querier,
+	})
+
+	claimExpiryCron := &inboundCron.WaitlistClaimExpiryCron{
+		Cfg:       cfg,
+		Cache:     cacheClient,
+		Publisher: js,
+		TimeNow:   time.Now,
+	}
+
+	go claimExpiryCron.Start(ctx)
+
+	st, err := js.Stream(ctx, constant.QueueStreamName)
+	if err != nil {
+		log.Fatalln("failed to get stream", err)
+	}
+
+	waitlistEvent := event.WaitlistEvent{
+		Cache:     cacheClient,
+		Publisher: js,
+		ClaimUrl:  cfg.GetString("waitlist.claim_url"),
+		ClaimTTL:  cfg.GetDuration("waitlist.claim_ttl"),
+		Timeout:   cfg.GetDuration("queue.waitlist.timeout"),
+		TimeNow:   time.Now,
+	}
+
+	cons, err := st.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       "consumer:waitlist",
+		FilterSubject: constant.WaitlistWildcard,
+		MaxDeliver:    cfg.GetInt("queue.waitlist.max_deliver"),
+		AckWait:       cfg.GetDuration("queue.waitlist.ack_wait"),
+	})
+	if err != nil {
+		log.Fatalln("failed to create consumer", err)
+	}
+
+	iter, err := cons.Messages()
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				msg, err := iter.Next()
+				if err != nil && err != jetstream.ErrMsgIteratorClosed {
+					slog.ErrorContext(ctx, "Error fetching message", slog.Any(constant.LogFieldErr, err))
+					continue
+				}
+
+				if msg == nil {
+					continue
+				}
+
+				var eventErr error
+				switch msg.Subject() {
+				case constant.SubjectWaitlistRestock:
+					eventErr = waitlistEvent.RestockHandler(ctx, msg.Data())
+				}
+
+				if eventErr != nil {
+					msg.Nak()
+					continue
+				}
+
+				if err := msg.Ack(); err != nil {
+					slog.ErrorContext(ctx, "Error acknowledging message",
+						slog.Any(constant.LogFieldErr, err),
+						slog.Any(constant.LogFieldPayload, string(msg.Data())),
+						slog.String("subject", msg.Subject()),
+					)
+					continue
+				}
+			}
+		}
+	}()
+
+	slog.InfoContext(ctx, "waitlist queue consumer started")
+
+	<-ctx.Done()
+
+	iter.Stop()
+
+	slog.InfoContext(ctx, "waitlist queue consumer stopped")
+}
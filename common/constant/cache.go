@@ -3,10 +3,25 @@ package constant
 import "time"
 
 const (
-	EachCategoryQuantityKey = "category:%d:quantity"
-	OrderEmailLock          = "order:email_lock:%s"
+	EachCategoryQuantityKey = "event:%d:category:%d:quantity"
+	InventoryReconcileLease = "inventory:reconcile:lease"
+	InventorySnapshotKey    = "inventory:reconcile:snapshots"
+	OrderEmailLock          = "event:%d:order:email_lock:%s"
+	OrderIdempotencyKey     = "event:%d:order:idempotency:%s:%s"
+	OrderExpiryLeaseKey     = "order:expiry:lease"
+	OrderStatusIndexKey     = "order:status:%s"
+	PaymentCallbackNonceKey = "payment:callback:nonce:%s"
+	SeatHoldKey             = "seat:hold:%s"
+	WaitlistKey             = "event:%d:category:%d:waitlist"
+	WaitlistEmailsKey       = "event:%d:category:%d:waitlist:emails"
+	WaitlistPendingKey      = "event:%d:category:%d:waitlist:pending"
+	WaitlistClaimKey        = "waitlist:claim:%s"
+	WaitlistClaimsKey       = "waitlist:claims"
 )
 
 const (
-	OrderEmailLockDefaultTTL = 1 * time.Minute
+	OrderEmailLockDefaultTTL      = 1 * time.Minute
+	OrderIdempotencyInProgressTTL = 30 * time.Second
+	OrderIdempotencyDefaultTTL    = 24 * time.Hour
+	OrderStatusIndexTTL           = 1 * time.Minute
 )
@@ -3,27 +3,33 @@ package constant
 const EmailOrderConfirmationTemplate = `
 Dear %s,
 
-Thank you for ordering tickets for our concert! Your order has been successfully created.
+Thank you for ordering tickets for %s! Your order has been successfully created.
 
 Order Details:
 ------------------------------------------
+Event: %s
 Order ID: %s
 Ticket Category: %s
+Quantity: %d
+Subtotal: %s
+Platform Fee: %s
+VAT (PPN): %s
 Total Amount: %s
+Payment Method: %s
 Payment Code: %s
 ------------------------------------------
 
 Please complete your payment before: %s
 
 Payment Instructions:
-1. Use the payment code above at any supported payment channel
-2. Complete the payment within the time limit to secure your tickets
-3. You will receive a confirmation email once payment is processed
+%s
 
-If you have any questions or need assistance, please contact our support team at support@concert-ticket.com or call +62 812 3456 7890.
+You will receive a confirmation email once payment is processed.
+
+If you have any questions or need assistance, please contact our support team at %s.
 
 Best regards,
-Concert Ticket Team
+%s
 
 Note: This is an automated message, please do not reply to this email.
 `
@@ -37,10 +43,15 @@ Great news! Your payment has been successfully processed and your tickets are no
 
 Order Details:
 ------------------------------------------
+Event: %s
 Order ID: %s
 Ticket Category: %s
+Quantity: %d
+Subtotal: %s
+Platform Fee: %s
+VAT (PPN): %s
 Total Amount: %s
-Seat: Row %d, Seat %d
+Seats: %s
 ------------------------------------------
 
 Your e-tickets are attached to this email. Please show them at the venue entrance.
@@ -50,12 +61,47 @@ Important Information:
 • Valid ID may be required for entry
 • No refunds or exchanges are permitted
 
-If you have any questions, please contact our support team at support@concert-ticket.com or call +62 812 3456 7890.
+If you have any questions, please contact our support team at %s.
 
-We look forward to seeing you at the concert!
+We look forward to seeing you at %s!
 
 Best regards,
-Concert Ticket Team
+%s
+`
+
+const EmailOrderAdmissionTemplate = `
+Dear %s,
+
+Great news! Your payment has been successfully processed and your tickets are now confirmed.
+
+✅ ORDER COMPLETED ✅
+
+Order Details:
+------------------------------------------
+Event: %s
+Order ID: %s
+Ticket Category: %s
+Quantity: %d
+Subtotal: %s
+Platform Fee: %s
+VAT (PPN): %s
+Total Amount: %s
+Admission Numbers: %s
+------------------------------------------
+
+Your e-tickets are attached to this email. This is a standing area without assigned seats, entry is in the order of admission numbers.
+
+Important Information:
+• Please arrive at least 30 minutes before the show
+• Valid ID may be required for entry
+• No refunds or exchanges are permitted
+
+If you have any questions, please contact our support team at %s.
+
+We look forward to seeing you at %s!
+
+Best regards,
+%s
 `
 
 const EmailOrderCancellationTemplate = `
@@ -65,15 +111,69 @@ We regret to inform you that your order has been cancelled.
 
 Order Details:
 ------------------------------------------
+Event: %s
 Order ID: %s
 Ticket Category: %s
+Quantity: %d
 Total Amount: %s
 ------------------------------------------
 
-If you have any questions or need assistance, please contact our support team at support@concert-ticket.com or call +62 812 3456 7890.
+If you have any questions or need assistance, please contact our support team at %s.
+
+Best regards,
+%s
+
+Note: This is an automated message, please do not reply to this email.
+`
+
+const EmailOrderRefundTemplate = `
+Dear %s,
+
+We received your payment after your order had already been cancelled, and the tickets in your category are no longer available.
+
+Order Details:
+------------------------------------------
+Event: %s
+Order ID: %s
+Ticket Category: %s
+Quantity: %d
+Refund Amount: %s
+------------------------------------------
+
+Your payment will be refunded in full to the original payment method. Depending on your bank, the refund may take up to 14 business days to appear.
+
+If you have any questions or need assistance, please contact our support team at %s.
+
+Best regards,
+%s
+
+Note: This is an automated message, please do not reply to this email.
+`
+
+const EmailWaitlistClaimTemplate = `
+Dear %s,
+
+Good news! A ticket you were waiting for has become available and is reserved for you.
+
+Reservation Details:
+------------------------------------------
+Event: %s
+Ticket Category: %s
+Quantity: 1
+------------------------------------------
+
+Claim your ticket before %s using the link below, it turns your reservation
+into an order that you can pay like any other:
+
+%s
+
+If the reservation is not claimed in time, the ticket goes to the next person
+on the waitlist.
+
+If you have any questions or need assistance, please contact our support team at %s.
 
 Best regards,
-Concert Ticket Team
+%s
 
 Note: This is an automated message, please do not reply to this email.
 `
@@ -9,11 +9,14 @@ const (
 	OrderWildcard    = "events.order.>"
 	CategoryWildcard = "events.category.>"
 	EmailWildcard    = "events.email.>"
+	WaitlistWildcard = "events.waitlist.>"
 
 	SubjectCreateOrder                   = "events.order.create"
 	SubjectIncrementCategoryQuantity     = "events.category.increment_quantity"
 	SubjectBulkIncrementCategoryQuantity = "events.category.bulk_increment_quantity"
 	SubjectCallbackPayment               = "events.order.complete"
+	SubjectRefundPayment                 = "events.order.refund"
 	SubjectAssignOrderTicketRowCol       = "events.assign_ticket"
 	SubjectSendEmail                     = "events.email.send"
+	SubjectWaitlistRestock               = "events.waitlist.restock"
 )
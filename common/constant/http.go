@@ -0,0 +1,10 @@
+package constant
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	HeaderCallbackId        = "X-Callback-Id"
+	HeaderCallbackTimestamp = "X-Callback-Timestamp"
+	HeaderCallbackSignature = "X-Callback-Signature"
+)
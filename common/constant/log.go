@@ -5,4 +5,5 @@ const (
 	LogFieldPayload  = "payload"
 	LogFieldResponse = "response"
 	LogFieldErr      = "error"
+	LogFieldSecurity = "security"
 )
@@ -0,0 +1,67 @@
+package constant
+
+const (
+	PaymentStatusPaid    = "paid"
+	PaymentStatusFailed  = "failed"
+	PaymentStatusExpired = "expired"
+
+	PaymentCurrencyIDR = "IDR"
+)
+
+const (
+	PaymentExceptionAmountMismatch   = "amount_mismatch"
+	PaymentExceptionCurrencyMismatch = "currency_mismatch"
+)
+
+const (
+	LatePaymentOutcomeReinstated     = "reinstated"
+	LatePaymentOutcomeRefundRequired = "refund_required"
+)
+
+const (
+	PaymentCallbackOutcomeReceived      = "received"
+	PaymentCallbackOutcomeQueued        = "queued"
+	PaymentCallbackOutcomePublishFailed = "publish_failed"
+	PaymentCallbackOutcomeRejected      = "rejected"
+	PaymentCallbackOutcomeDuplicate     = "duplicate"
+	PaymentCallbackOutcomeNotFound      = "not_found"
+)
+
+const (
+	PaymentMethodBcaVa     = "bca_va"
+	PaymentMethodBniVa     = "bni_va"
+	PaymentMethodBriVa     = "bri_va"
+	PaymentMethodMandiriVa = "mandiri_va"
+	PaymentMethodQris      = "qris"
+	PaymentMethodEwallet   = "ewallet"
+)
+
+var PaymentMethodNameByMethod = map[string]string{
+	PaymentMethodBcaVa:     "BCA Virtual Account",
+	PaymentMethodBniVa:     "BNI Virtual Account",
+	PaymentMethodBriVa:     "BRI Virtual Account",
+	PaymentMethodMandiriVa: "Mandiri Virtual Account",
+	PaymentMethodQris:      "QRIS",
+	PaymentMethodEwallet:   "E-Wallet",
+}
+
+var PaymentMethodInstructionsByMethod = map[string]string{
+	PaymentMethodBcaVa: `1. Open BCA mobile or KlikBCA, or visit a BCA ATM
+2. Choose m-Transfer, then BCA Virtual Account
+3. Enter the payment code above as the virtual account number and confirm the amount`,
+	PaymentMethodBniVa: `1. Open BNI Mobile Banking, or visit a BNI ATM
+2. Choose Transfer, then Virtual Account Billing
+3. Enter the payment code above as the virtual account number and confirm the amount`,
+	PaymentMethodBriVa: `1. Open BRImo, or visit a BRI ATM
+2. Choose BRIVA
+3. Enter the payment code above as the BRIVA number and confirm the amount`,
+	PaymentMethodMandiriVa: `1. Open Livin' by Mandiri, or visit a Mandiri ATM
+2. Choose Pay, then Multipayment
+3. Enter the payment code above as the virtual account number and confirm the amount`,
+	PaymentMethodQris: `1. Open any banking or e-wallet app that supports QRIS
+2. Scan the QR code generated from the payment code above
+3. Check the merchant name and amount, then confirm the payment`,
+	PaymentMethodEwallet: `1. Open your e-wallet app
+2. Choose Pay, then enter the payment code above
+3. Check the amount and confirm the payment with your PIN`,
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"concert-ticket/common/constant"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"fmt"
+	"golang.org/x/text/message"
+)
+
+// OrderNumber is the order id shown to buyers, prefixed with the order prefix
+// of its event.
+func OrderNumber(event model.Event, id int32) string {
+	return fmt.Sprintf("%s-%d", event.OrderPrefix, id)
+}
+
+func BuildOrderCancellationEmailBody(idrCurrencyFormatter *message.Printer, id int32, categoryId int16, quantity int32, name string, totalAmount int64) string {
+	priceFormattedIdr := idrCurrencyFormatter.Sprintf("Rp%d", totalAmount)
+	category, _ := vars.GetCategory(categoryId)
+	event, _ := vars.GetEvent(category.EventId)
+
+	return fmt.Sprintf(constant.EmailOrderCancellationTemplate,
+		name,
+		event.Name,
+		OrderNumber(event, id),
+		category.Name,
+		quantity,
+		priceFormattedIdr,
+		event.SupportEmail,
+		event.BrandName,
+	)
+}
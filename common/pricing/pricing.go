@@ -0,0 +1,118 @@
+package pricing
+
+import (
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"fmt"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strconv"
+)
+
+const (
+	PlatformFeeTypeFlat       = "flat"
+	PlatformFeeTypePercentage = "percentage"
+)
+
+// rule is the platform fee and VAT applied to a category. Percentages are in
+// basis points, a flat platform fee is in rupiah per ticket.
+type rule struct {
+	platformFeeType string
+	platformFee     int64
+	vatBps          int64
+}
+
+// Calculator prices an order from the category base price, a platform fee and
+// VAT (PPN) charged on top of both. Rules are read from pricing.default and
+// may be overridden per category under pricing.categories.
+type Calculator struct {
+	Cfg *viper.Viper
+
+	defaultRule   rule
+	categoryRules map[int16]rule
+}
+
+func (c *Calculator) Init() {
+	c.defaultRule = c.readRule("pricing.default", rule{platformFeeType: PlatformFeeTypeFlat})
+	c.categoryRules = make(map[int16]rule)
+
+	for key := range c.Cfg.GetStringMap("pricing.categories") {
+		categoryId, err := strconv.ParseInt(key, 10, 16)
+		if err != nil {
+			slog.Warn("invalid category id in pricing ignored", slog.String("category_id", key))
+			continue
+		}
+
+		c.categoryRules[int16(categoryId)] = c.readRule(fmt.Sprintf("pricing.categories.%s", key), c.defaultRule)
+	}
+}
+
+// readRule reads the rule under prefix, settings that are not set are taken
+// from fallback.
+func (c *Calculator) readRule(prefix string, fallback rule) rule {
+	r := fallback
+	if c.Cfg.IsSet(prefix + ".platform_fee_type") {
+		r.platformFeeType = c.Cfg.GetString(prefix + ".platform_fee_type")
+	}
+
+	if c.Cfg.IsSet(prefix + ".platform_fee") {
+		r.platformFee = c.Cfg.GetInt64(prefix + ".platform_fee")
+	}
+
+	if c.Cfg.IsSet(prefix + ".vat_bps") {
+		r.vatBps = c.Cfg.GetInt64(prefix + ".vat_bps")
+	}
+
+	return r
+}
+
+// Calculate prices quantity tickets of a category, ok is false when the
+// category is unknown.
+func (c *Calculator) Calculate(categoryId int16, quantity int32) (model.PriceBreakdown, bool) {
+	category, ok := vars.GetCategory(categoryId)
+	if !ok {
+		return model.PriceBreakdown{}, false
+	}
+
+	r, ok := c.categoryRules[categoryId]
+	if !ok {
+		r = c.defaultRule
+	}
+
+	basePrice := int64(category.Price)
+	subtotal := basePrice * int64(quantity)
+
+	var platformFee int64
+	switch r.platformFeeType {
+	case PlatformFeeTypePercentage:
+		platformFee = applyBps(subtotal, r.platformFee)
+	default:
+		platformFee = r.platformFee * int64(quantity)
+	}
+
+	vat := applyBps(subtotal+platformFee, r.vatBps)
+
+	return model.PriceBreakdown{
+		BasePrice:   basePrice,
+		Subtotal:    subtotal,
+		PlatformFee: platformFee,
+		Vat:         vat,
+		Total:       subtotal + platformFee + vat,
+	}, true
+}
+
+// FromOrder rebuilds the breakdown snapshotted on an order.
+func FromOrder(quantity int32, basePrice, platformFee, vatAmount, totalAmount int64) model.PriceBreakdown {
+	return model.PriceBreakdown{
+		BasePrice:   basePrice,
+		Subtotal:    basePrice * int64(quantity),
+		PlatformFee: platformFee,
+		Vat:         vatAmount,
+		Total:       totalAmount,
+	}
+}
+
+// applyBps returns amount times bps basis points, rounded half up.
+func applyBps(amount, bps int64) int64 {
+	return (amount*bps + 5_000) / 10_000
+}
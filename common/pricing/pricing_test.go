@@ -0,0 +1,121 @@
+package pricing
+
+import (
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type CalculatorTestSuite struct {
+	suite.Suite
+
+	calculator *Calculator
+}
+
+func (s *CalculatorTestSuite) SetupTest() {
+	vars.SetCategories([]model.CategoryResponse{
+		{Id: 1, EventId: 1, Name: "Flat", Price: 1_000_000},
+		{Id: 2, EventId: 1, Name: "Percentage override", Price: 1_000_000},
+		{Id: 3, EventId: 1, Name: "Default", Price: 1_000_000},
+		{Id: 4, EventId: 1, Name: "VAT half", Price: 50},
+		{Id: 5, EventId: 1, Name: "VAT below half", Price: 59},
+		{Id: 6, EventId: 1, Name: "Fee half", Price: 10},
+	})
+
+	cfg := viper.New()
+	cfg.Set("pricing.default.platform_fee_type", PlatformFeeTypePercentage)
+	cfg.Set("pricing.default.platform_fee", 500)
+	cfg.Set("pricing.default.vat_bps", 1100)
+	cfg.Set("pricing.categories", map[string]any{
+		"1":       map[string]any{"platform_fee_type": PlatformFeeTypeFlat, "platform_fee": 150_000},
+		"2":       map[string]any{"platform_fee": 250, "vat_bps": 1000},
+		"4":       map[string]any{"platform_fee": 0},
+		"5":       map[string]any{"platform_fee": 0},
+		"invalid": map[string]any{"platform_fee": 1},
+	})
+
+	s.calculator = &Calculator{Cfg: cfg}
+	s.calculator.Init()
+}
+
+func TestCalculatorTestSuite(t *testing.T) {
+	suite.Run(t, new(CalculatorTestSuite))
+}
+
+func (s *CalculatorTestSuite) TestCalculate() {
+	tests := []struct {
+		name       string
+		categoryId int16
+		quantity   int32
+		expected   model.PriceBreakdown
+		expectedOk bool
+	}{
+		{
+			name:       "flat platform fee per ticket",
+			categoryId: 1,
+			quantity:   2,
+			expected:   model.PriceBreakdown{BasePrice: 1_000_000, Subtotal: 2_000_000, PlatformFee: 300_000, Vat: 253_000, Total: 2_553_000},
+			expectedOk: true,
+		},
+		{
+			name:       "percentage platform fee from default",
+			categoryId: 3,
+			quantity:   1,
+			expected:   model.PriceBreakdown{BasePrice: 1_000_000, Subtotal: 1_000_000, PlatformFee: 50_000, Vat: 115_500, Total: 1_165_500},
+			expectedOk: true,
+		},
+		{
+			name:       "category overrides fee and vat, keeps default fee type",
+			categoryId: 2,
+			quantity:   1,
+			expected:   model.PriceBreakdown{BasePrice: 1_000_000, Subtotal: 1_000_000, PlatformFee: 25_000, Vat: 102_500, Total: 1_127_500},
+			expectedOk: true,
+		},
+		{
+			name:       "vat at half rounds up",
+			categoryId: 4,
+			quantity:   1,
+			expected:   model.PriceBreakdown{BasePrice: 50, Subtotal: 50, PlatformFee: 0, Vat: 6, Total: 56},
+			expectedOk: true,
+		},
+		{
+			name:       "vat below half rounds down",
+			categoryId: 5,
+			quantity:   1,
+			expected:   model.PriceBreakdown{BasePrice: 59, Subtotal: 59, PlatformFee: 0, Vat: 6, Total: 65},
+			expectedOk: true,
+		},
+		{
+			name:       "percentage platform fee at half rounds up",
+			categoryId: 6,
+			quantity:   1,
+			expected:   model.PriceBreakdown{BasePrice: 10, Subtotal: 10, PlatformFee: 1, Vat: 1, Total: 12},
+			expectedOk: true,
+		},
+		{
+			name:       "unknown category",
+			categoryId: 99,
+			quantity:   1,
+			expected:   model.PriceBreakdown{},
+			expectedOk: false,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			price, ok := s.calculator.Calculate(tc.categoryId, tc.quantity)
+
+			s.Equal(tc.expectedOk, ok)
+			s.Equal(tc.expected, price)
+		})
+	}
+}
+
+func (s *CalculatorTestSuite) TestFromOrder() {
+	s.Equal(
+		model.PriceBreakdown{BasePrice: 1_000_000, Subtotal: 2_000_000, PlatformFee: 300_000, Vat: 253_000, Total: 2_553_000},
+		FromOrder(2, 1_000_000, 300_000, 253_000, 2_553_000),
+	)
+}
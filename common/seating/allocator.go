@@ -0,0 +1,116 @@
+package seating
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strconv"
+)
+
+const (
+	StrategyBestAvailable = "best_available"
+	StrategyBackToFront   = "back_to_front"
+	StrategyCenterOut     = "center_out"
+	StrategyRandom        = "random"
+)
+
+// Ranking weighs the terms ClaimSeats orders the available seats by, most
+// preferred first. Row orders by row number, -1 puts the back rows first.
+// RowCenter and ColCenter weigh the squared distance from the middle row and
+// column, and Shuffle orders by a random key. Ties are broken by row then
+// column.
+type Ranking struct {
+	Row       int32
+	RowCenter int32
+	ColCenter int32
+	Shuffle   bool
+}
+
+// SeatAllocator decides which of the available seats an order gets first. The
+// ranking is applied by ClaimSeats in SQL, so only the claimed seats are read
+// and a seat locked by a concurrent worker simply falls through to the next.
+type SeatAllocator interface {
+	Ranking() Ranking
+}
+
+// BestAvailable fills the rows closest to the stage first, from the center of
+// each row outwards.
+type BestAvailable struct{}
+
+func (BestAvailable) Ranking() Ranking {
+	return Ranking{Row: 1, ColCenter: 1}
+}
+
+// BackToFront fills the rows furthest from the stage first, from the center of
+// each row outwards.
+type BackToFront struct{}
+
+func (BackToFront) Ranking() Ranking {
+	return Ranking{Row: -1, ColCenter: 1}
+}
+
+// CenterOut fills the seats closest to the middle of the category first.
+type CenterOut struct{}
+
+func (CenterOut) Ranking() Ranking {
+	return Ranking{RowCenter: 1, ColCenter: 1}
+}
+
+// Random spreads orders over the whole category, which also keeps concurrent
+// workers from contending for the same seats.
+type Random struct{}
+
+func (Random) Ranking() Ranking {
+	return Ranking{Shuffle: true}
+}
+
+// Allocators picks the SeatAllocator of each category. The strategy is read
+// from seat.allocation.default and may be overridden per category under
+// seat.allocation.categories.
+type Allocators struct {
+	Cfg *viper.Viper
+
+	defaultAllocator   SeatAllocator
+	categoryAllocators map[int16]SeatAllocator
+}
+
+func (a *Allocators) Init() {
+	a.defaultAllocator = newAllocator(a.Cfg.GetString("seat.allocation.default"))
+	a.categoryAllocators = make(map[int16]SeatAllocator)
+
+	for key := range a.Cfg.GetStringMap("seat.allocation.categories") {
+		categoryId, err := strconv.ParseInt(key, 10, 16)
+		if err != nil {
+			slog.Warn("invalid category id in seat allocation ignored", slog.String("category_id", key))
+			continue
+		}
+
+		a.categoryAllocators[int16(categoryId)] = newAllocator(a.Cfg.GetString(fmt.Sprintf("seat.allocation.categories.%s", key)))
+	}
+}
+
+func (a *Allocators) For(categoryId int16) SeatAllocator {
+	if allocator, ok := a.categoryAllocators[categoryId]; ok {
+		return allocator
+	}
+
+	return a.defaultAllocator
+}
+
+// newAllocator returns the allocator of strategy, falling back to best
+// available when it is not set or unknown.
+func newAllocator(strategy string) SeatAllocator {
+	switch strategy {
+	case StrategyBestAvailable, "":
+		return BestAvailable{}
+	case StrategyBackToFront:
+		return BackToFront{}
+	case StrategyCenterOut:
+		return CenterOut{}
+	case StrategyRandom:
+		return Random{}
+	default:
+		slog.Warn("unknown seat allocation strategy, using best available", slog.String("strategy", strategy))
+		return BestAvailable{}
+	}
+}
@@ -0,0 +1,76 @@
+package seating
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type SeatAllocatorTestSuite struct {
+	suite.Suite
+}
+
+func TestSeatAllocatorTestSuite(t *testing.T) {
+	suite.Run(t, new(SeatAllocatorTestSuite))
+}
+
+func (s *SeatAllocatorTestSuite) TestRanking() {
+	tests := []struct {
+		name      string
+		allocator SeatAllocator
+		expected  Ranking
+	}{
+		{
+			name:      "best available",
+			allocator: BestAvailable{},
+			expected:  Ranking{Row: 1, ColCenter: 1},
+		},
+		{
+			name:      "back to front",
+			allocator: BackToFront{},
+			expected:  Ranking{Row: -1, ColCenter: 1},
+		},
+		{
+			name:      "center out",
+			allocator: CenterOut{},
+			expected:  Ranking{RowCenter: 1, ColCenter: 1},
+		},
+		{
+			name:      "random",
+			allocator: Random{},
+			expected:  Ranking{Shuffle: true},
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			s.Equal(tc.expected, tc.allocator.Ranking())
+		})
+	}
+}
+
+func (s *SeatAllocatorTestSuite) TestFor() {
+	cfg := viper.New()
+	cfg.Set("seat.allocation.default", StrategyBackToFront)
+	cfg.Set("seat.allocation.categories", map[string]any{
+		"1":       StrategyCenterOut,
+		"2":       StrategyRandom,
+		"3":       "unknown",
+		"invalid": StrategyCenterOut,
+	})
+
+	allocators := &Allocators{Cfg: cfg}
+	allocators.Init()
+
+	s.Equal(CenterOut{}, allocators.For(1))
+	s.Equal(Random{}, allocators.For(2))
+	s.Equal(BestAvailable{}, allocators.For(3))
+	s.Equal(BackToFront{}, allocators.For(9))
+
+	s.Run("default when not configured", func() {
+		allocators := &Allocators{Cfg: viper.New()}
+		allocators.Init()
+
+		s.Equal(BestAvailable{}, allocators.For(1))
+	})
+}
@@ -4,6 +4,10 @@ import (
 	"concert-ticket/common/constant"
 	"concert-ticket/common/otel"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oklog/ulid/v2"
@@ -56,3 +60,33 @@ func PublishMessage(ctx context.Context, publisher jetstream.Publisher, subject
 
 	return nil
 }
+
+// SignPaymentCallback returns the hex HMAC-SHA256 of
+// "<callback id>.<timestamp>.<body>", the signature a payment gateway sends
+// along with its callback. The callback id is signed since replays are
+// deduplicated on it.
+func SignPaymentCallback(secret []byte, callbackId, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(callbackId))
+	mac.Write([]byte("."))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateToken returns a random token handed out to a buyer, only its
+// HashToken is stored.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
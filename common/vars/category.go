@@ -6,7 +6,14 @@ import (
 	"unsafe"
 )
 
-// categoryDataPtr holds a pointer to the current slice of category data.
+// categoryRegistry is one snapshot of the categories table, it is never
+// modified after being published.
+type categoryRegistry struct {
+	categories []model.CategoryResponse
+	byId       map[int16]model.CategoryResponse
+}
+
+// categoryDataPtr holds a pointer to the current category registry.
 // This approach allows for lock-free reads with atomic updates.
 var categoryDataPtr unsafe.Pointer
 
@@ -17,7 +24,19 @@ func GetCategories() []model.CategoryResponse {
 	if ptr == nil {
 		return nil
 	}
-	return *(*[]model.CategoryResponse)(ptr)
+	return (*categoryRegistry)(ptr).categories
+}
+
+// GetCategory returns the category with the given id, ok is false when the
+// category does not exist or the registry is not loaded yet.
+func GetCategory(id int16) (category model.CategoryResponse, ok bool) {
+	ptr := atomic.LoadPointer(&categoryDataPtr)
+	if ptr == nil {
+		return model.CategoryResponse{}, false
+	}
+
+	category, ok = (*categoryRegistry)(ptr).byId[id]
+	return category, ok
 }
 
 // SetCategories atomically updates the category data.
@@ -28,9 +47,17 @@ func SetCategories(categories []model.CategoryResponse) {
 
 	if len(categories) > 0 {
 		// Only create a copy if we have data
-		categoriesCopy := make([]model.CategoryResponse, len(categories))
-		copy(categoriesCopy, categories)
-		ptr = unsafe.Pointer(&categoriesCopy)
+		registry := &categoryRegistry{
+			categories: make([]model.CategoryResponse, len(categories)),
+			byId:       make(map[int16]model.CategoryResponse, len(categories)),
+		}
+
+		copy(registry.categories, categories)
+		for _, category := range categories {
+			registry.byId[category.Id] = category
+		}
+
+		ptr = unsafe.Pointer(registry)
 	}
 
 	// Atomically replace the pointer
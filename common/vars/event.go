@@ -0,0 +1,60 @@
+package vars
+
+import (
+	"concert-ticket/model"
+	"sync/atomic"
+	"unsafe"
+)
+
+// eventRegistry is one snapshot of the events table, it is never modified
+// after being published.
+type eventRegistry struct {
+	events []model.Event
+	byId   map[int16]model.Event
+}
+
+// eventDataPtr holds a pointer to the current event registry, it is swapped
+// the same way as the category registry.
+var eventDataPtr unsafe.Pointer
+
+// GetEvents returns the current events ordered by id.
+func GetEvents() []model.Event {
+	ptr := atomic.LoadPointer(&eventDataPtr)
+	if ptr == nil {
+		return nil
+	}
+	return (*eventRegistry)(ptr).events
+}
+
+// GetEvent returns the event with the given id, ok is false when the event
+// does not exist or the registry is not loaded yet.
+func GetEvent(id int16) (event model.Event, ok bool) {
+	ptr := atomic.LoadPointer(&eventDataPtr)
+	if ptr == nil {
+		return model.Event{}, false
+	}
+
+	event, ok = (*eventRegistry)(ptr).byId[id]
+	return event, ok
+}
+
+// SetEvents atomically replaces the events, pass nil to clear them.
+func SetEvents(events []model.Event) {
+	var ptr unsafe.Pointer
+
+	if len(events) > 0 {
+		registry := &eventRegistry{
+			events: make([]model.Event, len(events)),
+			byId:   make(map[int16]model.Event, len(events)),
+		}
+
+		copy(registry.events, events)
+		for _, event := range events {
+			registry.byId[event.Id] = event
+		}
+
+		ptr = unsafe.Pointer(registry)
+	}
+
+	atomic.StorePointer(&eventDataPtr, ptr)
+}
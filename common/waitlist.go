@@ -0,0 +1,106 @@
+package common
+
+import (
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"context"
+	"fmt"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+)
+
+// restockLua defines restock, which hands released tickets to the heads of the
+// waitlist before anything goes back on the counter. A handed over entry moves
+// to the pending list, where serve-queue:waitlist issues its claim, so a buyer
+// ordering meanwhile never sees the ticket. It returns the number of tickets
+// handed to the waitlist.
+const restockLua = `
+local function restock(counter, waitlist, pending, quantity)
+	local waitlisted = 0
+	while waitlisted < quantity do
+		local entry = redis.call("LPOP", waitlist)
+		if not entry then
+			break
+		end
+		redis.call("RPUSH", pending, entry)
+		waitlisted = waitlisted + 1
+	end
+	if quantity > waitlisted then
+		redis.call("INCRBY", counter, quantity - waitlisted)
+	end
+	return waitlisted
+end
+`
+
+// RestockScript releases tickets of a category. KEYS are the counter, the
+// waitlist and its pending list, ARGV is the quantity released.
+var RestockScript = redis.NewScript(restockLua + `
+return restock(KEYS[1], KEYS[2], KEYS[3], tonumber(ARGV[1]))
+`)
+
+// ExpireWaitlistClaimScript drops a claim and releases its ticket, unless the
+// claim was taken by the buyer or another instance first. KEYS are the claim
+// expiry index, the claim, the counter, the waitlist and its pending list,
+// ARGV is the claim token hash. It returns -1 when the claim was already
+// taken, otherwise the number of tickets handed to the waitlist.
+var ExpireWaitlistClaimScript = redis.NewScript(restockLua + `
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+redis.call("DEL", KEYS[2])
+return restock(KEYS[3], KEYS[4], KEYS[5], 1)
+`)
+
+// RestockKeys returns the keys of RestockScript for a category.
+func RestockKeys(eventId, categoryId int16) []string {
+	return []string{
+		fmt.Sprintf(constant.EachCategoryQuantityKey, eventId, categoryId),
+		fmt.Sprintf(constant.WaitlistKey, eventId, categoryId),
+		fmt.Sprintf(constant.WaitlistPendingKey, eventId, categoryId),
+	}
+}
+
+// Restock releases tickets of a category, the heads of its waitlist are served
+// first and the rest goes back on sale. It returns how many tickets went to
+// the waitlist, a restock message for them has to be published so their
+// claims are issued.
+func Restock(ctx context.Context, cache redis.Scripter, eventId, categoryId int16, quantity int32) (int32, error) {
+	waitlisted, err := RestockScript.Run(ctx, cache, RestockKeys(eventId, categoryId), quantity).Int()
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(waitlisted), nil
+}
+
+// PublishRestock publishes the outcome of Restock for a category, the tickets
+// back on sale go to categories.quantity and the waitlisted ones to the
+// waitlist consumer.
+func PublishRestock(ctx context.Context, publisher jetstream.Publisher, eventId, categoryId int16, quantity, waitlisted int32) error {
+	traceIdAttr := ExtractTraceIDFromCtx(ctx)
+
+	if quantity > waitlisted {
+		err := PublishMessage(ctx, publisher, constant.SubjectBulkIncrementCategoryQuantity, []model.IncrementCategoryQuantityEventMessage{
+			{ID: categoryId, Quantity: quantity - waitlisted},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish bulk increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+	}
+
+	if waitlisted > 0 {
+		err := PublishMessage(ctx, publisher, constant.SubjectWaitlistRestock, model.WaitlistRestockEventMessage{
+			EventId:    eventId,
+			CategoryId: categoryId,
+			Quantity:   waitlisted,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish waitlist restock message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,423 @@
+package cli
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SettlementFormatJson = "json"
+	SettlementFormatCsv  = "csv"
+)
+
+type SettlementOptions struct {
+	File string
+	// Date is the day the settled orders were completed on, the settlement
+	// file of a bank usually covers the previous day.
+	Date           time.Time
+	Format         string
+	Output         io.Writer
+	PublishMissing bool
+}
+
+// SettlementReconciler matches the settlement file of a bank against orders.
+// The file layout is read from settlement.csv, columns are zero based and -1
+// marks a column the bank does not send.
+type SettlementReconciler struct {
+	Cfg       *viper.Viper
+	Querier   *sqlgen.Queries
+	Publisher jetstream.Publisher
+
+	TimeNow func() time.Time
+}
+
+func (in SettlementReconciler) Run(ctx context.Context, opts SettlementOptions) error {
+	file, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := in.Parse(file)
+	if err != nil {
+		return err
+	}
+
+	from := time.Date(opts.Date.Year(), opts.Date.Month(), opts.Date.Day(), 0, 0, 0, 0, opts.Date.Location())
+	report, err := in.Reconcile(ctx, rows, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	report.File = opts.File
+
+	if opts.PublishMissing {
+		report.Published, err = in.PublishMissing(ctx, report)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "settlement reconciled",
+		slog.Int("rows", len(rows)),
+		slog.Int("matched", len(report.Matched)),
+		slog.Int("paid_not_completed", len(report.PaidNotCompleted)),
+		slog.Int("completed_unsettled", len(report.CompletedUnsettled)),
+		slog.Int("amount_mismatch", len(report.AmountMismatch)),
+		slog.Int("unmatched", len(report.Unmatched)),
+		slog.Int("published", report.Published),
+	)
+
+	return WriteSettlementReport(opts.Output, report, opts.Format)
+}
+
+// Parse reads the settlement rows, every row needs a payment code or an
+// external id to be matched by.
+func (in SettlementReconciler) Parse(r io.Reader) ([]model.SettlementRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if delimiter := in.Cfg.GetString("settlement.csv.delimiter"); delimiter != "" {
+		reader.Comma = []rune(delimiter)[0]
+	}
+
+	skipRows := in.Cfg.GetInt("settlement.csv.skip_rows")
+	thousandSeparator := in.Cfg.GetString("settlement.csv.thousand_separator")
+	referenceCol := in.Cfg.GetInt("settlement.csv.columns.reference")
+	paymentCodeCol := in.Cfg.GetInt("settlement.csv.columns.payment_code")
+	externalIdCol := in.Cfg.GetInt("settlement.csv.columns.external_id")
+	providerTransactionIdCol := in.Cfg.GetInt("settlement.csv.columns.provider_transaction_id")
+	amountCol := in.Cfg.GetInt("settlement.csv.columns.amount")
+
+	var rows []model.SettlementRow
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if line <= skipRows {
+			continue
+		}
+
+		row := model.SettlementRow{
+			Line:                  line,
+			Reference:             column(record, referenceCol),
+			PaymentCode:           column(record, paymentCodeCol),
+			ExternalId:            column(record, externalIdCol),
+			ProviderTransactionId: column(record, providerTransactionIdCol),
+		}
+
+		if row.PaymentCode == "" && row.ExternalId == "" {
+			return nil, fmt.Errorf("line %d: missing payment code and external id", line)
+		}
+
+		amount := column(record, amountCol)
+		if thousandSeparator != "" {
+			amount = strings.ReplaceAll(amount, thousandSeparator, "")
+		}
+
+		row.Amount, err = strconv.ParseInt(amount, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, amount)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func column(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[index])
+}
+
+// Reconcile matches settlement rows to orders by payment code, falling back to
+// the external id, and reports the completed orders between from and to that
+// are missing from the settlement.
+func (in SettlementReconciler) Reconcile(ctx context.Context, rows []model.SettlementRow, from, to time.Time) (model.SettlementReport, error) {
+	report := model.SettlementReport{
+		From:               from.Format(time.RFC3339),
+		To:                 to.Format(time.RFC3339),
+		Matched:            []model.SettlementReportEntry{},
+		PaidNotCompleted:   []model.SettlementReportEntry{},
+		CompletedUnsettled: []model.SettlementReportEntry{},
+		AmountMismatch:     []model.SettlementReportEntry{},
+		Unmatched:          []model.SettlementReportEntry{},
+	}
+
+	paymentCodes := make([]string, 0, len(rows))
+	externalIds := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.PaymentCode != "" {
+			paymentCodes = append(paymentCodes, row.PaymentCode)
+		}
+
+		if row.ExternalId != "" {
+			externalIds = append(externalIds, row.ExternalId)
+		}
+	}
+
+	orders, err := in.Querier.FindOrdersByPaymentCodesOrExternalIds(ctx, sqlgen.FindOrdersByPaymentCodesOrExternalIdsParams{
+		PaymentCodes: paymentCodes,
+		ExternalIds:  externalIds,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find settlement orders", slog.Any(constant.LogFieldErr, err))
+		return report, err
+	}
+
+	byPaymentCode := make(map[string]sqlgen.FindOrdersByPaymentCodesOrExternalIdsRow, len(orders))
+	byExternalId := make(map[string]sqlgen.FindOrdersByPaymentCodesOrExternalIdsRow, len(orders))
+	for _, order := range orders {
+		byPaymentCode[order.PaymentCode] = order
+		byExternalId[order.ExternalID] = order
+	}
+
+	settled := make(map[int32]bool, len(rows))
+	for _, row := range rows {
+		entry := model.SettlementReportEntry{
+			Line:                  row.Line,
+			Reference:             row.Reference,
+			ExternalId:            row.ExternalId,
+			PaymentCode:           row.PaymentCode,
+			ProviderTransactionId: row.ProviderTransactionId,
+			SettledAmount:         row.Amount,
+		}
+
+		order, ok := byPaymentCode[row.PaymentCode]
+		if !ok {
+			order, ok = byExternalId[row.ExternalId]
+		}
+
+		if !ok {
+			report.Unmatched = append(report.Unmatched, entry)
+			continue
+		}
+
+		settled[order.ID] = true
+		entry.OrderId = order.ID
+		entry.ExternalId = order.ExternalID
+		entry.PaymentCode = order.PaymentCode
+		entry.OrderStatus = string(order.Status.OrderStatus)
+		entry.ExpectedAmount = order.TotalAmount
+
+		switch {
+		case entry.SettledAmount != entry.ExpectedAmount:
+			report.AmountMismatch = append(report.AmountMismatch, entry)
+		case order.Status.OrderStatus != sqlgen.OrderStatusCompleted:
+			report.PaidNotCompleted = append(report.PaidNotCompleted, entry)
+		default:
+			report.Matched = append(report.Matched, entry)
+		}
+	}
+
+	completed, err := in.Querier.FindCompletedOrdersUpdatedBetween(ctx, sqlgen.FindCompletedOrdersUpdatedBetweenParams{
+		UpdatedFrom: pgtype.Timestamp{Time: from, Valid: true},
+		UpdatedTo:   pgtype.Timestamp{Time: to, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find completed orders", slog.Any(constant.LogFieldErr, err))
+		return report, err
+	}
+
+	for _, order := range completed {
+		if settled[order.ID] {
+			continue
+		}
+
+		report.CompletedUnsettled = append(report.CompletedUnsettled, model.SettlementReportEntry{
+			OrderId:        order.ID,
+			ExternalId:     order.ExternalID,
+			PaymentCode:    order.PaymentCode,
+			OrderStatus:    string(sqlgen.OrderStatusCompleted),
+			ExpectedAmount: order.TotalAmount,
+		})
+	}
+
+	return report, nil
+}
+
+// PublishMissing replays the payment callback of settled orders that are still
+// pending or were cancelled, the latter go through the late payment flow.
+// Orders that already require a refund are left alone, and so are rows
+// without a provider transaction id since the callback could not be matched
+// to the gateway's. Every replay is appended to the payment callback ledger
+// like a delivered callback, a transaction already delivered by the gateway
+// or replayed by an earlier run is not published again.
+func (in SettlementReconciler) PublishMissing(ctx context.Context, report model.SettlementReport) (int, error) {
+	published := 0
+	for _, entry := range report.PaidNotCompleted {
+		status := sqlgen.OrderStatus(entry.OrderStatus)
+		if status != sqlgen.OrderStatusPending && status != sqlgen.OrderStatusCancelled {
+			continue
+		}
+
+		if entry.ProviderTransactionId == "" {
+			slog.WarnContext(ctx, "settlement row without provider transaction id not published",
+				slog.Int("line", entry.Line),
+				slog.String("external_id", entry.ExternalId),
+			)
+			continue
+		}
+
+		req := model.PaymentCallbackRequest{
+			ExternalId:            entry.ExternalId,
+			ProviderTransactionId: entry.ProviderTransactionId,
+			Status:                constant.PaymentStatusPaid,
+			Amount:                entry.SettledAmount,
+			Currency:              constant.PaymentCurrencyIDR,
+		}
+
+		callbackId := fmt.Sprintf("settlement-%s", entry.ProviderTransactionId)
+		fresh, err := in.recordCallback(ctx, callbackId, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to record settlement payment callback", slog.String("external_id", entry.ExternalId), slog.Any(constant.LogFieldErr, err))
+			return published, err
+		}
+
+		if !fresh {
+			slog.WarnContext(ctx, "settlement payment callback already delivered",
+				slog.String("external_id", entry.ExternalId),
+				slog.String("provider_transaction_id", entry.ProviderTransactionId),
+			)
+			continue
+		}
+
+		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectCallbackPayment, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish settlement payment callback", slog.String("external_id", entry.ExternalId), slog.Any(constant.LogFieldErr, err))
+			in.updateCallbackOutcome(ctx, callbackId, constant.PaymentCallbackOutcomePublishFailed)
+			return published, err
+		}
+
+		in.updateCallbackOutcome(ctx, callbackId, constant.PaymentCallbackOutcomeQueued)
+		published++
+	}
+
+	return published, nil
+}
+
+// recordCallback appends a replayed callback to the ledger, see
+// PaymentHttp.recordCallback. It reports false when the transaction already
+// has a live callback or the replay was recorded by an earlier run.
+func (in SettlementReconciler) recordCallback(ctx context.Context, callbackId string, req model.PaymentCallbackRequest) (bool, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	recorded, err := in.Querier.InsertPaymentCallback(ctx, sqlgen.InsertPaymentCallbackParams{
+		ProviderTransactionID: req.ProviderTransactionId,
+		ExternalID:            req.ExternalId,
+		CallbackID:            callbackId,
+		Status:                req.Status,
+		Payload:               string(payload),
+		Headers:               []byte("{}"),
+		ReceivedAt:            pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+		Outcome:               constant.PaymentCallbackOutcomeReceived,
+	})
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return recorded == constant.PaymentCallbackOutcomeReceived, nil
+}
+
+func (in SettlementReconciler) updateCallbackOutcome(ctx context.Context, callbackId, outcome string) {
+	err := in.Querier.UpdatePaymentCallbackOutcome(ctx, sqlgen.UpdatePaymentCallbackOutcomeParams{
+		Outcome:    outcome,
+		UpdatedAt:  pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+		CallbackID: callbackId,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update payment callback outcome",
+			slog.String("callback_id", callbackId),
+			slog.String("outcome", outcome),
+			slog.Any(constant.LogFieldErr, err),
+		)
+	}
+}
+
+func WriteSettlementReport(w io.Writer, report model.SettlementReport, format string) error {
+	switch format {
+	case SettlementFormatJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case SettlementFormatCsv:
+		return writeSettlementReportCsv(w, report)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+func writeSettlementReportCsv(w io.Writer, report model.SettlementReport) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"section", "line", "reference", "order_id", "external_id", "payment_code", "provider_transaction_id", "order_status", "expected_amount", "settled_amount"})
+	if err != nil {
+		return err
+	}
+
+	sections := []struct {
+		name    string
+		entries []model.SettlementReportEntry
+	}{
+		{"matched", report.Matched},
+		{"paid_not_completed", report.PaidNotCompleted},
+		{"completed_unsettled", report.CompletedUnsettled},
+		{"amount_mismatch", report.AmountMismatch},
+		{"unmatched", report.Unmatched},
+	}
+
+	for _, section := range sections {
+		for _, entry := range section.entries {
+			err := writer.Write([]string{
+				section.name,
+				strconv.Itoa(entry.Line),
+				entry.Reference,
+				strconv.Itoa(int(entry.OrderId)),
+				entry.ExternalId,
+				entry.PaymentCode,
+				entry.ProviderTransactionId,
+				entry.OrderStatus,
+				strconv.FormatInt(entry.ExpectedAmount, 10),
+				strconv.FormatInt(entry.SettledAmount, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,336 @@
+package cli
+
+import (
+	"bytes"
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	settlementOrdersQuery  = `SELECT (.+) FROM orders WHERE payment_code = ANY \(\$1::varchar\[\]\) OR external_id = ANY \(\$2::varchar\[\]\)`
+	completedOrdersQuery   = `SELECT (.+) FROM orders WHERE status = 'completed' AND updated_at >= \$1 AND updated_at < \$2 ORDER BY id`
+	settlementOrderColumns = "id,category_id,quantity,external_id,status,payment_code,total_amount"
+)
+
+type SettlementReconcilerTestSuite struct {
+	suite.Suite
+
+	PgxMock    pgxmock.PgxPoolIface
+	publisher  *jetsteamMock.MockPublisher
+	reconciler SettlementReconciler
+}
+
+func (s *SettlementReconcilerTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.publisher = jetsteamMock.NewMockPublisher(ctrl)
+
+	cfg := viper.New()
+	cfg.Set("settlement.csv.delimiter", ";")
+	cfg.Set("settlement.csv.skip_rows", 1)
+	cfg.Set("settlement.csv.thousand_separator", ".")
+	cfg.Set("settlement.csv.columns.reference", 0)
+	cfg.Set("settlement.csv.columns.payment_code", 1)
+	cfg.Set("settlement.csv.columns.external_id", -1)
+	cfg.Set("settlement.csv.columns.amount", 2)
+	cfg.Set("settlement.csv.columns.provider_transaction_id", 3)
+
+	s.reconciler = SettlementReconciler{
+		Cfg:       cfg,
+		Querier:   sqlgen.New(pool),
+		Publisher: s.publisher,
+		TimeNow: func() time.Time {
+			return time.Date(2023, 1, 2, 8, 0, 0, 0, time.UTC)
+		},
+	}
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *SettlementReconcilerTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+}
+
+func TestSettlementReconcilerTestSuite(t *testing.T) {
+	suite.Run(t, new(SettlementReconcilerTestSuite))
+}
+
+func (s *SettlementReconcilerTestSuite) TestParse() {
+	tests := []struct {
+		name         string
+		input        string
+		expectedRows []model.SettlementRow
+		expectError  string
+	}{
+		{
+			name:  "success",
+			input: "Reference;VA Number;Amount;Transaction Id\ntrx-1;39358000000000001;11.000.000;pg-1\ntrx-2; 39358000000000002 ;7.500.000\n",
+			expectedRows: []model.SettlementRow{
+				{Line: 2, Reference: "trx-1", PaymentCode: "39358000000000001", ProviderTransactionId: "pg-1", Amount: 11_000_000},
+				{Line: 3, Reference: "trx-2", PaymentCode: "39358000000000002", Amount: 7_500_000},
+			},
+		},
+		{
+			name:        "missing payment code",
+			input:       "Reference;VA Number;Amount\ntrx-1;;11.000.000\n",
+			expectError: "line 2: missing payment code and external id",
+		},
+		{
+			name:        "invalid amount",
+			input:       "Reference;VA Number;Amount\ntrx-1;39358000000000001;11,000,000\n",
+			expectError: `line 2: invalid amount "11,000,000"`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			rows, err := s.reconciler.Parse(strings.NewReader(tc.input))
+
+			if tc.expectError != "" {
+				s.EqualError(err, tc.expectError)
+				return
+			}
+
+			s.NoError(err)
+			s.Equal(tc.expectedRows, rows)
+		})
+	}
+}
+
+func (s *SettlementReconcilerTestSuite) TestReconcile() {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	rows := []model.SettlementRow{
+		{Line: 2, Reference: "trx-1", PaymentCode: "VA-1", Amount: 11_000_000},
+		{Line: 3, Reference: "trx-2", PaymentCode: "VA-2", ProviderTransactionId: "pg-2", Amount: 7_500_000},
+		{Line: 4, Reference: "trx-3", PaymentCode: "VA-3", Amount: 1_000},
+		{Line: 5, Reference: "trx-4", PaymentCode: "VA-4", Amount: 5_800_000},
+	}
+
+	orderRows := func() *pgxmock.Rows {
+		return pgxmock.NewRows(strings.Split(settlementOrderColumns, ",")).
+			AddRow(int32(1), int16(1), int32(1), "order-1", sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}, "VA-1", int64(11_000_000)).
+			AddRow(int32(2), int16(2), int32(1), "order-2", sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCancelled, Valid: true}, "VA-2", int64(7_500_000)).
+			AddRow(int32(3), int16(3), int32(1), "order-3", sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}, "VA-3", int64(5_800_000))
+	}
+
+	tests := []struct {
+		name           string
+		setupMock      func()
+		expectedReport model.SettlementReport
+		expectError    bool
+	}{
+		{
+			name: "find orders error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(settlementOrdersQuery).
+					WithArgs([]string{"VA-1", "VA-2", "VA-3", "VA-4"}, []string{}).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "find completed orders error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(settlementOrdersQuery).
+					WithArgs([]string{"VA-1", "VA-2", "VA-3", "VA-4"}, []string{}).
+					WillReturnRows(orderRows())
+				s.PgxMock.ExpectQuery(completedOrdersQuery).
+					WithArgs(pgtype.Timestamp{Time: from, Valid: true}, pgtype.Timestamp{Time: to, Valid: true}).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "success",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(settlementOrdersQuery).
+					WithArgs([]string{"VA-1", "VA-2", "VA-3", "VA-4"}, []string{}).
+					WillReturnRows(orderRows())
+				s.PgxMock.ExpectQuery(completedOrdersQuery).
+					WithArgs(pgtype.Timestamp{Time: from, Valid: true}, pgtype.Timestamp{Time: to, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "category_id", "quantity", "external_id", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int32(1), "order-1", "VA-1", int64(11_000_000)).
+						AddRow(int32(5), int16(9), int32(2), "order-5", "VA-5", int64(5_000_000)))
+			},
+			expectedReport: model.SettlementReport{
+				From: "2023-01-01T00:00:00Z",
+				To:   "2023-01-02T00:00:00Z",
+				Matched: []model.SettlementReportEntry{
+					{Line: 2, Reference: "trx-1", OrderId: 1, ExternalId: "order-1", PaymentCode: "VA-1", OrderStatus: "completed", ExpectedAmount: 11_000_000, SettledAmount: 11_000_000},
+				},
+				PaidNotCompleted: []model.SettlementReportEntry{
+					{Line: 3, Reference: "trx-2", OrderId: 2, ExternalId: "order-2", PaymentCode: "VA-2", ProviderTransactionId: "pg-2", OrderStatus: "cancelled", ExpectedAmount: 7_500_000, SettledAmount: 7_500_000},
+				},
+				CompletedUnsettled: []model.SettlementReportEntry{
+					{OrderId: 5, ExternalId: "order-5", PaymentCode: "VA-5", OrderStatus: "completed", ExpectedAmount: 5_000_000},
+				},
+				AmountMismatch: []model.SettlementReportEntry{
+					{Line: 4, Reference: "trx-3", OrderId: 3, ExternalId: "order-3", PaymentCode: "VA-3", OrderStatus: "completed", ExpectedAmount: 5_800_000, SettledAmount: 1_000},
+				},
+				Unmatched: []model.SettlementReportEntry{
+					{Line: 5, Reference: "trx-4", PaymentCode: "VA-4", SettledAmount: 5_800_000},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupMock()
+
+			report, err := s.reconciler.Reconcile(context.Background(), rows, from, to)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+				s.Equal(tc.expectedReport, report)
+			}
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *SettlementReconcilerTestSuite) TestPublishMissing() {
+	report := model.SettlementReport{
+		PaidNotCompleted: []model.SettlementReportEntry{
+			{Line: 2, ExternalId: "order-1", PaymentCode: "VA-1", ProviderTransactionId: "pg-1", OrderStatus: "pending", SettledAmount: 11_000_000},
+			{Line: 3, ExternalId: "order-2", PaymentCode: "VA-2", OrderStatus: "cancelled", SettledAmount: 7_500_000},
+			{Line: 4, ExternalId: "order-3", PaymentCode: "VA-3", ProviderTransactionId: "pg-3", OrderStatus: "refund_required", SettledAmount: 5_800_000},
+			{Line: 5, ExternalId: "order-4", PaymentCode: "VA-4", ProviderTransactionId: "pg-4", OrderStatus: "cancelled", SettledAmount: 7_500_000},
+		},
+	}
+
+	receivedAt := pgtype.Timestamp{Time: time.Date(2023, 1, 2, 8, 0, 0, 0, time.UTC), Valid: true}
+	payload1 := `{"external_id":"order-1","provider_transaction_id":"pg-1","status":"paid","amount":11000000,"currency":"IDR"}`
+	payload4 := `{"external_id":"order-4","provider_transaction_id":"pg-4","status":"paid","amount":7500000,"currency":"IDR"}`
+
+	expectRecord := func(externalId, providerTransactionId, payload string) *pgxmock.ExpectedQuery {
+		return s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+			WithArgs(providerTransactionId, externalId, "settlement-"+providerTransactionId, constant.PaymentStatusPaid, payload, []byte("{}"), receivedAt, constant.PaymentCallbackOutcomeReceived)
+	}
+	expectOutcome := func(providerTransactionId, outcome string) {
+		s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+			WithArgs(outcome, receivedAt, "settlement-"+providerTransactionId).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	}
+
+	tests := []struct {
+		name              string
+		setupMock         func()
+		expectedPublished int
+		expectError       bool
+	}{
+		{
+			name: "record error",
+			setupMock: func() {
+				expectRecord("order-1", "pg-1", payload1).WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "publish error",
+			setupMock: func() {
+				expectRecord("order-1", "pg-1", payload1).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCallbackPayment, []byte(payload1)).
+					Return(nil, fmt.Errorf("publish error"))
+				expectOutcome("pg-1", constant.PaymentCallbackOutcomePublishFailed)
+			},
+			expectError: true,
+		},
+		{
+			name: "skip transactions already delivered",
+			setupMock: func() {
+				expectRecord("order-1", "pg-1", payload1).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeDuplicate))
+				expectRecord("order-4", "pg-4", payload4).
+					WillReturnError(pgx.ErrNoRows)
+			},
+			expectedPublished: 0,
+		},
+		{
+			name: "success",
+			setupMock: func() {
+				expectRecord("order-1", "pg-1", payload1).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCallbackPayment, []byte(payload1)).
+					Return(nil, nil)
+				expectOutcome("pg-1", constant.PaymentCallbackOutcomeQueued)
+
+				expectRecord("order-4", "pg-4", payload4).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCallbackPayment, []byte(payload4)).
+					Return(nil, nil)
+				expectOutcome("pg-4", constant.PaymentCallbackOutcomeQueued)
+			},
+			expectedPublished: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupMock()
+
+			published, err := s.reconciler.PublishMissing(context.Background(), report)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+			}
+
+			s.Equal(tc.expectedPublished, published)
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *SettlementReconcilerTestSuite) TestWriteSettlementReport() {
+	report := model.SettlementReport{
+		Matched: []model.SettlementReportEntry{
+			{Line: 2, Reference: "trx-1", OrderId: 1, ExternalId: "order-1", PaymentCode: "VA-1", ProviderTransactionId: "pg-1", OrderStatus: "completed", ExpectedAmount: 11_000_000, SettledAmount: 11_000_000},
+		},
+		CompletedUnsettled: []model.SettlementReportEntry{
+			{OrderId: 5, ExternalId: "order-5", PaymentCode: "VA-5", OrderStatus: "completed", ExpectedAmount: 5_000_000},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WriteSettlementReport(&buf, report, SettlementFormatCsv)
+	s.NoError(err)
+	s.Equal("section,line,reference,order_id,external_id,payment_code,provider_transaction_id,order_status,expected_amount,settled_amount\n"+
+		"matched,2,trx-1,1,order-1,VA-1,pg-1,completed,11000000,11000000\n"+
+		"completed_unsettled,0,,5,order-5,VA-5,,completed,5000000,0\n", buf.String())
+
+	buf.Reset()
+	err = WriteSettlementReport(&buf, report, SettlementFormatJson)
+	s.NoError(err)
+	s.Contains(buf.String(), `"completed_unsettled": [`)
+
+	err = WriteSettlementReport(&buf, report, "xml")
+	s.EqualError(err, `unknown report format "xml"`)
+}
@@ -0,0 +1,690 @@
+package cli
+
+import (
+	"concert-ticket/common/constant"
+	"concert-ticket/common/contract"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	VenueLayoutFormatJson = "json"
+	VenueLayoutFormatCsv  = "csv"
+)
+
+// venueLayoutCsvColumns are the header names a CSV layout needs, one line per
+// seat with the category columns repeated on every line. The optional
+// seating_type and capacity columns define general admission categories, on a
+// line with an empty section, and the optional sale_starts_at and sale_ends_at
+// columns the sale window of a category.
+var venueLayoutCsvColumns = []string{"event_id", "category_id", "category_name", "price", "section", "row_label", "seat_label", "row", "col", "x", "y"}
+
+type VenueImportOptions struct {
+	File   string
+	DryRun bool
+	Output io.Writer
+}
+
+// VenueImporter replaces the categories and seats of an event with a venue
+// layout. Events that already have orders are refused, their tickets point at
+// the seats being replaced.
+type VenueImporter struct {
+	Db      contract.DbConn
+	Querier *sqlgen.Queries
+	Cache   *redis.Client
+}
+
+func (in VenueImporter) Run(ctx context.Context, opts VenueImportOptions) error {
+	file, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	format := VenueLayoutFormatJson
+	if strings.EqualFold(filepath.Ext(opts.File), ".csv") {
+		format = VenueLayoutFormatCsv
+	}
+
+	layout, err := in.Parse(file, format)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateVenueLayout(layout); err != nil {
+		return fmt.Errorf("invalid layout:\n%w", err)
+	}
+
+	summary := SummarizeVenueLayout(layout)
+	summary.File = opts.File
+	summary.DryRun = opts.DryRun
+
+	if !opts.DryRun {
+		if err := in.Import(ctx, layout); err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "venue layout imported",
+		slog.Int("event_id", int(layout.EventId)),
+		slog.Int("categories", len(summary.Categories)),
+		slog.Int("capacity", int(summary.Capacity)),
+		slog.Bool("dry_run", opts.DryRun),
+	)
+
+	encoder := json.NewEncoder(opts.Output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+func (in VenueImporter) Parse(r io.Reader, format string) (model.VenueLayout, error) {
+	switch format {
+	case VenueLayoutFormatJson:
+		var layout model.VenueLayout
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&layout); err != nil {
+			return layout, fmt.Errorf("decode layout: %w", err)
+		}
+
+		// Seating type defaults to reserved, like the categories table
+		for i := range layout.Categories {
+			if layout.Categories[i].SeatingType == "" {
+				layout.Categories[i].SeatingType = constant.SeatingTypeReserved
+			}
+		}
+
+		return layout, nil
+	case VenueLayoutFormatCsv:
+		return parseVenueLayoutCsv(r)
+	default:
+		return model.VenueLayout{}, fmt.Errorf("unknown layout format %q", format)
+	}
+}
+
+func parseVenueLayoutCsv(r io.Reader) (model.VenueLayout, error) {
+	var layout model.VenueLayout
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return layout, fmt.Errorf("read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range venueLayoutCsvColumns {
+		if _, ok := columns[name]; !ok {
+			return layout, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	type rowKey struct{ section, label string }
+
+	categoryIndex := make(map[int16]int)
+	categoryLines := make(map[int16]int)
+	sectionIndex := make(map[string]int)
+	rowIndex := make(map[rowKey]int)
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return layout, err
+		}
+
+		value := func(name string) string {
+			index, ok := columns[name]
+			if !ok {
+				return ""
+			}
+
+			return column(record, index)
+		}
+
+		eventId, err := parseLayoutInt(line, "event_id", value("event_id"), 16)
+		if err != nil {
+			return layout, err
+		}
+
+		if line == 2 {
+			layout.EventId = int16(eventId)
+		} else if int16(eventId) != layout.EventId {
+			return layout, fmt.Errorf("line %d: event_id %d differs from %d", line, eventId, layout.EventId)
+		}
+
+		categoryId, err := parseLayoutInt(line, "category_id", value("category_id"), 16)
+		if err != nil {
+			return layout, err
+		}
+
+		price, err := parseLayoutInt(line, "price", value("price"), 32)
+		if err != nil {
+			return layout, err
+		}
+
+		seatingType := value("seating_type")
+		if seatingType == "" {
+			seatingType = constant.SeatingTypeReserved
+		}
+
+		var capacity int64
+		if value("capacity") != "" {
+			capacity, err = parseLayoutInt(line, "capacity", value("capacity"), 32)
+			if err != nil {
+				return layout, err
+			}
+		}
+
+		category := model.LayoutCategory{
+			Id:           int16(categoryId),
+			Name:         value("category_name"),
+			Price:        int32(price),
+			SeatingType:  seatingType,
+			Capacity:     int32(capacity),
+			SaleStartsAt: value("sale_starts_at"),
+			SaleEndsAt:   value("sale_ends_at"),
+		}
+
+		if index, ok := categoryIndex[category.Id]; !ok {
+			categoryIndex[category.Id] = len(layout.Categories)
+			categoryLines[category.Id] = line
+			layout.Categories = append(layout.Categories, category)
+		} else if layout.Categories[index] != category {
+			return layout, fmt.Errorf("line %d: category %d differs from line %d", line, category.Id, categoryLines[category.Id])
+		}
+
+		sectionName := value("section")
+		if sectionName == "" {
+			continue
+		}
+
+		row, err := parseLayoutInt(line, "row", value("row"), 32)
+		if err != nil {
+			return layout, err
+		}
+
+		col, err := parseLayoutInt(line, "col", value("col"), 32)
+		if err != nil {
+			return layout, err
+		}
+
+		x, err := parseLayoutFloat(line, "x", value("x"))
+		if err != nil {
+			return layout, err
+		}
+
+		y, err := parseLayoutFloat(line, "y", value("y"))
+		if err != nil {
+			return layout, err
+		}
+
+		seat := model.LayoutSeat{
+			Label: value("seat_label"),
+			Row:   int32(row),
+			Col:   int32(col),
+			X:     x,
+			Y:     y,
+		}
+
+		index, ok := sectionIndex[sectionName]
+		if !ok {
+			index = len(layout.Sections)
+			sectionIndex[sectionName] = index
+			layout.Sections = append(layout.Sections, model.LayoutSection{Name: sectionName, CategoryId: category.Id})
+		}
+
+		section := &layout.Sections[index]
+		if section.CategoryId != category.Id {
+			return layout, fmt.Errorf("line %d: section %q is mapped to category %d", line, sectionName, section.CategoryId)
+		}
+
+		key := rowKey{section: sectionName, label: value("row_label")}
+		rowPosition, ok := rowIndex[key]
+		if !ok {
+			rowPosition = len(section.Rows)
+			rowIndex[key] = rowPosition
+			section.Rows = append(section.Rows, model.LayoutRow{Label: key.label})
+		}
+
+		section.Rows[rowPosition].Seats = append(section.Rows[rowPosition].Seats, seat)
+	}
+
+	return layout, nil
+}
+
+func parseLayoutInt(line int, name, value string, bitSize int) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s %q", line, name, value)
+	}
+
+	return n, nil
+}
+
+func parseLayoutFloat(line int, name, value string) (float32, error) {
+	n, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s %q", line, name, value)
+	}
+
+	return float32(n), nil
+}
+
+// ValidateVenueLayout reports every problem of the layout at once, so a venue
+// file can be fixed in a single pass. Label lengths follow the seats table.
+func ValidateVenueLayout(layout model.VenueLayout) error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if layout.EventId <= 0 {
+		fail("event_id must be positive")
+	}
+
+	if len(layout.Categories) == 0 {
+		fail("at least one category is required")
+	}
+
+	categories := make(map[int16]model.LayoutCategory, len(layout.Categories))
+	for _, category := range layout.Categories {
+		name := fmt.Sprintf("category %d", category.Id)
+
+		if category.Id <= 0 {
+			fail("%s: id must be positive", name)
+		}
+
+		if _, ok := categories[category.Id]; ok {
+			fail("%s: duplicate id", name)
+		} else {
+			categories[category.Id] = category
+		}
+
+		if !validLabel(category.Name, 50) {
+			fail("%s: name must be 1 to 50 characters", name)
+		}
+
+		if category.Price <= 0 {
+			fail("%s: price must be positive", name)
+		}
+
+		switch category.SeatingType {
+		case constant.SeatingTypeReserved:
+			if category.Capacity != 0 {
+				fail("%s: capacity of a reserved category is its number of seats", name)
+			}
+		case constant.SeatingTypeGeneralAdmission:
+			if category.Capacity <= 0 {
+				fail("%s: capacity must be positive", name)
+			}
+		default:
+			fail("%s: unknown seating type %q", name, category.SeatingType)
+		}
+
+		saleStartsAt, saleEndsAt, err := parseLayoutSaleWindow(category)
+		if err != nil {
+			fail("%s: %v", name, err)
+		} else if saleStartsAt.Valid && saleEndsAt.Valid && !saleStartsAt.Time.Before(saleEndsAt.Time) {
+			fail("%s: sale_starts_at must be before sale_ends_at", name)
+		}
+	}
+
+	type position struct{ row, col int32 }
+
+	sections := make(map[string]bool, len(layout.Sections))
+	seats := make(map[int16]map[position]string)
+	for _, section := range layout.Sections {
+		name := fmt.Sprintf("section %q", section.Name)
+
+		if !validLabel(section.Name, 50) {
+			fail("%s: name must be 1 to 50 characters", name)
+		}
+
+		if sections[section.Name] {
+			fail("%s: duplicate name", name)
+		}
+		sections[section.Name] = true
+
+		category, ok := categories[section.CategoryId]
+		if !ok {
+			fail("%s: category %d not found", name, section.CategoryId)
+		} else if category.SeatingType != constant.SeatingTypeReserved {
+			fail("%s: category %d is not reserved seating", name, section.CategoryId)
+		}
+
+		if len(section.Rows) == 0 {
+			fail("%s: at least one row is required", name)
+		}
+
+		if seats[section.CategoryId] == nil {
+			seats[section.CategoryId] = make(map[position]string)
+		}
+
+		rows := make(map[string]bool, len(section.Rows))
+		for _, row := range section.Rows {
+			rowName := fmt.Sprintf("%s row %q", name, row.Label)
+
+			if !validLabel(row.Label, 10) {
+				fail("%s: label must be 1 to 10 characters", rowName)
+			}
+
+			if rows[row.Label] {
+				fail("%s: duplicate label", rowName)
+			}
+			rows[row.Label] = true
+
+			if len(row.Seats) == 0 {
+				fail("%s: at least one seat is required", rowName)
+			}
+
+			labels := make(map[string]bool, len(row.Seats))
+			for _, seat := range row.Seats {
+				seatName := fmt.Sprintf("%s seat %q", rowName, seat.Label)
+
+				if !validLabel(seat.Label, 10) {
+					fail("%s: label must be 1 to 10 characters", seatName)
+				}
+
+				if labels[seat.Label] {
+					fail("%s: duplicate label", seatName)
+				}
+				labels[seat.Label] = true
+
+				if seat.Row < 1 || seat.Col < 1 {
+					fail("%s: row and col must be positive", seatName)
+					continue
+				}
+
+				at := position{row: seat.Row, col: seat.Col}
+				if taken, ok := seats[section.CategoryId][at]; ok {
+					fail("%s: row %d col %d is taken by %s", seatName, seat.Row, seat.Col, taken)
+					continue
+				}
+				seats[section.CategoryId][at] = seatName
+			}
+		}
+	}
+
+	for _, category := range layout.Categories {
+		if category.SeatingType == constant.SeatingTypeReserved && len(seats[category.Id]) == 0 {
+			fail("category %d: no seats are mapped to it", category.Id)
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// ValidateVenueSaleWindows reports the categories of a valid layout whose sale
+// window is not inside the sale window of the event.
+func ValidateVenueSaleWindows(layout model.VenueLayout, eventStartsAt, eventEndsAt time.Time) error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	window := fmt.Sprintf("%s to %s", eventStartsAt.Format(time.RFC3339), eventEndsAt.Format(time.RFC3339))
+	for _, category := range layout.Categories {
+		saleStartsAt, saleEndsAt, _ := parseLayoutSaleWindow(category)
+
+		if saleStartsAt.Valid && (saleStartsAt.Time.Before(eventStartsAt) || !saleStartsAt.Time.Before(eventEndsAt)) {
+			fail("category %d: sale_starts_at must be within the event sale window %s", category.Id, window)
+		}
+
+		if saleEndsAt.Valid && (saleEndsAt.Time.After(eventEndsAt) || !saleEndsAt.Time.After(eventStartsAt)) {
+			fail("category %d: sale_ends_at must be within the event sale window %s", category.Id, window)
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// parseLayoutSaleWindow parses the sale window of a category, an empty time is
+// returned as NULL so the category follows the event.
+func parseLayoutSaleWindow(category model.LayoutCategory) (pgtype.Timestamptz, pgtype.Timestamptz, error) {
+	var saleStartsAt, saleEndsAt pgtype.Timestamptz
+
+	if category.SaleStartsAt != "" {
+		t, err := time.Parse(time.RFC3339, category.SaleStartsAt)
+		if err != nil {
+			return saleStartsAt, saleEndsAt, fmt.Errorf("invalid sale_starts_at %q", category.SaleStartsAt)
+		}
+		saleStartsAt = pgtype.Timestamptz{Time: t.UTC(), Valid: true}
+	}
+
+	if category.SaleEndsAt != "" {
+		t, err := time.Parse(time.RFC3339, category.SaleEndsAt)
+		if err != nil {
+			return saleStartsAt, saleEndsAt, fmt.Errorf("invalid sale_ends_at %q", category.SaleEndsAt)
+		}
+		saleEndsAt = pgtype.Timestamptz{Time: t.UTC(), Valid: true}
+	}
+
+	return saleStartsAt, saleEndsAt, nil
+}
+
+func validLabel(label string, maxLength int) bool {
+	length := utf8.RuneCountInString(label)
+	return strings.TrimSpace(label) != "" && length <= maxLength
+}
+
+// SummarizeVenueLayout counts the capacity of every category of a valid
+// layout, a reserved category holds one ticket per seat.
+func SummarizeVenueLayout(layout model.VenueLayout) model.VenueImportSummary {
+	summary := model.VenueImportSummary{
+		EventId:    layout.EventId,
+		Categories: make([]model.VenueImportSummaryEntry, 0, len(layout.Categories)),
+	}
+
+	index := make(map[int16]int, len(layout.Categories))
+	for _, category := range layout.Categories {
+		index[category.Id] = len(summary.Categories)
+		summary.Categories = append(summary.Categories, model.VenueImportSummaryEntry{
+			CategoryId:  category.Id,
+			Name:        category.Name,
+			SeatingType: category.SeatingType,
+			Capacity:    category.Capacity,
+		})
+	}
+
+	for _, section := range layout.Sections {
+		entry := &summary.Categories[index[section.CategoryId]]
+		entry.Sections++
+		entry.Rows += len(section.Rows)
+		for _, row := range section.Rows {
+			entry.Capacity += int32(len(row.Seats))
+		}
+	}
+
+	for _, entry := range summary.Categories {
+		summary.Capacity += entry.Capacity
+	}
+
+	return summary
+}
+
+// Import replaces the categories and seats of the layout's event in one
+// transaction, then resets the quantity counters of the event in the cache.
+// The layout must be valid, the sale windows of its categories are checked
+// against the event here.
+//
+// The event row is locked first, orders take a key share lock on it when they
+// are inserted, so no order can be created between the check for orders and
+// the commit.
+func (in VenueImporter) Import(ctx context.Context, layout model.VenueLayout) error {
+	eventIdAttr := slog.Int("event_id", int(layout.EventId))
+
+	categoryParams, seatParams := buildVenueLayoutParams(layout)
+
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	withTx := in.Querier.WithTx(tx)
+
+	event, err := withTx.FindEventSaleWindowForUpdate(ctx, layout.EventId)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("event %d not found", layout.EventId)
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find event", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if err := ValidateVenueSaleWindows(layout, event.SaleStartsAt.Time, event.SaleEndsAt.Time); err != nil {
+		return fmt.Errorf("invalid layout:\n%w", err)
+	}
+
+	hasOrders, err := withTx.FindOrderByEventId(ctx, layout.EventId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find orders of event", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if hasOrders {
+		return fmt.Errorf("event %d already has orders, its layout can no longer be replaced", layout.EventId)
+	}
+
+	// Category ids are unique across events, a layout reusing the id of
+	// another event's category would fail on insert with a bare key violation.
+	taken, err := withTx.FindCategoriesOfOtherEvents(ctx, sqlgen.FindCategoriesOfOtherEventsParams{
+		Ids:     categoryParams.Ids,
+		EventID: layout.EventId,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find categories of other events", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if len(taken) > 0 {
+		problems := make([]error, 0, len(taken))
+		for _, category := range taken {
+			problems = append(problems, fmt.Errorf("category %d: id is used by event %d", category.ID, category.EventID))
+		}
+		return fmt.Errorf("invalid layout:\n%w", errors.Join(problems...))
+	}
+
+	if err := withTx.DeleteSeatsByEventId(ctx, layout.EventId); err != nil {
+		slog.ErrorContext(ctx, "failed to delete seats", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	removedIds, err := withTx.DeleteCategoriesByEventId(ctx, layout.EventId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete categories", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if err := withTx.InsertCategories(ctx, categoryParams); err != nil {
+		slog.ErrorContext(ctx, "failed to insert categories", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if _, err := withTx.InsertSeats(ctx, seatParams); err != nil {
+		slog.ErrorContext(ctx, "failed to insert seats", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	pipe := in.Cache.TxPipeline()
+	if len(removedIds) > 0 {
+		removedKeys := make([]string, 0, len(removedIds))
+		for _, id := range removedIds {
+			removedKeys = append(removedKeys, fmt.Sprintf(constant.EachCategoryQuantityKey, layout.EventId, id))
+		}
+		pipe.Del(ctx, removedKeys...)
+	}
+
+	for i, id := range categoryParams.Ids {
+		pipe.Set(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, layout.EventId, id), categoryParams.Capacities[i], 0)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to reset category quantities in cache", eventIdAttr, slog.Any(constant.LogFieldErr, err))
+		return fmt.Errorf("layout imported but the cache was not reset, run reconcile-inventory --repair: %w", err)
+	}
+
+	return nil
+}
+
+// buildVenueLayoutParams flattens the layout into the insert arrays, the seat
+// map grid of a reserved category is sized by its highest row and col.
+func buildVenueLayoutParams(layout model.VenueLayout) (sqlgen.InsertCategoriesParams, sqlgen.InsertSeatsParams) {
+	summary := SummarizeVenueLayout(layout)
+
+	categoryParams := sqlgen.InsertCategoriesParams{EventID: layout.EventId}
+	var seatParams sqlgen.InsertSeatsParams
+
+	maxRows := make(map[int16]int32, len(layout.Categories))
+	maxCols := make(map[int16]int32, len(layout.Categories))
+	for _, section := range layout.Sections {
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				maxRows[section.CategoryId] = max(maxRows[section.CategoryId], seat.Row)
+				maxCols[section.CategoryId] = max(maxCols[section.CategoryId], seat.Col)
+
+				seatParams.CategoryIds = append(seatParams.CategoryIds, section.CategoryId)
+				seatParams.Rows = append(seatParams.Rows, seat.Row)
+				seatParams.Cols = append(seatParams.Cols, seat.Col)
+				seatParams.Sections = append(seatParams.Sections, section.Name)
+				seatParams.RowLabels = append(seatParams.RowLabels, row.Label)
+				seatParams.SeatLabels = append(seatParams.SeatLabels, seat.Label)
+				seatParams.Xs = append(seatParams.Xs, seat.X)
+				seatParams.Ys = append(seatParams.Ys, seat.Y)
+			}
+		}
+	}
+
+	for i, category := range layout.Categories {
+		categoryParams.Ids = append(categoryParams.Ids, category.Id)
+		categoryParams.Names = append(categoryParams.Names, category.Name)
+		categoryParams.Prices = append(categoryParams.Prices, category.Price)
+		categoryParams.Capacities = append(categoryParams.Capacities, summary.Categories[i].Capacity)
+		categoryParams.MaxRows = append(categoryParams.MaxRows, maxRows[category.Id])
+		categoryParams.MaxCols = append(categoryParams.MaxCols, maxCols[category.Id])
+		categoryParams.SeatingTypes = append(categoryParams.SeatingTypes, category.SeatingType)
+
+		saleStartsAt, saleEndsAt, _ := parseLayoutSaleWindow(category)
+		categoryParams.SaleStartsAts = append(categoryParams.SaleStartsAts, saleStartsAt)
+		categoryParams.SaleEndsAts = append(categoryParams.SaleEndsAts, saleEndsAt)
+	}
+
+	return categoryParams, seatParams
+}
@@ -0,0 +1,505 @@
+package cli
+
+import (
+	"bytes"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"fmt"
+	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	findEventSaleWindowQuery  = `SELECT sale_starts_at, sale_ends_at FROM events WHERE id = \$1 FOR UPDATE`
+	findOtherCategoriesQuery  = `SELECT id, event_id FROM categories WHERE id = ANY \(\$1::smallint\[\]\) AND event_id <> \$2`
+	findOrderByEventIdQuery   = `SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1\) AS "exists"`
+	deleteSeatsByEventIdQuery = `DELETE FROM seats WHERE category_id IN \(SELECT id FROM categories WHERE event_id = \$1\)`
+	deleteCategoriesQuery     = `DELETE FROM categories WHERE event_id = \$1 RETURNING id`
+	insertCategoriesQuery     = `INSERT INTO categories (.+) SELECT UNNEST`
+	insertSeatsQuery          = `INSERT INTO seats (.+) SELECT UNNEST`
+)
+
+const testVenueLayoutJson = `{
+  "event_id": 1,
+  "categories": [
+    {"id": 1, "name": "CAT 1", "price": 5800000},
+    {"id": 2, "name": "Festival", "price": 2500000, "seating_type": "general_admission", "capacity": 100, "sale_starts_at": "2025-06-01T10:00:00+07:00", "sale_ends_at": "2025-06-30T22:00:00+07:00"}
+  ],
+  "sections": [
+    {
+      "name": "A",
+      "category_id": 1,
+      "rows": [
+        {"label": "AA", "seats": [{"label": "1", "row": 1, "col": 1, "x": 10, "y": 20}, {"label": "2", "row": 1, "col": 2, "x": 11.5, "y": 20.5}]},
+        {"label": "BB", "seats": [{"label": "2", "row": 2, "col": 2, "x": 11.5, "y": 22}]}
+      ]
+    }
+  ]
+}`
+
+const testVenueLayoutCsv = `event_id,category_id,category_name,price,seating_type,capacity,sale_starts_at,sale_ends_at,section,row_label,seat_label,row,col,x,y
+1,1,CAT 1,5800000,,,,,A,AA,1,1,1,10,20
+1,1,CAT 1,5800000,,,,,A,AA,2,1,2,11.5,20.5
+1,2,Festival,2500000,general_admission,100,2025-06-01T10:00:00+07:00,2025-06-30T22:00:00+07:00,,,,,,,
+1,1,CAT 1,5800000,,,,,A,BB,2,2,2,11.5,22
+`
+
+func testVenueLayout() model.VenueLayout {
+	return model.VenueLayout{
+		EventId: 1,
+		Categories: []model.LayoutCategory{
+			{Id: 1, Name: "CAT 1", Price: 5800000, SeatingType: "reserved"},
+			{Id: 2, Name: "Festival", Price: 2500000, SeatingType: "general_admission", Capacity: 100, SaleStartsAt: "2025-06-01T10:00:00+07:00", SaleEndsAt: "2025-06-30T22:00:00+07:00"},
+		},
+		Sections: []model.LayoutSection{
+			{
+				Name:       "A",
+				CategoryId: 1,
+				Rows: []model.LayoutRow{
+					{Label: "AA", Seats: []model.LayoutSeat{
+						{Label: "1", Row: 1, Col: 1, X: 10, Y: 20},
+						{Label: "2", Row: 1, Col: 2, X: 11.5, Y: 20.5},
+					}},
+					{Label: "BB", Seats: []model.LayoutSeat{
+						{Label: "2", Row: 2, Col: 2, X: 11.5, Y: 22},
+					}},
+				},
+			},
+		},
+	}
+}
+
+type VenueImporterTestSuite struct {
+	suite.Suite
+
+	PgxMock   pgxmock.PgxPoolIface
+	CacheMock redismock.ClientMock
+	Cache     *redis.Client
+	importer  VenueImporter
+}
+
+func (s *VenueImporterTestSuite) SetupTest() {
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.importer = VenueImporter{
+		Db:      pool,
+		Querier: sqlgen.New(pool),
+		Cache:   rdb,
+	}
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *VenueImporterTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestVenueImporterTestSuite(t *testing.T) {
+	suite.Run(t, new(VenueImporterTestSuite))
+}
+
+func (s *VenueImporterTestSuite) TestParse() {
+	tests := []struct {
+		name           string
+		format         string
+		input          string
+		expectedLayout model.VenueLayout
+		expectError    string
+	}{
+		{
+			name:           "json",
+			format:         VenueLayoutFormatJson,
+			input:          testVenueLayoutJson,
+			expectedLayout: testVenueLayout(),
+		},
+		{
+			name:        "json unknown field",
+			format:      VenueLayoutFormatJson,
+			input:       `{"event_id": 1, "categories": [{"id": 1, "quantity": 10}]}`,
+			expectError: `decode layout: json: unknown field "quantity"`,
+		},
+		{
+			name:           "csv",
+			format:         VenueLayoutFormatCsv,
+			input:          testVenueLayoutCsv,
+			expectedLayout: testVenueLayout(),
+		},
+		{
+			name:        "csv missing column",
+			format:      VenueLayoutFormatCsv,
+			input:       "event_id,category_id,category_name,price,section,row_label,seat_label,row,col\n",
+			expectError: `missing column "x"`,
+		},
+		{
+			name:        "csv invalid row",
+			format:      VenueLayoutFormatCsv,
+			input:       "event_id,category_id,category_name,price,section,row_label,seat_label,row,col,x,y\n1,1,CAT 1,5800000,A,AA,1,one,1,10,20\n",
+			expectError: `line 2: invalid row "one"`,
+		},
+		{
+			name:        "csv different event",
+			format:      VenueLayoutFormatCsv,
+			input:       "event_id,category_id,category_name,price,section,row_label,seat_label,row,col,x,y\n1,1,CAT 1,5800000,A,AA,1,1,1,10,20\n2,1,CAT 1,5800000,A,AA,2,1,2,11,20\n",
+			expectError: "line 3: event_id 2 differs from 1",
+		},
+		{
+			name:        "csv category differs",
+			format:      VenueLayoutFormatCsv,
+			input:       "event_id,category_id,category_name,price,section,row_label,seat_label,row,col,x,y\n1,1,CAT 1,5800000,A,AA,1,1,1,10,20\n1,1,CAT 1,5200000,A,AA,2,1,2,11,20\n",
+			expectError: "line 3: category 1 differs from line 2",
+		},
+		{
+			name:        "csv section mapped to another category",
+			format:      VenueLayoutFormatCsv,
+			input:       "event_id,category_id,category_name,price,section,row_label,seat_label,row,col,x,y\n1,1,CAT 1,5800000,A,AA,1,1,1,10,20\n1,2,CAT 2,5200000,A,AA,2,1,2,11,20\n",
+			expectError: `line 3: section "A" is mapped to category 1`,
+		},
+		{
+			name:        "unknown format",
+			format:      "xml",
+			expectError: `unknown layout format "xml"`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			layout, err := s.importer.Parse(strings.NewReader(tc.input), tc.format)
+
+			if tc.expectError != "" {
+				s.EqualError(err, tc.expectError)
+				return
+			}
+
+			s.NoError(err)
+			s.Equal(tc.expectedLayout, layout)
+		})
+	}
+}
+
+func (s *VenueImporterTestSuite) TestValidate() {
+	tests := []struct {
+		name        string
+		layout      func() model.VenueLayout
+		expectError string
+	}{
+		{
+			name:   "valid",
+			layout: testVenueLayout,
+		},
+		{
+			name: "invalid categories",
+			layout: func() model.VenueLayout {
+				layout := testVenueLayout()
+				layout.EventId = 0
+				layout.Categories[1].Capacity = 0
+				layout.Categories = append(layout.Categories,
+					model.LayoutCategory{Id: 1, Name: "", Price: 0, SeatingType: "standing"},
+					model.LayoutCategory{Id: 3, Name: "CAT 3", Price: 4600000, SeatingType: "reserved"},
+				)
+				return layout
+			},
+			expectError: "event_id must be positive\n" +
+				"category 2: capacity must be positive\n" +
+				"category 1: duplicate id\n" +
+				"category 1: name must be 1 to 50 characters\n" +
+				"category 1: price must be positive\n" +
+				`category 1: unknown seating type "standing"` + "\n" +
+				"category 3: no seats are mapped to it",
+		},
+		{
+			name: "invalid sale windows",
+			layout: func() model.VenueLayout {
+				layout := testVenueLayout()
+				layout.Categories[0].SaleStartsAt = "2025-06-01"
+				layout.Categories[1].SaleStartsAt = "2025-07-01T00:00:00+07:00"
+				return layout
+			},
+			expectError: `category 1: invalid sale_starts_at "2025-06-01"` + "\n" +
+				"category 2: sale_starts_at must be before sale_ends_at",
+		},
+		{
+			name: "invalid sections",
+			layout: func() model.VenueLayout {
+				layout := testVenueLayout()
+				layout.Sections[0].Rows[0].Seats = append(layout.Sections[0].Rows[0].Seats,
+					model.LayoutSeat{Label: "1", Row: 1, Col: 3},
+					model.LayoutSeat{Label: "3", Row: 0, Col: 3},
+				)
+				layout.Sections[0].Rows[1].Seats[0].Row = 1
+				layout.Sections = append(layout.Sections,
+					model.LayoutSection{Name: "A", CategoryId: 2},
+					model.LayoutSection{Name: "B", CategoryId: 9, Rows: []model.LayoutRow{{Label: "AA"}}},
+				)
+				return layout
+			},
+			expectError: `section "A" row "AA" seat "1": duplicate label` + "\n" +
+				`section "A" row "AA" seat "3": row and col must be positive` + "\n" +
+				`section "A" row "BB" seat "2": row 1 col 2 is taken by section "A" row "AA" seat "2"` + "\n" +
+				`section "A": duplicate name` + "\n" +
+				`section "A": category 2 is not reserved seating` + "\n" +
+				`section "A": at least one row is required` + "\n" +
+				`section "B": category 9 not found` + "\n" +
+				`section "B" row "AA": at least one seat is required`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			err := ValidateVenueLayout(tc.layout())
+
+			if tc.expectError != "" {
+				s.EqualError(err, tc.expectError)
+				return
+			}
+
+			s.NoError(err)
+		})
+	}
+}
+
+func (s *VenueImporterTestSuite) TestImport() {
+	expectReplace := func() {
+		s.PgxMock.ExpectExec(deleteSeatsByEventIdQuery).
+			WithArgs(int16(1)).
+			WillReturnResult(pgxmock.NewResult("DELETE", 4))
+		s.PgxMock.ExpectQuery(deleteCategoriesQuery).
+			WithArgs(int16(1)).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int16(1)).AddRow(int16(3)))
+		s.PgxMock.ExpectExec(insertCategoriesQuery).
+			WithArgs(
+				[]int16{1, 2},
+				int16(1),
+				[]string{"CAT 1", "Festival"},
+				[]int32{5800000, 2500000},
+				[]int32{3, 100},
+				[]int32{2, 0},
+				[]int32{2, 0},
+				[]string{"reserved", "general_admission"},
+				[]pgtype.Timestamptz{{}, {Time: time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC), Valid: true}},
+				[]pgtype.Timestamptz{{}, {Time: time.Date(2025, 6, 30, 15, 0, 0, 0, time.UTC), Valid: true}},
+			).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		s.PgxMock.ExpectExec(insertSeatsQuery).
+			WithArgs(
+				[]int16{1, 1, 1},
+				[]int32{1, 1, 2},
+				[]int32{1, 2, 2},
+				[]string{"A", "A", "A"},
+				[]string{"AA", "AA", "BB"},
+				[]string{"1", "2", "2"},
+				[]float32{10, 11.5, 11.5},
+				[]float32{20, 20.5, 22},
+			).
+			WillReturnResult(pgxmock.NewResult("INSERT", 3))
+		s.PgxMock.ExpectCommit()
+	}
+
+	expectResetCache := func() {
+		s.CacheMock.ExpectTxPipeline()
+		s.CacheMock.ExpectDel("event:1:category:1:quantity", "event:1:category:3:quantity").SetVal(2)
+		s.CacheMock.ExpectSet("event:1:category:1:quantity", int32(3), 0).SetVal("OK")
+		s.CacheMock.ExpectSet("event:1:category:2:quantity", int32(100), 0).SetVal("OK")
+	}
+
+	eventSaleWindow := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"sale_starts_at", "sale_ends_at"}).
+			AddRow(
+				pgtype.Timestamptz{Time: time.Date(2025, 5, 1, 3, 0, 0, 0, time.UTC), Valid: true},
+				pgtype.Timestamptz{Time: time.Date(2025, 7, 1, 3, 0, 0, 0, time.UTC), Valid: true},
+			)
+	}
+
+	tests := []struct {
+		name        string
+		setupMock   func()
+		expectError string
+	}{
+		{
+			name: "event not found",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: "event 1 not found",
+		},
+		{
+			name: "sale window outside the event",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"sale_starts_at", "sale_ends_at"}).
+						AddRow(
+							pgtype.Timestamptz{Time: time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC), Valid: true},
+							pgtype.Timestamptz{Time: time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC), Valid: true},
+						))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: "invalid layout:\n" +
+				"category 2: sale_starts_at must be within the event sale window 2025-06-15T00:00:00Z to 2025-06-20T00:00:00Z\n" +
+				"category 2: sale_ends_at must be within the event sale window 2025-06-15T00:00:00Z to 2025-06-20T00:00:00Z",
+		},
+		{
+			name: "event has orders",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(eventSaleWindow())
+				s.PgxMock.ExpectQuery(findOrderByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: "event 1 already has orders, its layout can no longer be replaced",
+		},
+		{
+			name: "category id of another event",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(eventSaleWindow())
+				s.PgxMock.ExpectQuery(findOrderByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.PgxMock.ExpectQuery(findOtherCategoriesQuery).
+					WithArgs([]int16{1, 2}, int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id"}).AddRow(int16(2), int16(3)))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: "invalid layout:\ncategory 2: id is used by event 3",
+		},
+		{
+			name: "insert seats error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(eventSaleWindow())
+				s.PgxMock.ExpectQuery(findOrderByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.PgxMock.ExpectQuery(findOtherCategoriesQuery).
+					WithArgs([]int16{1, 2}, int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id"}))
+				s.PgxMock.ExpectExec(deleteSeatsByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				s.PgxMock.ExpectQuery(deleteCategoriesQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}))
+				s.PgxMock.ExpectExec(insertCategoriesQuery).
+					WithArgs(pgxmock.AnyArg(), int16(1), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 2))
+				s.PgxMock.ExpectExec(insertSeatsQuery).
+					WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: "database error",
+		},
+		{
+			name: "cache error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(eventSaleWindow())
+				s.PgxMock.ExpectQuery(findOrderByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.PgxMock.ExpectQuery(findOtherCategoriesQuery).
+					WithArgs([]int16{1, 2}, int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id"}))
+				expectReplace()
+				expectResetCache()
+				s.CacheMock.ExpectTxPipelineExec().SetErr(redis.ErrClosed)
+			},
+			expectError: "layout imported but the cache was not reset, run reconcile-inventory --repair: redis: client is closed",
+		},
+		{
+			name: "success",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(findEventSaleWindowQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(eventSaleWindow())
+				s.PgxMock.ExpectQuery(findOrderByEventIdQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.PgxMock.ExpectQuery(findOtherCategoriesQuery).
+					WithArgs([]int16{1, 2}, int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id"}))
+				expectReplace()
+				expectResetCache()
+				s.CacheMock.ExpectTxPipelineExec()
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupMock()
+
+			err := s.importer.Import(context.Background(), testVenueLayout())
+
+			if tc.expectError != "" {
+				s.EqualError(err, tc.expectError)
+			} else {
+				s.NoError(err)
+			}
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *VenueImporterTestSuite) TestRunDryRun() {
+	file := filepath.Join(s.T().TempDir(), "layout.csv")
+	s.Require().NoError(os.WriteFile(file, []byte(testVenueLayoutCsv), 0644))
+
+	var out bytes.Buffer
+	err := s.importer.Run(context.Background(), VenueImportOptions{File: file, DryRun: true, Output: &out})
+
+	s.NoError(err)
+	s.JSONEq(fmt.Sprintf(`{
+		"file": %q,
+		"event_id": 1,
+		"dry_run": true,
+		"capacity": 103,
+		"categories": [
+			{"category_id": 1, "name": "CAT 1", "seating_type": "reserved", "sections": 1, "rows": 2, "capacity": 3},
+			{"category_id": 2, "name": "Festival", "seating_type": "general_admission", "sections": 0, "rows": 0, "capacity": 100}
+		]
+	}`, file), out.String())
+	s.NoError(s.PgxMock.ExpectationsWereMet())
+}
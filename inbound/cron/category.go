@@ -4,6 +4,7 @@ import (
 	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	"concert-ticket/common/vars"
+	"concert-ticket/model"
 	"concert-ticket/outbound/sqlgen"
 	"context"
 	"fmt"
@@ -20,20 +21,19 @@ type CategoryCron struct {
 	Querier *sqlgen.Queries
 }
 
+// Start keeps the category registry up to date, Refresh must have loaded it
+// once before so handlers never read an empty registry.
 func (in CategoryCron) Start(ctx context.Context) {
 	refreshTicker := time.NewTicker(in.Cfg.GetDuration("cron.category.refresh.interval"))
 	defer refreshTicker.Stop()
 
-	// Run initial refresh
-	in.refresh(ctx)
-
 	slog.Info("category cron started")
 
 	// Block in the main function, not in a goroutine
 	for {
 		select {
 		case <-refreshTicker.C:
-			in.refresh(ctx)
+			in.Refresh(ctx)
 		case <-ctx.Done():
 			slog.Info("category cron stopped")
 			return
@@ -41,7 +41,11 @@ func (in CategoryCron) Start(ctx context.Context) {
 	}
 }
 
-func (in CategoryCron) refresh(ctx context.Context) {
+// Refresh loads the events and categories tables into the registry, with the
+// remaining quantity of each category read from the cache. Sale windows are
+// reloaded too, so a category opens or closes on the next refresh after its
+// window is changed, without a restart.
+func (in CategoryCron) Refresh(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, in.Cfg.GetDuration("cron.category.refresh.timeout"))
 	defer cancel()
 
@@ -49,35 +53,117 @@ func (in CategoryCron) refresh(ctx context.Context) {
 
 	slog.DebugContext(ctx, "refreshing categories", traceIdAttr)
 
-	categories := constant.CategoriesData
-	quantityCacheKeys := make([]string, 0, len(categories))
-	for _, category := range categories {
-		quantityCacheKeys = append(quantityCacheKeys, fmt.Sprintf(constant.EachCategoryQuantityKey, category.Id))
+	events, err := in.findEvents(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := in.Querier.FindAllCategories(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find categories", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return fmt.Errorf("find categories: %w", err)
+	}
+
+	if len(rows) == 0 {
+		slog.WarnContext(ctx, "no categories found", traceIdAttr)
+		vars.SetEvents(events)
+		vars.SetCategories(nil)
+		return nil
+	}
+
+	quantityCacheKeys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		quantityCacheKeys = append(quantityCacheKeys, fmt.Sprintf(constant.EachCategoryQuantityKey, row.EventID, row.ID))
 	}
 
 	quantities, err := in.Cache.MGet(ctx, quantityCacheKeys...).Result()
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to get quantities from cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
-		return
+		return fmt.Errorf("get quantities: %w", err)
 	}
 
-	for i, quantity := range quantities {
-		if quantity == "" {
+	locations := make(map[int16]*time.Location, len(events))
+	for _, event := range events {
+		locations[event.Id] = event.Location
+	}
+
+	categories := make([]model.CategoryResponse, 0, len(rows))
+	for i, row := range rows {
+		quantity := quantities[i]
+		if quantity == nil || quantity == "" {
 			quantity = "0"
 		}
 
 		quantityInt, err := strconv.Atoi(quantity.(string))
 		if err != nil {
 			slog.ErrorContext(ctx, "failed to convert quantity to int", traceIdAttr, slog.Any(constant.LogFieldErr, err))
-			return
+			return fmt.Errorf("convert quantity: %w", err)
 		}
 
-		categories[i].Quantity = int32(quantityInt)
+		// An event created after the events were read has no location yet,
+		// its categories are shown in UTC until the next refresh.
+		location, ok := locations[row.EventID]
+		if !ok {
+			location = time.UTC
+		}
+
+		categories = append(categories, model.CategoryResponse{
+			Id:           row.ID,
+			EventId:      row.EventID,
+			Name:         row.Name,
+			Price:        row.Price,
+			Quantity:     int32(quantityInt),
+			SeatingType:  string(row.SeatingType),
+			SaleStartsAt: row.SaleStartsAt.Time.In(location),
+			SaleEndsAt:   row.SaleEndsAt.Time.In(location),
+		})
 	}
 
+	// Events go first, a category is never published before its event.
+	vars.SetEvents(events)
 	vars.SetCategories(categories)
 
 	slog.DebugContext(ctx, "categories refreshed successfully", traceIdAttr)
+
+	return nil
+}
+
+// findEvents loads the events table, an event with an unknown timezone fails
+// the whole refresh so times are never shown in the wrong zone.
+func (in CategoryCron) findEvents(ctx context.Context) ([]model.Event, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	rows, err := in.Querier.FindAllEvents(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find events", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, fmt.Errorf("find events: %w", err)
+	}
+
+	events := make([]model.Event, 0, len(rows))
+	for _, row := range rows {
+		location, err := time.LoadLocation(row.Timezone)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load event timezone", slog.Int("event_id", int(row.ID)), traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return nil, fmt.Errorf("load timezone of event %d: %w", row.ID, err)
+		}
+
+		events = append(events, model.Event{
+			Id:           row.ID,
+			Name:         row.Name,
+			Venue:        row.VenueName,
+			City:         row.VenueCity,
+			Timezone:     row.Timezone,
+			Location:     location,
+			StartsAt:     row.StartsAt.Time.In(location),
+			SaleStartsAt: row.SaleStartsAt.Time.In(location),
+			SaleEndsAt:   row.SaleEndsAt.Time.In(location),
+			OrderPrefix:  row.OrderPrefix,
+			BrandName:    row.BrandName,
+			SupportEmail: row.SupportEmail,
+		})
+	}
+
+	return events, nil
 }
 
 func (in CategoryCron) InitQuantityCache(ctx context.Context) error {
@@ -97,7 +183,7 @@ func (in CategoryCron) InitQuantityCache(ctx context.Context) error {
 
 	pipe := in.Cache.TxPipeline()
 	for _, category := range categories {
-		pipe.SetNX(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, category.ID), category.Quantity, 0)
+		pipe.SetNX(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, category.EventID, category.ID), category.Quantity, 0)
 	}
 
 	if _, err = pipe.Exec(ctx); err != nil {
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/pashagolub/pgxmock/v4"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
@@ -46,22 +47,6 @@ func (s *CategoryCronTestSuite) SetupTest() {
 	s.Cfg.Set("cron.category.refresh.interval", "5s")
 	s.Cfg.Set("cron.category.refresh.timeout", "10s")
 
-	// Initialize test data
-	constant.CategoriesData = []model.CategoryResponse{
-		{
-			Id:       1,
-			Name:     "Category 1",
-			Price:    100,
-			Quantity: 0,
-		},
-		{
-			Id:       2,
-			Name:     "Category 2",
-			Price:    200,
-			Quantity: 0,
-		},
-	}
-
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 }
 
@@ -72,83 +57,188 @@ func (s *CategoryCronTestSuite) TearDownTest() {
 		s.T().Fatalf("failed to close redis mock: %v", err)
 	}
 
-	// Reset the categories
+	// Reset the registry
+	vars.SetEvents(nil)
 	vars.SetCategories(nil)
 }
 
+var (
+	categoryColumns        = []string{"id", "event_id", "name", "price", "max_row", "max_col", "quantity", "seating_type", "sale_starts_at", "sale_ends_at"}
+	findAllCategoriesQuery = `SELECT c.id, c.event_id, c.name, c.price, c.max_row, c.max_col, c.quantity, c.seating_type, GREATEST\(c.sale_starts_at, e.sale_starts_at\)::timestamptz AS sale_starts_at, LEAST\(c.sale_ends_at, e.sale_ends_at\)::timestamptz AS sale_ends_at FROM categories c JOIN events e ON e.id = c.event_id ORDER BY c.id`
+	eventColumns           = []string{"id", "name", "timezone", "starts_at", "sale_starts_at", "sale_ends_at", "order_prefix", "brand_name", "support_email", "venue_name", "venue_city"}
+	findAllEventsQuery     = `SELECT e.id, e.name, e.timezone, e.starts_at, e.sale_starts_at, e.sale_ends_at, e.order_prefix, e.brand_name, e.support_email, v.name AS venue_name, v.city AS venue_city FROM events e JOIN venues v ON v.id = e.venue_id ORDER BY e.id`
+)
+
+// Category 1 is sold for the whole sale window of its event, category 2 opens
+// later with a window of its own.
+var (
+	eventSaleStartsAt    = pgtype.Timestamptz{Time: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), Valid: true}
+	eventSaleEndsAt      = pgtype.Timestamptz{Time: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+	categorySaleStartsAt = pgtype.Timestamptz{Time: time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC), Valid: true}
+)
+
 func TestCategoryCronTestSuite(t *testing.T) {
 	suite.Run(t, new(CategoryCronTestSuite))
 }
 
+func (s *CategoryCronTestSuite) expectFindAllEvents() {
+	rows := pgxmock.NewRows(eventColumns).
+		AddRow(int16(1), "Event 1", "Asia/Jakarta",
+			pgtype.Timestamptz{Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Valid: true},
+			pgtype.Timestamptz{Time: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), Valid: true},
+			pgtype.Timestamptz{Time: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), Valid: true},
+			"EVT", "Event Team", "support@example.com", "Venue 1", "Jakarta")
+
+	s.PgxMock.ExpectQuery(findAllEventsQuery).WillReturnRows(rows)
+}
+
+func (s *CategoryCronTestSuite) expectFindAllCategories() {
+	rows := pgxmock.NewRows(categoryColumns).
+		AddRow(int16(1), int16(1), "Category 1", int32(100), int32(10), int32(10), int32(50), sqlgen.SeatingTypeReserved, eventSaleStartsAt, eventSaleEndsAt).
+		AddRow(int16(2), int16(1), "Category 2", int32(200), int32(5), int32(5), int32(75), sqlgen.SeatingTypeGeneralAdmission, categorySaleStartsAt, eventSaleEndsAt)
+
+	s.PgxMock.ExpectQuery(findAllCategoriesQuery).WillReturnRows(rows)
+}
+
 func (s *CategoryCronTestSuite) TestRefresh() {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	s.Require().NoError(err)
+
 	tests := []struct {
 		name           string
 		setupMock      func()
+		expectError    bool
 		expectedResult []model.CategoryResponse
 	}{
+		{
+			name: "find events error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(findAllEventsQuery).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError:    true,
+			expectedResult: nil,
+		},
+		{
+			name: "unknown event timezone",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(findAllEventsQuery).
+					WillReturnRows(pgxmock.NewRows(eventColumns).
+						AddRow(int16(1), "Event 1", "Mars/Olympus", pgtype.Timestamptz{}, pgtype.Timestamptz{}, pgtype.Timestamptz{},
+							"EVT", "Event Team", "support@example.com", "Venue 1", "Jakarta"))
+			},
+			expectError:    true,
+			expectedResult: nil,
+		},
+		{
+			name: "database error",
+			setupMock: func() {
+				s.expectFindAllEvents()
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError:    true,
+			expectedResult: nil,
+		},
+		{
+			name: "no categories found",
+			setupMock: func() {
+				s.expectFindAllEvents()
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
+					WillReturnRows(pgxmock.NewRows(categoryColumns))
+			},
+			expectedResult: nil,
+		},
 		{
 			name: "cache error",
 			setupMock: func() {
-				s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
+				s.expectFindAllEvents()
+				s.expectFindAllCategories()
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
 					SetErr(redis.ErrClosed)
 			},
+			expectError:    true,
 			expectedResult: nil,
 		},
 		{
 			name: "success with zero quantities",
 			setupMock: func() {
-				s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
-					SetVal([]interface{}{"", ""})
+				s.expectFindAllEvents()
+				s.expectFindAllCategories()
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"", nil})
 			},
 			expectedResult: []model.CategoryResponse{
 				{
-					Id:       1,
-					Name:     "Category 1",
-					Price:    100,
-					Quantity: 0,
+					Id:           1,
+					EventId:      1,
+					Name:         "Category 1",
+					Price:        100,
+					Quantity:     0,
+					SeatingType:  constant.SeatingTypeReserved,
+					SaleStartsAt: eventSaleStartsAt.Time.In(jakarta),
+					SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 				},
 				{
-					Id:       2,
-					Name:     "Category 2",
-					Price:    200,
-					Quantity: 0,
+					Id:           2,
+					EventId:      1,
+					Name:         "Category 2",
+					Price:        200,
+					Quantity:     0,
+					SeatingType:  constant.SeatingTypeGeneralAdmission,
+					SaleStartsAt: categorySaleStartsAt.Time.In(jakarta),
+					SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 				},
 			},
 		},
 		{
 			name: "success with actual quantities",
 			setupMock: func() {
-				s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
+				s.expectFindAllEvents()
+				s.expectFindAllCategories()
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
 					SetVal([]interface{}{"50", "75"})
 			},
 			expectedResult: []model.CategoryResponse{
 				{
-					Id:       1,
-					Name:     "Category 1",
-					Price:    100,
-					Quantity: 50,
+					Id:           1,
+					EventId:      1,
+					Name:         "Category 1",
+					Price:        100,
+					Quantity:     50,
+					SeatingType:  constant.SeatingTypeReserved,
+					SaleStartsAt: eventSaleStartsAt.Time.In(jakarta),
+					SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 				},
 				{
-					Id:       2,
-					Name:     "Category 2",
-					Price:    200,
-					Quantity: 75,
+					Id:           2,
+					EventId:      1,
+					Name:         "Category 2",
+					Price:        200,
+					Quantity:     75,
+					SeatingType:  constant.SeatingTypeGeneralAdmission,
+					SaleStartsAt: categorySaleStartsAt.Time.In(jakarta),
+					SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 				},
 			},
 		},
 		{
 			name: "invalid quantity value",
 			setupMock: func() {
-				s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
+				s.expectFindAllEvents()
+				s.expectFindAllCategories()
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
 					SetVal([]interface{}{"not-a-number", "75"})
 			},
+			expectError:    true,
 			expectedResult: nil,
 		},
 	}
 
 	for _, tc := range tests {
 		s.Run(tc.name, func() {
-			// Reset categories before each test
+			// Reset the registry before each test
+			vars.SetEvents(nil)
 			vars.SetCategories(nil)
 
 			categoryCron := CategoryCron{
@@ -160,24 +250,37 @@ func (s *CategoryCronTestSuite) TestRefresh() {
 			tc.setupMock()
 
 			ctx := context.Background()
-			categoryCron.refresh(ctx)
+			err := categoryCron.Refresh(ctx)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+			}
 
 			if tc.expectedResult == nil {
 				s.Nil(vars.GetCategories())
 			} else {
 				s.Equal(tc.expectedResult, vars.GetCategories())
+
+				category, ok := vars.GetCategory(2)
+				s.True(ok)
+				s.Equal(tc.expectedResult[1], category)
+
+				event, ok := vars.GetEvent(category.EventId)
+				s.True(ok)
+				s.Equal("Asia/Jakarta", event.Location.String())
+				s.Equal("2024-01-01T10:00:00+07:00", event.SaleStartsAt.Format(time.RFC3339))
+				s.Equal("2024-02-01T10:00:00+07:00", category.SaleStartsAt.Format(time.RFC3339))
 			}
 
 			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
 		})
 	}
 }
 
 func (s *CategoryCronTestSuite) TestStart() {
-	// Setup mock for initial refresh
-	s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
-		SetVal([]interface{}{"50", "75"})
-
 	// Set a shorter refresh interval for testing
 	s.Cfg.Set("cron.category.refresh.interval", "200ms")
 
@@ -187,6 +290,12 @@ func (s *CategoryCronTestSuite) TestStart() {
 		Querier: s.Querier,
 	}
 
+	// Setup mock for the first refresh cycle
+	s.expectFindAllEvents()
+	s.expectFindAllCategories()
+	s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+		SetVal([]interface{}{"60", "85"})
+
 	// Create a context with cancel to stop the cron
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -195,46 +304,37 @@ func (s *CategoryCronTestSuite) TestStart() {
 		categoryCron.Start(ctx)
 	}()
 
-	// Wait a bit to ensure the initial refresh completes
+	// Start does not refresh until the first tick, Refresh loads the registry
 	time.Sleep(100 * time.Millisecond)
-
-	// Verify that categories were updated in the initial refresh
-	expected := []model.CategoryResponse{
-		{
-			Id:       1,
-			Name:     "Category 1",
-			Price:    100,
-			Quantity: 50,
-		},
-		{
-			Id:       2,
-			Name:     "Category 2",
-			Price:    200,
-			Quantity: 75,
-		},
-	}
-	s.Equal(expected, vars.GetCategories())
-
-	// Setup mock for the next refresh cycle
-	s.CacheMock.ExpectMGet("category:1:quantity", "category:2:quantity").
-		SetVal([]interface{}{"60", "85"})
+	s.Nil(vars.GetCategories())
 
 	// Wait for the next refresh cycle
 	time.Sleep(250 * time.Millisecond)
 
-	// Verify categories were updated again
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	s.Require().NoError(err)
+
+	// Verify categories were updated
 	updated := []model.CategoryResponse{
 		{
-			Id:       1,
-			Name:     "Category 1",
-			Price:    100,
-			Quantity: 60,
+			Id:           1,
+			EventId:      1,
+			Name:         "Category 1",
+			Price:        100,
+			Quantity:     60,
+			SeatingType:  constant.SeatingTypeReserved,
+			SaleStartsAt: eventSaleStartsAt.Time.In(jakarta),
+			SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 		},
 		{
-			Id:       2,
-			Name:     "Category 2",
-			Price:    200,
-			Quantity: 85,
+			Id:           2,
+			EventId:      1,
+			Name:         "Category 2",
+			Price:        200,
+			Quantity:     85,
+			SeatingType:  constant.SeatingTypeGeneralAdmission,
+			SaleStartsAt: categorySaleStartsAt.Time.In(jakarta),
+			SaleEndsAt:   eventSaleEndsAt.Time.In(jakarta),
 		},
 	}
 	s.Equal(updated, vars.GetCategories())
@@ -246,6 +346,7 @@ func (s *CategoryCronTestSuite) TestStart() {
 	time.Sleep(100 * time.Millisecond)
 
 	s.NoError(s.CacheMock.ExpectationsWereMet())
+	s.NoError(s.PgxMock.ExpectationsWereMet())
 }
 
 func (s *CategoryCronTestSuite) TestInitQuantityCache() {
@@ -257,7 +358,7 @@ func (s *CategoryCronTestSuite) TestInitQuantityCache() {
 		{
 			name: "database error",
 			setupMock: func() {
-				s.PgxMock.ExpectQuery("SELECT id, name, price, max_row, max_col, quantity FROM categories").
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
 					WillReturnError(fmt.Errorf("database error"))
 			},
 			wantErr: true,
@@ -265,24 +366,24 @@ func (s *CategoryCronTestSuite) TestInitQuantityCache() {
 		{
 			name: "no categories found",
 			setupMock: func() {
-				s.PgxMock.ExpectQuery("SELECT id, name, price, max_row, max_col, quantity FROM categories").
-					WillReturnRows(pgxmock.NewRows([]string{"id", "name", "price", "max_row", "max_col", "quantity"}))
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
+					WillReturnRows(pgxmock.NewRows(categoryColumns))
 			},
 			wantErr: false,
 		},
 		{
 			name: "redis pipeline error",
 			setupMock: func() {
-				rows := pgxmock.NewRows([]string{"id", "name", "price", "max_row", "max_col", "quantity"}).
-					AddRow(int16(1), "Category 1", 100.0, int16(10), int16(10), int32(50)).
-					AddRow(int16(2), "Category 2", 200.0, int16(5), int16(5), int32(75))
+				rows := pgxmock.NewRows(categoryColumns).
+					AddRow(int16(1), int16(1), "Category 1", int32(100), int32(10), int32(10), int32(50), sqlgen.SeatingTypeReserved, eventSaleStartsAt, eventSaleEndsAt).
+					AddRow(int16(2), int16(1), "Category 2", int32(200), int32(5), int32(5), int32(75), sqlgen.SeatingTypeReserved, categorySaleStartsAt, eventSaleEndsAt)
 
-				s.PgxMock.ExpectQuery("SELECT id, name, price, max_row, max_col, quantity FROM categories").
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
 					WillReturnRows(rows)
 
 				s.CacheMock.ExpectTxPipeline()
-				s.CacheMock.ExpectSetNX("category:1:quantity", int32(50), 0).SetVal(true)
-				s.CacheMock.ExpectSetNX("category:2:quantity", int32(75), 0).SetVal(true)
+				s.CacheMock.ExpectSetNX("event:1:category:1:quantity", int32(50), 0).SetVal(true)
+				s.CacheMock.ExpectSetNX("event:1:category:2:quantity", int32(75), 0).SetVal(true)
 				s.CacheMock.ExpectTxPipelineExec().SetErr(redis.ErrClosed)
 			},
 			wantErr: true,
@@ -290,16 +391,16 @@ func (s *CategoryCronTestSuite) TestInitQuantityCache() {
 		{
 			name: "success",
 			setupMock: func() {
-				rows := pgxmock.NewRows([]string{"id", "name", "price", "max_row", "max_col", "quantity"}).
-					AddRow(int16(1), "Category 1", 100.0, int16(10), int16(10), int32(50)).
-					AddRow(int16(2), "Category 2", 200.0, int16(5), int16(5), int32(75))
+				rows := pgxmock.NewRows(categoryColumns).
+					AddRow(int16(1), int16(1), "Category 1", int32(100), int32(10), int32(10), int32(50), sqlgen.SeatingTypeReserved, eventSaleStartsAt, eventSaleEndsAt).
+					AddRow(int16(2), int16(1), "Category 2", int32(200), int32(5), int32(5), int32(75), sqlgen.SeatingTypeReserved, categorySaleStartsAt, eventSaleEndsAt)
 
-				s.PgxMock.ExpectQuery("SELECT id, name, price, max_row, max_col, quantity FROM categories").
+				s.PgxMock.ExpectQuery(findAllCategoriesQuery).
 					WillReturnRows(rows)
 
 				s.CacheMock.ExpectTxPipeline()
-				s.CacheMock.ExpectSetNX("category:1:quantity", int32(50), 0).SetVal(true)
-				s.CacheMock.ExpectSetNX("category:2:quantity", int32(75), 0).SetVal(true)
+				s.CacheMock.ExpectSetNX("event:1:category:1:quantity", int32(50), 0).SetVal(true)
+				s.CacheMock.ExpectSetNX("event:1:category:2:quantity", int32(75), 0).SetVal(true)
 				s.CacheMock.ExpectTxPipelineExec()
 			},
 			wantErr: false,
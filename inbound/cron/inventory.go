@@ -0,0 +1,350 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// InventoryCron compares the stock counters in Redis and categories.quantity
+// against the stock derived from orders, capacity minus the quantity of
+// pending and completed orders and minus the tickets held by the waitlist,
+// either waiting for a claim to be issued or in an open claim.
+//
+// Orders reserve stock in Redis before their row is committed and release it
+// after, so a category with orders in flight shows a short lived drift. A
+// drifted category is only repaired once its figures have not changed for
+// cron.inventory.reconcile.idle, longer than any order can stay in flight.
+// Repair is off by default for the cron, enable it with
+// cron.inventory.reconcile.repair.
+type InventoryCron struct {
+	Cfg       *viper.Viper
+	Cache     *redis.Client
+	Querier   *sqlgen.Queries
+	Publisher jetstream.Publisher
+
+	// InstanceId identifies the lease holder, it must be unique per process.
+	InstanceId string
+	TimeNow    func() time.Time
+}
+
+func (in InventoryCron) Start(ctx context.Context) {
+	reconcileTicker := time.NewTicker(in.Cfg.GetDuration("cron.inventory.reconcile.interval"))
+	defer reconcileTicker.Stop()
+
+	slog.Info("inventory cron started", slog.String("instance_id", in.InstanceId))
+
+	for {
+		select {
+		case <-reconcileTicker.C:
+			in.run(ctx)
+		case <-ctx.Done():
+			err := releaseLease(context.Background(), in.Cache, constant.InventoryReconcileLease, in.InstanceId)
+			if err != nil {
+				slog.Error("failed to release inventory reconcile lease", slog.Any(constant.LogFieldErr, err))
+			}
+			slog.Info("inventory cron stopped")
+			return
+		}
+	}
+}
+
+// run reconciles once per tick. Only the instance holding the lease runs, so
+// a repair is never applied twice.
+func (in InventoryCron) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, in.Cfg.GetDuration("cron.inventory.reconcile.timeout"))
+	defer cancel()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	leased, err := acquireLease(ctx, in.Cache, constant.InventoryReconcileLease, in.InstanceId, in.Cfg.GetDuration("cron.inventory.reconcile.lease_ttl"))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to acquire inventory reconcile lease", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return
+	}
+
+	if !leased {
+		slog.DebugContext(ctx, "inventory reconcile lease held by another instance", traceIdAttr)
+		return
+	}
+
+	in.Reconcile(ctx, in.Cfg.GetBool("cron.inventory.reconcile.repair"))
+}
+
+// Reconcile reports the drift of every category. With repair the drift of the
+// categories idle since the previous reconciles is corrected, the Redis counter
+// is moved by the drift and categories.quantity is overwritten with the
+// expected stock.
+func (in InventoryCron) Reconcile(ctx context.Context, repair bool) (model.InventoryReport, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	report := model.InventoryReport{
+		CheckedAt:  in.TimeNow().Format(time.RFC3339),
+		Categories: []model.InventoryReportEntry{},
+	}
+
+	inventories, err := in.Querier.FindCategoryInventory(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find category inventory", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return report, err
+	}
+
+	if len(inventories) == 0 {
+		return report, nil
+	}
+
+	quantityCacheKeys := make([]string, 0, len(inventories))
+	for _, inventory := range inventories {
+		quantityCacheKeys = append(quantityCacheKeys, fmt.Sprintf(constant.EachCategoryQuantityKey, inventory.EventID, inventory.ID))
+	}
+
+	quantities, err := in.Cache.MGet(ctx, quantityCacheKeys...).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get quantities from cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return report, err
+	}
+
+	waitlisted, err := in.waitlisted(ctx, inventories)
+	if err != nil {
+		return report, err
+	}
+
+	snapshots, err := in.Cache.HGetAll(ctx, constant.InventorySnapshotKey).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get inventory snapshots from cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return report, err
+	}
+
+	now := in.TimeNow()
+	entries := make([]model.InventoryReportEntry, 0, len(inventories))
+	changed := make([]interface{}, 0)
+
+	for i, inventory := range inventories {
+		// A missing counter is reported as zero, repairing it sets it from scratch.
+		var cached int64
+		if quantity, ok := quantities[i].(string); ok {
+			cached, err = strconv.ParseInt(quantity, 10, 64)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to convert quantity to int", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return report, err
+			}
+		}
+
+		expected := inventory.Capacity - inventory.Reserved - waitlisted[inventory.ID]
+		entry := model.InventoryReportEntry{
+			EventId:    inventory.EventID,
+			CategoryId: inventory.ID,
+			Capacity:   inventory.Capacity,
+			Reserved:   inventory.Reserved,
+			Waitlisted: waitlisted[inventory.ID],
+			Expected:   expected,
+			Cache:      cached,
+			CacheDrift: cached - int64(expected),
+			Db:         inventory.Quantity,
+			DbDrift:    inventory.Quantity - expected,
+		}
+
+		snapshot := model.InventorySnapshot{
+			Cache:      entry.Cache,
+			Reserved:   entry.Reserved,
+			Waitlisted: entry.Waitlisted,
+			Db:         entry.Db,
+			SeenAt:     now.Format(time.RFC3339),
+		}
+
+		field := strconv.Itoa(int(inventory.ID))
+		var previous model.InventorySnapshot
+		if data, ok := snapshots[field]; ok {
+			if err := json.Unmarshal([]byte(data), &previous); err != nil {
+				slog.ErrorContext(ctx, "failed to unmarshal inventory snapshot", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return report, err
+			}
+		}
+
+		if previous.SeenAt != "" && previous.Cache == snapshot.Cache && previous.Reserved == snapshot.Reserved &&
+			previous.Waitlisted == snapshot.Waitlisted && previous.Db == snapshot.Db {
+			snapshot.SeenAt = previous.SeenAt
+		} else {
+			data, err := json.Marshal(snapshot)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to marshal inventory snapshot", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return report, err
+			}
+			changed = append(changed, field, string(data))
+		}
+		entry.IdleSince = snapshot.SeenAt
+
+		entries = append(entries, entry)
+	}
+
+	// The snapshots are saved before any repair, a repair that fails halfway is
+	// only retried once the figures it left behind are idle again.
+	if len(changed) > 0 {
+		err = in.Cache.HSet(ctx, constant.InventorySnapshotKey, changed...).Err()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to save inventory snapshots to cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return report, err
+		}
+	}
+
+	idle := in.Cfg.GetDuration("cron.inventory.reconcile.idle")
+
+	for _, entry := range entries {
+		if entry.CacheDrift != 0 || entry.DbDrift != 0 {
+			report.Drifted++
+			slog.WarnContext(ctx, "category inventory drift",
+				slog.Int("category_id", int(entry.CategoryId)),
+				slog.Int64("cache_drift", entry.CacheDrift),
+				slog.Int("db_drift", int(entry.DbDrift)),
+				traceIdAttr,
+			)
+
+			idleSince, err := time.Parse(time.RFC3339, entry.IdleSince)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to parse inventory snapshot time", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return report, err
+			}
+
+			if repair && now.Sub(idleSince) < idle {
+				slog.InfoContext(ctx, "category not idle, repair skipped", slog.Int("category_id", int(entry.CategoryId)), slog.String("idle_since", entry.IdleSince), traceIdAttr)
+			} else if repair {
+				err = in.repair(ctx, entry)
+				if err != nil {
+					return report, err
+				}
+				entry.Repaired = true
+			}
+		}
+
+		report.Categories = append(report.Categories, entry)
+	}
+
+	slog.InfoContext(ctx, "inventory reconciled", slog.Int("categories", len(report.Categories)), slog.Int("drifted", report.Drifted), slog.Bool("repair", repair), traceIdAttr)
+
+	return report, nil
+}
+
+// waitlisted counts the tickets held by the waitlist of every category, the
+// entries waiting for their claim to be issued and the open claims.
+func (in InventoryCron) waitlisted(ctx context.Context, inventories []sqlgen.FindCategoryInventoryRow) (map[int16]int32, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	waitlisted := make(map[int16]int32, len(inventories))
+
+	pendingCmds := make([]*redis.IntCmd, 0, len(inventories))
+	_, err := in.Cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, inventory := range inventories {
+			pendingCmds = append(pendingCmds, pipe.LLen(ctx, fmt.Sprintf(constant.WaitlistPendingKey, inventory.EventID, inventory.ID)))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get pending waitlist entries from cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, err
+	}
+
+	for i, inventory := range inventories {
+		waitlisted[inventory.ID] += int32(pendingCmds[i].Val())
+	}
+
+	tokenHashes, err := in.Cache.ZRange(ctx, constant.WaitlistClaimsKey, 0, -1).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find waitlist claims", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, err
+	}
+
+	if len(tokenHashes) == 0 {
+		return waitlisted, nil
+	}
+
+	claimKeys := make([]string, 0, len(tokenHashes))
+	for _, tokenHash := range tokenHashes {
+		claimKeys = append(claimKeys, fmt.Sprintf(constant.WaitlistClaimKey, tokenHash))
+	}
+
+	claims, err := in.Cache.MGet(ctx, claimKeys...).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get waitlist claims from cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, err
+	}
+
+	// A claim without its record holds nothing, the claim expiry cron drops
+	// it from the index.
+	for _, data := range claims {
+		data, ok := data.(string)
+		if !ok {
+			continue
+		}
+
+		var claim model.WaitlistClaim
+		if err := json.Unmarshal([]byte(data), &claim); err != nil {
+			slog.ErrorContext(ctx, "failed to unmarshal waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return nil, err
+		}
+		waitlisted[claim.CategoryId]++
+	}
+
+	return waitlisted, nil
+}
+
+// repair moves the counter by the drift. Missing stock is released through the
+// waitlist like any other release, so buyers waiting for the category are
+// served before it goes back on sale, and categories.quantity is set to what
+// went back on sale.
+func (in InventoryCron) repair(ctx context.Context, entry model.InventoryReportEntry) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	quantity := entry.Expected
+
+	switch {
+	case entry.CacheDrift < 0:
+		waitlisted, err := common.Restock(ctx, in.Cache, entry.EventId, entry.CategoryId, int32(-entry.CacheDrift))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to repair category quantity cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+
+		if waitlisted > 0 {
+			quantity -= waitlisted
+
+			err = common.PublishMessage(ctx, in.Publisher, constant.SubjectWaitlistRestock, model.WaitlistRestockEventMessage{
+				EventId:    entry.EventId,
+				CategoryId: entry.CategoryId,
+				Quantity:   waitlisted,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to publish waitlist restock message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return err
+			}
+		}
+	case entry.CacheDrift > 0:
+		err := in.Cache.IncrBy(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, entry.EventId, entry.CategoryId), -entry.CacheDrift).Err()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to repair category quantity cache", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+	}
+
+	if entry.Db != quantity {
+		err := in.Querier.UpdateCategoryQuantity(ctx, sqlgen.UpdateCategoryQuantityParams{
+			ID:       entry.CategoryId,
+			Quantity: quantity,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to repair category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"fmt"
+	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const (
+	categoryInventoryQuery = `SELECT c.id, c.event_id, c.quantity, (.+) FROM categories c LEFT JOIN orders o ON o.category_id = c.id AND o.status IN \('pending', 'completed'\) GROUP BY c.id ORDER BY c.id`
+	updateCategoryQuery    = `UPDATE categories SET quantity = \$2 WHERE id = \$1`
+)
+
+var categoryInventoryColumns = []string{"id", "event_id", "quantity", "capacity", "reserved"}
+
+type InventoryCronTestSuite struct {
+	suite.Suite
+
+	Cfg *viper.Viper
+
+	Querier *sqlgen.Queries
+	PgxMock pgxmock.PgxPoolIface
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
+	Publisher *jetsteamMock.MockPublisher
+}
+
+func (s *InventoryCronTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.Querier = sqlgen.New(pool)
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
+
+	s.Cfg = viper.New()
+	s.Cfg.Set("cron.inventory.reconcile.interval", "1m")
+	s.Cfg.Set("cron.inventory.reconcile.timeout", "20s")
+	s.Cfg.Set("cron.inventory.reconcile.lease_ttl", "2m")
+	s.Cfg.Set("cron.inventory.reconcile.idle", "2m")
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *InventoryCronTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestInventoryCronTestSuite(t *testing.T) {
+	suite.Run(t, new(InventoryCronTestSuite))
+}
+
+func (s *InventoryCronTestSuite) newInventoryCron() InventoryCron {
+	return InventoryCron{
+		Cfg:        s.Cfg,
+		Cache:      s.Cache,
+		Querier:    s.Querier,
+		Publisher:  s.Publisher,
+		InstanceId: "instance-1",
+		TimeNow: func() time.Time {
+			return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func (s *InventoryCronTestSuite) TestReconcile() {
+	inventoryRows := func() *pgxmock.Rows {
+		return pgxmock.NewRows(categoryInventoryColumns).
+			AddRow(int16(1), int16(1), int32(497), int32(500), int32(3)).
+			AddRow(int16(2), int16(1), int32(990), int32(1000), int32(4))
+	}
+
+	// expectWaitlisted expects the pending waitlist entries of both
+	// categories and the open claims, the claim is for category 2.
+	expectWaitlisted := func(pending1, pending2 int64, claims ...string) {
+		s.CacheMock.ExpectLLen(fmt.Sprintf(constant.WaitlistPendingKey, int16(1), int16(1))).SetVal(pending1)
+		s.CacheMock.ExpectLLen(fmt.Sprintf(constant.WaitlistPendingKey, int16(1), int16(2))).SetVal(pending2)
+		s.CacheMock.ExpectZRange(constant.WaitlistClaimsKey, 0, -1).SetVal(claims)
+		if len(claims) > 0 {
+			claimKeys := make([]string, 0, len(claims))
+			values := make([]interface{}, 0, len(claims))
+			for _, claim := range claims {
+				claimKeys = append(claimKeys, fmt.Sprintf(constant.WaitlistClaimKey, claim))
+				values = append(values, `{"event_id":1,"category_id":2,"name":"John","email":"john@example.com","expired_at":"2023-01-01T00:10:00Z"}`)
+			}
+			s.CacheMock.ExpectMGet(claimKeys...).SetVal(values)
+		}
+	}
+
+	const (
+		now       = "2023-01-01T00:00:00Z"
+		idleSince = "2022-12-31T23:57:00Z"
+	)
+
+	snapshot := func(cache int64, reserved, waitlisted, db int32, seenAt string) string {
+		return fmt.Sprintf(`{"cache":%d,"reserved":%d,"waitlisted":%d,"db":%d,"seen_at":"%s"}`, cache, reserved, waitlisted, db, seenAt)
+	}
+
+	// expectSnapshots expects the snapshots of the previous reconciles and
+	// the fields saved because their figures changed.
+	expectSnapshots := func(previous map[string]string, changed ...interface{}) {
+		s.CacheMock.ExpectHGetAll(constant.InventorySnapshotKey).SetVal(previous)
+		if len(changed) > 0 {
+			s.CacheMock.ExpectHSet(constant.InventorySnapshotKey, changed...).SetVal(int64(len(changed) / 2))
+		}
+	}
+
+	tests := []struct {
+		name           string
+		repair         bool
+		setupMock      func()
+		expectedReport model.InventoryReport
+		expectError    bool
+	}{
+		{
+			name: "find inventory error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "cache error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetErr(redis.ErrClosed)
+			},
+			expectError: true,
+		},
+		{
+			name: "waitlist claims error",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"497", "992"})
+				s.CacheMock.ExpectLLen(fmt.Sprintf(constant.WaitlistPendingKey, int16(1), int16(1))).SetVal(0)
+				s.CacheMock.ExpectLLen(fmt.Sprintf(constant.WaitlistPendingKey, int16(1), int16(2))).SetVal(0)
+				s.CacheMock.ExpectZRange(constant.WaitlistClaimsKey, 0, -1).SetErr(redis.ErrClosed)
+			},
+			expectError: true,
+		},
+		{
+			name: "report only",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"497", "992"})
+				expectWaitlisted(0, 0)
+				expectSnapshots(map[string]string{}, "1", snapshot(497, 3, 0, 497, now), "2", snapshot(992, 4, 0, 990, now))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   1,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 497, Db: 497, IdleSince: now},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Expected: 996, Cache: 992, CacheDrift: -4, Db: 990, DbDrift: -6, IdleSince: now},
+				},
+			},
+		},
+		{
+			name: "count waitlist holdings as reserved",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"497", "992"})
+				expectWaitlisted(0, 1, "hash-1", "hash-2")
+				expectSnapshots(map[string]string{}, "1", snapshot(497, 3, 0, 497, now), "2", snapshot(992, 4, 3, 990, now))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   1,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 497, Db: 497, IdleSince: now},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Waitlisted: 3, Expected: 993, Cache: 992, CacheDrift: -1, Db: 990, DbDrift: -3, IdleSince: now},
+				},
+			},
+		},
+		{
+			name:   "repair through the waitlist and missing counter",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{nil, "992"})
+				expectWaitlisted(0, 1)
+				expectSnapshots(map[string]string{"1": snapshot(0, 3, 0, 497, idleSince), "2": snapshot(992, 4, 1, 990, idleSince)})
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(497)).SetVal(int64(2))
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					[]byte(`{"event_id":1,"category_id":1,"quantity":2}`),
+				).Return(nil, nil)
+				s.PgxMock.ExpectExec(updateCategoryQuery).
+					WithArgs(int16(1), int32(495)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 2), int32(3)).SetVal(int64(0))
+				s.PgxMock.ExpectExec(updateCategoryQuery).
+					WithArgs(int16(2), int32(995)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   2,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 0, CacheDrift: -497, Db: 497, IdleSince: idleSince, Repaired: true},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Waitlisted: 1, Expected: 995, Cache: 992, CacheDrift: -3, Db: 990, DbDrift: -5, IdleSince: idleSince, Repaired: true},
+				},
+			},
+		},
+		{
+			name:   "repair surplus counter",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"500", "1000"})
+				expectWaitlisted(0, 0, "hash-1")
+				expectSnapshots(map[string]string{"1": snapshot(500, 3, 0, 497, idleSince), "2": snapshot(1000, 4, 1, 990, idleSince)})
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(-3)).SetVal(497)
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(2)), int64(-5)).SetVal(995)
+				s.PgxMock.ExpectExec(updateCategoryQuery).
+					WithArgs(int16(2), int32(995)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   2,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 500, CacheDrift: 3, Db: 497, IdleSince: idleSince, Repaired: true},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Waitlisted: 1, Expected: 995, Cache: 1000, CacheDrift: 5, Db: 990, DbDrift: -5, IdleSince: idleSince, Repaired: true},
+				},
+			},
+		},
+		{
+			name:   "skip repair until idle",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"496", "992"})
+				expectWaitlisted(0, 0)
+				expectSnapshots(map[string]string{"1": snapshot(496, 3, 0, 497, "2022-12-31T23:59:00Z")}, "2", snapshot(992, 4, 0, 990, now))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   2,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 496, CacheDrift: -1, Db: 497, IdleSince: "2022-12-31T23:59:00Z"},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Expected: 996, Cache: 992, CacheDrift: -4, Db: 990, DbDrift: -6, IdleSince: now},
+				},
+			},
+		},
+		{
+			name:   "skip repair when figures changed",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"497", "992"})
+				expectWaitlisted(0, 0)
+				expectSnapshots(map[string]string{"1": snapshot(497, 3, 0, 497, idleSince), "2": snapshot(993, 4, 0, 990, idleSince)}, "2", snapshot(992, 4, 0, 990, now))
+			},
+			expectedReport: model.InventoryReport{
+				CheckedAt: "2023-01-01T00:00:00Z",
+				Drifted:   1,
+				Categories: []model.InventoryReportEntry{
+					{EventId: 1, CategoryId: 1, Capacity: 500, Reserved: 3, Expected: 497, Cache: 497, Db: 497, IdleSince: idleSince},
+					{EventId: 1, CategoryId: 2, Capacity: 1000, Reserved: 4, Expected: 996, Cache: 992, CacheDrift: -4, Db: 990, DbDrift: -6, IdleSince: now},
+				},
+			},
+		},
+		{
+			name:   "repair publish error",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"496", "996"})
+				expectWaitlisted(0, 0)
+				expectSnapshots(map[string]string{"1": snapshot(496, 3, 0, 497, idleSince)}, "2", snapshot(996, 4, 0, 990, now))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetVal(int64(1))
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			expectError: true,
+		},
+		{
+			name:   "repair database error",
+			repair: true,
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(categoryInventoryQuery).
+					WillReturnRows(inventoryRows())
+				s.CacheMock.ExpectMGet("event:1:category:1:quantity", "event:1:category:2:quantity").
+					SetVal([]interface{}{"497", "996"})
+				expectWaitlisted(0, 0)
+				expectSnapshots(map[string]string{"2": snapshot(996, 4, 0, 990, idleSince)}, "1", snapshot(497, 3, 0, 497, now))
+				s.PgxMock.ExpectExec(updateCategoryQuery).
+					WithArgs(int16(2), int32(996)).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupMock()
+
+			report, err := s.newInventoryCron().Reconcile(context.Background(), tc.repair)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+				s.Equal(tc.expectedReport, report)
+			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *InventoryCronTestSuite) TestRun() {
+	s.Run("skip when lease is held by another instance", func() {
+		s.CacheMock.ExpectSetNX(constant.InventoryReconcileLease, "instance-1", 2*time.Minute).
+			SetVal(false)
+		s.CacheMock.ExpectEvalSha(renewLeaseScript.Hash(), []string{constant.InventoryReconcileLease}, "instance-1", int64(120000)).
+			SetVal(int64(0))
+
+		s.newInventoryCron().run(context.Background())
+
+		s.NoError(s.CacheMock.ExpectationsWereMet())
+		s.NoError(s.PgxMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// renewLeaseScript extends the lease only when it is still held by the caller.
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript deletes the lease only when it is still held by the caller.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// acquireLease takes the lease under key for holder, or renews it when holder
+// already has it. Crons that must run on a single instance sweep only while
+// they hold their lease.
+func acquireLease(ctx context.Context, cache *redis.Client, key, holder string, ttl time.Duration) (bool, error) {
+	acquired, err := cache.SetNX(ctx, key, holder, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if acquired {
+		return true, nil
+	}
+
+	renewed, err := renewLeaseScript.Run(ctx, cache, []string{key}, holder, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return renewed == 1, nil
+}
+
+func releaseLease(ctx context.Context, cache *redis.Client, key, holder string) error {
+	return releaseLeaseScript.Run(ctx, cache, []string{key}, holder).Err()
+}
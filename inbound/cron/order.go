@@ -0,0 +1,192 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"concert-ticket/outbound/payment"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"golang.org/x/text/message"
+	"log/slog"
+	"time"
+)
+
+type OrderExpiryCron struct {
+	Cfg                  *viper.Viper
+	Cache                *redis.Client
+	Querier              *sqlgen.Queries
+	Publisher            jetstream.Publisher
+	PaymentGateway       payment.PaymentGateway
+	IdrCurrencyFormatter *message.Printer
+
+	// InstanceId identifies the lease holder, it must be unique per process.
+	InstanceId string
+	TimeNow    func() time.Time
+}
+
+func (in OrderExpiryCron) Start(ctx context.Context) {
+	sweepTicker := time.NewTicker(in.Cfg.GetDuration("cron.order.expiry.interval"))
+	defer sweepTicker.Stop()
+
+	slog.Info("order expiry cron started", slog.String("instance_id", in.InstanceId))
+
+	for {
+		select {
+		case <-sweepTicker.C:
+			in.sweep(ctx)
+		case <-ctx.Done():
+			in.releaseLease(context.Background())
+			slog.Info("order expiry cron stopped")
+			return
+		}
+	}
+}
+
+// sweep cancels expired orders batch by batch until none are left. Only the
+// instance holding the lease sweeps, the others skip the tick.
+func (in OrderExpiryCron) sweep(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, in.Cfg.GetDuration("cron.order.expiry.timeout"))
+	defer cancel()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	leased, err := in.acquireLease(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to acquire order expiry lease", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return
+	}
+
+	if !leased {
+		slog.DebugContext(ctx, "order expiry lease held by another instance", traceIdAttr)
+		return
+	}
+
+	batchSize := in.Cfg.GetInt("order.bulk_cancel_size")
+	for ctx.Err() == nil {
+		cancelled, err := in.cancelExpired(ctx)
+		if err != nil || cancelled < batchSize {
+			break
+		}
+	}
+
+	in.releaseStaleSeatHolds(ctx)
+}
+
+// releaseStaleSeatHolds frees the seats held by orders that will never be
+// paid, including the ones cancelled by the sweep that just ran, and the seats
+// of holds that expired without being ordered.
+func (in OrderExpiryCron) releaseStaleSeatHolds(ctx context.Context) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	released, err := in.Querier.ReleaseStaleSeatHolds(ctx, pgtype.Timestamp{Time: in.TimeNow(), Valid: true})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to release stale seat holds", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return
+	}
+
+	if released > 0 {
+		slog.InfoContext(ctx, "stale seat holds released", traceIdAttr, slog.Int64("released", released))
+	}
+}
+
+func (in OrderExpiryCron) acquireLease(ctx context.Context) (bool, error) {
+	return acquireLease(ctx, in.Cache, constant.OrderExpiryLeaseKey, in.InstanceId, in.Cfg.GetDuration("cron.order.expiry.lease_ttl"))
+}
+
+func (in OrderExpiryCron) releaseLease(ctx context.Context) {
+	err := releaseLease(ctx, in.Cache, constant.OrderExpiryLeaseKey, in.InstanceId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to release order expiry lease", slog.Any(constant.LogFieldErr, err))
+	}
+}
+
+func (in OrderExpiryCron) cancelExpired(ctx context.Context) (int, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	cancelableOrders, err := in.Querier.BulkCancelOrders(ctx, sqlgen.BulkCancelOrdersParams{
+		Limit:     in.Cfg.GetInt32("order.bulk_cancel_size"),
+		UpdatedAt: pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find cancelable orders", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return 0, err
+	}
+
+	if len(cancelableOrders) == 0 {
+		slog.DebugContext(ctx, "no cancelable orders", traceIdAttr)
+		return 0, nil
+	}
+
+	categoryIdValMap := make(map[int16]int32)
+	eventIdByCategoryId := make(map[int16]int16)
+	for _, order := range cancelableOrders {
+		categoryIdValMap[order.CategoryID] += order.Quantity
+		eventIdByCategoryId[order.CategoryID] = order.EventID
+	}
+
+	waitlistedByCategoryId := make(map[int16]int32)
+	incrementPayload := make([]model.IncrementCategoryQuantityEventMessage, 0, len(categoryIdValMap))
+	for categoryId, val := range categoryIdValMap {
+		waitlisted, err := common.Restock(ctx, in.Cache, eventIdByCategoryId[categoryId], categoryId, val)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to restock category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return 0, err
+		}
+
+		if waitlisted > 0 {
+			waitlistedByCategoryId[categoryId] = waitlisted
+		}
+
+		if val > waitlisted {
+			incrementPayload = append(incrementPayload, model.IncrementCategoryQuantityEventMessage{
+				ID:       categoryId,
+				Quantity: val - waitlisted,
+			})
+		}
+	}
+
+	for _, order := range cancelableOrders {
+		payment.CancelOpenCharge(ctx, in.PaymentGateway, order.PaymentCode)
+	}
+
+	if len(incrementPayload) > 0 {
+		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectBulkIncrementCategoryQuantity, incrementPayload)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish bulk increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return 0, err
+		}
+	}
+
+	for categoryId, waitlisted := range waitlistedByCategoryId {
+		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectWaitlistRestock, model.WaitlistRestockEventMessage{
+			EventId:    eventIdByCategoryId[categoryId],
+			CategoryId: categoryId,
+			Quantity:   waitlisted,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish waitlist restock message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return 0, err
+		}
+	}
+
+	for _, order := range cancelableOrders {
+		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
+			To:      order.Email,
+			Subject: "Order Cancellation",
+			Body:    common.BuildOrderCancellationEmailBody(in.IdrCurrencyFormatter, order.ID, order.CategoryID, order.Quantity, order.Name, order.TotalAmount),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish cancel order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return 0, err
+		}
+	}
+
+	slog.InfoContext(ctx, "cancel expired orders success", slog.Any(constant.LogFieldResponse, len(cancelableOrders)), traceIdAttr)
+
+	return len(cancelableOrders), nil
+}
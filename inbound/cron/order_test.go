@@ -0,0 +1,393 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/outbound/payment"
+	paymentMock "concert-ticket/outbound/payment/mocks"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"fmt"
+	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const bulkCancelOrdersQuery = `UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 ORDER BY expired_at LIMIT \$1\) RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount`
+
+type OrderExpiryCronTestSuite struct {
+	suite.Suite
+
+	Cfg *viper.Viper
+
+	Querier *sqlgen.Queries
+	PgxMock pgxmock.PgxPoolIface
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
+	Publisher *jetsteamMock.MockPublisher
+	Gateway   *paymentMock.MockPaymentGateway
+}
+
+func (s *OrderExpiryCronTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.Querier = sqlgen.New(pool)
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
+	s.Gateway = paymentMock.NewMockPaymentGateway(ctrl)
+
+	s.Cfg = viper.New()
+	s.Cfg.Set("order.bulk_cancel_size", 10)
+	s.Cfg.Set("cron.order.expiry.interval", "5s")
+	s.Cfg.Set("cron.order.expiry.timeout", "20s")
+	s.Cfg.Set("cron.order.expiry.lease_ttl", "30s")
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *OrderExpiryCronTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestOrderExpiryCronTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderExpiryCronTestSuite))
+}
+
+func (s *OrderExpiryCronTestSuite) newOrderExpiryCron() OrderExpiryCron {
+	return OrderExpiryCron{
+		Cfg:                  s.Cfg,
+		Cache:                s.Cache,
+		Querier:              s.Querier,
+		Publisher:            s.Publisher,
+		PaymentGateway:       s.Gateway,
+		IdrCurrencyFormatter: message.NewPrinter(language.Indonesian),
+		InstanceId:           "instance-1",
+		TimeNow: func() time.Time {
+			return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func (s *OrderExpiryCronTestSuite) TestCancelExpired() {
+	tests := []struct {
+		name      string
+		setupMock func(time.Time)
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name: "database error",
+			setupMock: func(fixedTime time.Time) {
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "no cancelable orders",
+			setupMock: func(fixedTime time.Time) {
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}))
+			},
+			wantCount: 0,
+		},
+		{
+			name: "restock error",
+			setupMock: func(fixedTime time.Time) {
+				rows := pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+					AddRow(int32(1), int16(1), int16(1), int32(1), "John Doe", "john@example.com", "PAY1", int64(11_000_000))
+
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(rows)
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetErr(redis.ErrClosed)
+			},
+			wantErr: true,
+		},
+		{
+			name: "publish increment category error",
+			setupMock: func(fixedTime time.Time) {
+				rows := pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+					AddRow(int32(1), int16(1), int16(1), int32(1), "John Doe", "john@example.com", "PAY1", int64(11_000_000))
+
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(rows)
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetVal(int64(0))
+				s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY1").Return(nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "publish email error",
+			setupMock: func(fixedTime time.Time) {
+				rows := pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+					AddRow(int32(1), int16(1), int16(1), int32(1), "John Doe", "john@example.com", "PAY1", int64(11_000_000))
+
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(rows)
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetVal(int64(0))
+				s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY1").Return(nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			setupMock: func(fixedTime time.Time) {
+				rows := pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+					AddRow(int32(1), int16(1), int16(1), int32(1), "John Doe", "john@example.com", "PAY1", int64(11_000_000))
+
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(rows)
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetVal(int64(0))
+				s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY1").Return(nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			wantCount: 1,
+		},
+		{
+			name: "success hands restocked tickets to the waitlist first",
+			setupMock: func(fixedTime time.Time) {
+				rows := pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+					AddRow(int32(1), int16(1), int16(1), int32(3), "John Doe", "john@example.com", "PAY1", int64(33_000_000)).
+					AddRow(int32(2), int16(1), int16(1), int32(1), "Jane Doe", "jane@example.com", "PAY2", int64(11_000_000))
+
+				s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(rows)
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(4)).SetVal(int64(1))
+				s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY1").Return(payment.ErrChargeNotFound)
+				s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY2").Return(fmt.Errorf("gateway error"))
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					[]byte(`[{"id":1,"quantity":3}]`),
+				).Return(nil, nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					[]byte(`{"event_id":1,"category_id":1,"quantity":1}`),
+				).Return(nil, nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil).Times(2)
+			},
+			wantCount: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			orderExpiryCron := s.newOrderExpiryCron()
+
+			fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			tc.setupMock(fixedTime)
+
+			count, err := orderExpiryCron.cancelExpired(context.Background())
+
+			if tc.wantErr {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+				s.Equal(tc.wantCount, count)
+			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderExpiryCronTestSuite) TestAcquireLease() {
+	tests := []struct {
+		name       string
+		setupMock  func()
+		wantLeased bool
+		wantErr    bool
+	}{
+		{
+			name: "setnx error",
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+					SetErr(redis.ErrClosed)
+			},
+			wantErr: true,
+		},
+		{
+			name: "lease acquired",
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+					SetVal(true)
+			},
+			wantLeased: true,
+		},
+		{
+			name: "lease renewed by owner",
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+					SetVal(false)
+				s.CacheMock.ExpectEvalSha(renewLeaseScript.Hash(), []string{constant.OrderExpiryLeaseKey}, "instance-1", int64(30000)).
+					SetVal(int64(1))
+			},
+			wantLeased: true,
+		},
+		{
+			name: "lease held by another instance",
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+					SetVal(false)
+				s.CacheMock.ExpectEvalSha(renewLeaseScript.Hash(), []string{constant.OrderExpiryLeaseKey}, "instance-1", int64(30000)).
+					SetVal(int64(0))
+			},
+			wantLeased: false,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			orderExpiryCron := s.newOrderExpiryCron()
+
+			tc.setupMock()
+
+			leased, err := orderExpiryCron.acquireLease(context.Background())
+
+			if tc.wantErr {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+				s.Equal(tc.wantLeased, leased)
+			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderExpiryCronTestSuite) TestSweep() {
+	s.Run("skip when lease is held by another instance", func() {
+		orderExpiryCron := s.newOrderExpiryCron()
+
+		s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+			SetVal(false)
+		s.CacheMock.ExpectEvalSha(renewLeaseScript.Hash(), []string{constant.OrderExpiryLeaseKey}, "instance-1", int64(30000)).
+			SetVal(int64(0))
+
+		orderExpiryCron.sweep(context.Background())
+
+		s.NoError(s.CacheMock.ExpectationsWereMet())
+		s.NoError(s.PgxMock.ExpectationsWereMet())
+	})
+
+	s.Run("drain until batch is not full", func() {
+		s.Cfg.Set("order.bulk_cancel_size", 1)
+		defer s.Cfg.Set("order.bulk_cancel_size", 10)
+
+		orderExpiryCron := s.newOrderExpiryCron()
+		fixedTime := orderExpiryCron.TimeNow()
+
+		s.CacheMock.ExpectSetNX(constant.OrderExpiryLeaseKey, "instance-1", 30*time.Second).
+			SetVal(true)
+
+		s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+			WithArgs(int32(1), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+				AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "PAY1", int64(22_000_000)))
+
+		s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(0))
+		s.Gateway.EXPECT().CancelCharge(gomock.Any(), "PAY1").Return(nil)
+
+		s.Publisher.EXPECT().Publish(
+			gomock.Any(),
+			constant.SubjectBulkIncrementCategoryQuantity,
+			[]byte(`[{"id":1,"quantity":2}]`),
+		).Return(nil, nil)
+
+		s.Publisher.EXPECT().Publish(
+			gomock.Any(),
+			constant.SubjectSendEmail,
+			gomock.Any(),
+		).Return(nil, nil)
+
+		s.PgxMock.ExpectQuery(bulkCancelOrdersQuery).
+			WithArgs(int32(1), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}))
+
+		s.PgxMock.ExpectExec(`UPDATE seats SET status = 'available', order_id = NULL, hold_id = NULL, held_until = NULL, updated_at = NOW\(\) WHERE status = 'held'`).
+			WithArgs(pgtype.Timestamp{Time: fixedTime, Valid: true}).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+
+		orderExpiryCron.sweep(context.Background())
+
+		s.NoError(s.CacheMock.ExpectationsWereMet())
+		s.NoError(s.PgxMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,123 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/common/contract"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/spf13/viper"
+	"log/slog"
+	"time"
+)
+
+type OutboxCron struct {
+	Cfg       *viper.Viper
+	Db        contract.DbConn
+	Querier   *sqlgen.Queries
+	Publisher jetstream.Publisher
+}
+
+func (in OutboxCron) Start(ctx context.Context) {
+	relayTicker := time.NewTicker(in.Cfg.GetDuration("cron.outbox.relay.interval"))
+	defer relayTicker.Stop()
+
+	batchSize := in.Cfg.GetInt("cron.outbox.relay.batch_size")
+
+	slog.Info("outbox cron started")
+
+	for {
+		select {
+		case <-relayTicker.C:
+			// Keep draining while full batches are relayed
+			for ctx.Err() == nil {
+				sent, err := in.relay(ctx)
+				if err != nil || sent < batchSize {
+					break
+				}
+			}
+		case <-ctx.Done():
+			slog.Info("outbox cron stopped")
+			return
+		}
+	}
+}
+
+// relay publishes one batch of pending outbox rows and marks them sent. Rows are
+// locked with SKIP LOCKED, so several relays can run side by side.
+func (in OutboxCron) relay(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, in.Cfg.GetDuration("cron.outbox.relay.timeout"))
+	defer cancel()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return 0, err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	withTx := in.Querier.WithTx(tx)
+
+	messages, err := withTx.FindPendingOutboxForUpdate(ctx, in.Cfg.GetInt32("cron.outbox.relay.batch_size"))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find pending outbox", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return 0, err
+	}
+
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	sentIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		err = common.PublishMessage(ctx, in.Publisher, message.Subject, json.RawMessage(message.Payload))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to relay outbox message", traceIdAttr, slog.Int64("outbox_id", message.ID), slog.Any(constant.LogFieldErr, err))
+
+			// Stop at the first failure, the rest of the batch is retried on the next tick
+			err = withTx.UpdateOutboxFailed(ctx, sqlgen.UpdateOutboxFailedParams{
+				ID:        message.ID,
+				LastError: pgtype.Text{String: err.Error(), Valid: true},
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to update outbox failed", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+				return 0, err
+			}
+			break
+		}
+
+		sentIds = append(sentIds, message.ID)
+	}
+
+	if len(sentIds) > 0 {
+		err = withTx.UpdateOutboxSent(ctx, sqlgen.UpdateOutboxSentParams{
+			SentAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+			Ids:    sentIds,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update outbox sent", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return 0, err
+	}
+
+	slog.DebugContext(ctx, "outbox relayed", traceIdAttr, slog.Int("sent", len(sentIds)))
+
+	return len(sentIds), nil
+}
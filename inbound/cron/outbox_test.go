@@ -0,0 +1,201 @@
+package cron
+
+import (
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"log/slog"
+	"testing"
+)
+
+type OutboxCronTestSuite struct {
+	suite.Suite
+
+	Querier *sqlgen.Queries
+	PgxMock pgxmock.PgxPoolIface
+
+	Publisher *jetsteamMock.MockPublisher
+
+	Cfg *viper.Viper
+}
+
+func (s *OutboxCronTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.Querier = sqlgen.New(pool)
+	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
+
+	s.Cfg = viper.New()
+	s.Cfg.Set("cron.outbox.relay.interval", "1s")
+	s.Cfg.Set("cron.outbox.relay.timeout", "10s")
+	s.Cfg.Set("cron.outbox.relay.batch_size", 10)
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *OutboxCronTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+}
+
+func TestOutboxCronTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxCronTestSuite))
+}
+
+func (s *OutboxCronTestSuite) TestRelay() {
+	pendingQuery := `SELECT id, subject, payload FROM outbox WHERE sent_at IS NULL ORDER BY id LIMIT \$1 FOR UPDATE SKIP LOCKED`
+
+	tests := []struct {
+		name         string
+		setupMock    func()
+		expectedSent int
+		expectError  bool
+	}{
+		{
+			name: "begin transaction error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin().WillReturnError(fmt.Errorf("begin error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "find pending outbox error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name: "no pending outbox",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "subject", "payload"}))
+				s.PgxMock.ExpectRollback()
+			},
+			expectedSent: 0,
+		},
+		{
+			name: "publish error marks failure and stops batch",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "subject", "payload"}).
+						AddRow(int64(1), constant.SubjectCreateOrder, []byte(`{"id":1}`)).
+						AddRow(int64(2), constant.SubjectCreateOrder, []byte(`{"id":2}`)).
+						AddRow(int64(3), constant.SubjectCreateOrder, []byte(`{"id":3}`)))
+
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCreateOrder, []byte(`{"id":1}`)).Return(nil, nil)
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCreateOrder, []byte(`{"id":2}`)).Return(nil, fmt.Errorf("publish error"))
+
+				s.PgxMock.ExpectExec(`UPDATE outbox SET attempts = attempts \+ 1, last_error = \$2 WHERE id = \$1`).
+					WithArgs(int64(2), pgtype.Text{String: "publish error", Valid: true}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec(`UPDATE outbox SET sent_at = \$1 WHERE id = ANY \(\$2::bigint\[\]\)`).
+					WithArgs(pgxmock.AnyArg(), []int64{1}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit()
+			},
+			expectedSent: 1,
+		},
+		{
+			name: "update outbox sent error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "subject", "payload"}).
+						AddRow(int64(1), constant.SubjectCreateOrder, []byte(`{"id":1}`)))
+
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCreateOrder, []byte(`{"id":1}`)).Return(nil, nil)
+
+				s.PgxMock.ExpectExec(`UPDATE outbox SET sent_at = \$1 WHERE id = ANY \(\$2::bigint\[\]\)`).
+					WithArgs(pgxmock.AnyArg(), []int64{1}).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name: "commit error",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "subject", "payload"}).
+						AddRow(int64(1), constant.SubjectCreateOrder, []byte(`{"id":1}`)))
+
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCreateOrder, []byte(`{"id":1}`)).Return(nil, nil)
+
+				s.PgxMock.ExpectExec(`UPDATE outbox SET sent_at = \$1 WHERE id = ANY \(\$2::bigint\[\]\)`).
+					WithArgs(pgxmock.AnyArg(), []int64{1}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(fmt.Errorf("commit error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name: "success",
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(pendingQuery).
+					WithArgs(int32(10)).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "subject", "payload"}).
+						AddRow(int64(1), constant.SubjectCreateOrder, []byte(`{"id":1}`)).
+						AddRow(int64(2), constant.SubjectSendEmail, []byte(`{"to":"john@example.com"}`)))
+
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectCreateOrder, []byte(`{"id":1}`)).Return(nil, nil)
+				s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectSendEmail, []byte(`{"to":"john@example.com"}`)).Return(nil, nil)
+
+				s.PgxMock.ExpectExec(`UPDATE outbox SET sent_at = \$1 WHERE id = ANY \(\$2::bigint\[\]\)`).
+					WithArgs(pgxmock.AnyArg(), []int64{1, 2}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+				s.PgxMock.ExpectCommit()
+			},
+			expectedSent: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			outboxCron := OutboxCron{
+				Cfg:       s.Cfg,
+				Db:        s.PgxMock,
+				Querier:   s.Querier,
+				Publisher: s.Publisher,
+			}
+
+			tc.setupMock()
+
+			sent, err := outboxCron.relay(context.Background())
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+				s.Equal(tc.expectedSent, sent)
+			}
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type WaitlistClaimExpiryCron struct {
+	Cfg       *viper.Viper
+	Cache     *redis.Client
+	Publisher jetstream.Publisher
+
+	TimeNow func() time.Time
+}
+
+func (in WaitlistClaimExpiryCron) Start(ctx context.Context) {
+	sweepTicker := time.NewTicker(in.Cfg.GetDuration("cron.waitlist.claim_expiry.interval"))
+	defer sweepTicker.Stop()
+
+	slog.Info("waitlist claim expiry cron started")
+
+	for {
+		select {
+		case <-sweepTicker.C:
+			in.sweep(ctx)
+		case <-ctx.Done():
+			slog.Info("waitlist claim expiry cron stopped")
+			return
+		}
+	}
+}
+
+// sweep releases the tickets of unclaimed waitlist claims, each one goes to the
+// next buyer on the waitlist or back on sale when nobody is waiting.
+func (in WaitlistClaimExpiryCron) sweep(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, in.Cfg.GetDuration("cron.waitlist.claim_expiry.timeout"))
+	defer cancel()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	tokenHashes, err := in.Cache.ZRangeByScore(ctx, constant.WaitlistClaimsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(in.TimeNow().Unix(), 10),
+		Count: in.Cfg.GetInt64("cron.waitlist.claim_expiry.batch_size"),
+	}).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find expired waitlist claims", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return
+	}
+
+	if len(tokenHashes) == 0 {
+		slog.DebugContext(ctx, "no expired waitlist claims", traceIdAttr)
+		return
+	}
+
+	for _, tokenHash := range tokenHashes {
+		if err := in.expire(ctx, tokenHash); err != nil {
+			return
+		}
+	}
+
+	slog.InfoContext(ctx, "expire waitlist claims success", slog.Any(constant.LogFieldResponse, len(tokenHashes)), traceIdAttr)
+}
+
+func (in WaitlistClaimExpiryCron) expire(ctx context.Context, tokenHash string) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	claimKey := fmt.Sprintf(constant.WaitlistClaimKey, tokenHash)
+	data, err := in.Cache.Get(ctx, claimKey).Result()
+	if err != nil && err != redis.Nil {
+		slog.ErrorContext(ctx, "failed to get waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	// A claim without its record has nothing to restock, it is only dropped
+	// from the index.
+	if err == redis.Nil {
+		if err := in.Cache.ZRem(ctx, constant.WaitlistClaimsKey, tokenHash).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to remove waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+		return nil
+	}
+
+	var claim model.WaitlistClaim
+	if err := json.Unmarshal([]byte(data), &claim); err != nil {
+		slog.ErrorContext(ctx, "failed to unmarshal waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	keys := append([]string{constant.WaitlistClaimsKey, claimKey}, common.RestockKeys(claim.EventId, claim.CategoryId)...)
+	waitlisted, err := common.ExpireWaitlistClaimScript.Run(ctx, in.Cache, keys, tokenHash).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to expire waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if waitlisted < 0 {
+		slog.DebugContext(ctx, "waitlist claim already taken", traceIdAttr)
+		return nil
+	}
+
+	return common.PublishRestock(ctx, in.Publisher, claim.EventId, claim.CategoryId, 1, int32(waitlisted))
+}
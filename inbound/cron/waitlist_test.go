@@ -0,0 +1,158 @@
+package cron
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"context"
+	"fmt"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type WaitlistClaimExpiryCronTestSuite struct {
+	suite.Suite
+
+	Cfg *viper.Viper
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
+	Publisher *jetsteamMock.MockPublisher
+}
+
+func (s *WaitlistClaimExpiryCronTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
+
+	s.Cfg = viper.New()
+	s.Cfg.Set("cron.waitlist.claim_expiry.interval", "5s")
+	s.Cfg.Set("cron.waitlist.claim_expiry.timeout", "10s")
+	s.Cfg.Set("cron.waitlist.claim_expiry.batch_size", 10)
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *WaitlistClaimExpiryCronTestSuite) TearDownTest() {
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestWaitlistClaimExpiryCronTestSuite(t *testing.T) {
+	suite.Run(t, new(WaitlistClaimExpiryCronTestSuite))
+}
+
+func (s *WaitlistClaimExpiryCronTestSuite) TestSweep() {
+	claim := `{"event_id":1,"category_id":9,"name":"Jane Doe","email":"jane@example.com","expired_at":"2022-12-31T23:50:00Z"}`
+	rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "1672531200", Count: 10}
+
+	expectExpire := func(tokenHash string) *redismock.ExpectedCmd {
+		return s.CacheMock.ExpectEvalSha(common.ExpireWaitlistClaimScript.Hash(), []string{
+			constant.WaitlistClaimsKey,
+			fmt.Sprintf(constant.WaitlistClaimKey, tokenHash),
+			fmt.Sprintf(constant.EachCategoryQuantityKey, 1, 9),
+			fmt.Sprintf(constant.WaitlistKey, 1, 9),
+			fmt.Sprintf(constant.WaitlistPendingKey, 1, 9),
+		}, tokenHash)
+	}
+
+	tests := []struct {
+		name      string
+		setupMock func()
+	}{
+		{
+			name: "find expired claims error",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetErr(fmt.Errorf("redis error"))
+			},
+		},
+		{
+			name: "no expired claims",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetVal([]string{})
+			},
+		},
+		{
+			name: "claim without record is dropped",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetVal([]string{"hash-1"})
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.WaitlistClaimKey, "hash-1")).RedisNil()
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, "hash-1").SetVal(1)
+			},
+		},
+		{
+			name: "claim taken meanwhile",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetVal([]string{"hash-1"})
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.WaitlistClaimKey, "hash-1")).SetVal(claim)
+				expectExpire("hash-1").SetVal(int64(-1))
+			},
+		},
+		{
+			name: "stop on publish error",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetVal([]string{"hash-1", "hash-2"})
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.WaitlistClaimKey, "hash-1")).SetVal(claim)
+				expectExpire("hash-1").SetVal(int64(0))
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+		},
+		{
+			name: "hand to the next buyer or put back on sale",
+			setupMock: func() {
+				s.CacheMock.ExpectZRangeByScore(constant.WaitlistClaimsKey, rangeBy).SetVal([]string{"hash-1", "hash-2"})
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.WaitlistClaimKey, "hash-1")).SetVal(claim)
+				expectExpire("hash-1").SetVal(int64(1))
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.WaitlistClaimKey, "hash-2")).SetVal(claim)
+				expectExpire("hash-2").SetVal(int64(0))
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					[]byte(`{"event_id":1,"category_id":9,"quantity":1}`),
+				).Return(nil, nil)
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					[]byte(`[{"id":9,"quantity":1}]`),
+				).Return(nil, nil)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			claimExpiryCron := WaitlistClaimExpiryCron{
+				Cfg:       s.Cfg,
+				Cache:     s.Cache,
+				Publisher: s.Publisher,
+				TimeNow: func() time.Time {
+					return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+				},
+			}
+
+			tc.setupMock()
+
+			claimExpiryCron.sweep(context.Background())
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"maps"
+	"slices"
 	"time"
 )
 
@@ -40,17 +42,24 @@ func (in CategoryEvent) BulkIncrementCategoryQuantityHandler(ctx context.Context
 		categoryIdValueMap[category.ID] += category.Quantity
 	}
 
-	err = in.Querier.BulkIncrementCategoryQuantity(ctx, sqlgen.BulkIncrementCategoryQuantityParams{
-		Column1: categoryIdValueMap[1],
-		Column2: categoryIdValueMap[2],
-		Column3: categoryIdValueMap[3],
-		Column4: categoryIdValueMap[4],
-		Column5: categoryIdValueMap[5],
-		Column6: categoryIdValueMap[6],
-		Column7: categoryIdValueMap[7],
-		Column8: categoryIdValueMap[8],
-		Column9: categoryIdValueMap[9],
-	})
+	// Categories without a net change are left out, ids are sorted to keep the
+	// query arguments stable.
+	var params sqlgen.BulkIncrementCategoryQuantityParams
+	for _, id := range slices.Sorted(maps.Keys(categoryIdValueMap)) {
+		if categoryIdValueMap[id] == 0 {
+			continue
+		}
+
+		params.Ids = append(params.Ids, id)
+		params.Quantities = append(params.Quantities, categoryIdValueMap[id])
+	}
+
+	if len(params.Ids) == 0 {
+		slog.InfoContext(ctx, "bulk increment category quantity event skipped, zero quantities only", traceIdAttr)
+		return nil
+	}
+
+	err = in.Querier.BulkIncrementCategoryQuantity(ctx, params)
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to bulk increment category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		return err
@@ -65,11 +65,20 @@ func (s *CategoryEventTestSuite) TestBulkIncrementCategoryQuantityHandler() {
 			},
 			setupMock: func() {
 				s.PgxMock.ExpectExec("UPDATE categories").
-					WithArgs(int32(5), int32(10), int32(0), int32(0), int32(0), int32(0), int32(0), int32(0), int32(0)).
+					WithArgs([]int16{1, 2}, []int32{5, 10}).
 					WillReturnError(fmt.Errorf("database error"))
 			},
 			expectError: true,
 		},
+		{
+			name: "zero quantities only",
+			input: []model.IncrementCategoryQuantityEventMessage{
+				{ID: 1, Quantity: 5},
+				{ID: 1, Quantity: -5},
+			},
+			setupMock:   func() {},
+			expectError: false,
+		},
 		{
 			name: "success",
 			input: []model.IncrementCategoryQuantityEventMessage{
@@ -77,11 +86,12 @@ func (s *CategoryEventTestSuite) TestBulkIncrementCategoryQuantityHandler() {
 				{ID: 3, Quantity: 15},
 				{ID: 9, Quantity: 25},
 				{ID: 3, Quantity: 10}, // Testing accumulation for same category
+				{ID: 12, Quantity: 4}, // Categories are not limited to a fixed set
 			},
 			setupMock: func() {
 				s.PgxMock.ExpectExec("UPDATE categories").
-					WithArgs(int32(5), int32(0), int32(25), int32(0), int32(0), int32(0), int32(0), int32(0), int32(25)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 9))
+					WithArgs([]int16{1, 3, 9, 12}, []int32{5, 25, 25, 4}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 4))
 			},
 			expectError: false,
 		},
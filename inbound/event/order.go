@@ -1,11 +1,16 @@
 package event
 
 import (
+	"cmp"
 	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	"concert-ticket/common/contract"
 	"concert-ticket/common/otel"
+	"concert-ticket/common/pricing"
+	"concert-ticket/common/seating"
+	"concert-ticket/common/vars"
 	"concert-ticket/model"
+	"concert-ticket/outbound/payment"
 	"concert-ticket/outbound/sqlgen"
 	"context"
 	"encoding/json"
@@ -14,18 +19,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/text/message"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 )
 
 type OrderEvent struct {
 	Db                   contract.DbConn
 	Querier              *sqlgen.Queries
+	Cache                *redis.Client
 	Publisher            jetstream.Publisher
+	PaymentGateway       payment.PaymentGateway
+	SeatAllocators       *seating.Allocators
 	IdrCurrencyFormatter *message.Printer
 
 	Timeout time.Duration
+	TimeNow func() time.Time
 }
 
 func (in OrderEvent) CreateHandler(ctx context.Context, msg []byte) error {
@@ -60,16 +72,26 @@ func (in OrderEvent) CreateHandler(ctx context.Context, msg []byte) error {
 }
 
 func (in OrderEvent) buildOrderConfirmationEmailBody(req model.CreateOrderEventMessage) string {
-	priceFormattedIdr := in.IdrCurrencyFormatter.Sprintf("Rp%d", constant.CategoryPriceById[req.CategoryID])
-	categoryName := constant.CategoryNameById[req.CategoryID]
+	category, _ := vars.GetCategory(req.CategoryID)
+	event, _ := vars.GetEvent(category.EventId)
 
 	return fmt.Sprintf(constant.EmailOrderConfirmationTemplate,
 		req.Name,
-		fmt.Sprintf("CLDPLY-%d", req.ID),
-		categoryName,
-		priceFormattedIdr,
+		event.Name,
+		event.Name,
+		common.OrderNumber(event, req.ID),
+		category.Name,
+		req.Quantity,
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Subtotal),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.PlatformFee),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Vat),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Total),
+		constant.PaymentMethodNameByMethod[req.PaymentMethod],
 		req.PaymentCode,
 		req.ExpiredAt,
+		constant.PaymentMethodInstructionsByMethod[req.PaymentMethod],
+		event.SupportEmail,
+		event.BrandName,
 	)
 }
 
@@ -98,26 +120,49 @@ func (in OrderEvent) CompleteHandler(ctx context.Context, msg []byte) error {
 	}
 
 	if err == pgx.ErrNoRows {
+		if req.Status == constant.PaymentStatusPaid {
+			return in.handleLatePayment(ctx, req, msg)
+		}
+
 		slog.WarnContext(ctx, "order not found", traceIdAttr)
 		return nil
 	}
 
-	cmd, err := in.Querier.UpdateOrderStatusToCompleted(ctx, order.ID)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to update order status", traceIdAttr, slog.Any(constant.LogFieldErr, err))
-		return err
+	if req.Status != constant.PaymentStatusPaid {
+		return in.cancelUnpaidOrder(ctx, order.ID, req)
 	}
 
-	if cmd.RowsAffected() == 0 {
-		slog.WarnContext(ctx, "order status is not pending", traceIdAttr)
-		return nil
+	// A payment that does not match the order is kept for manual review
+	// instead of completing the order.
+	expectedAmount := order.TotalAmount
+	if reason := paymentExceptionReason(req, expectedAmount); reason != "" {
+		return in.flagPaymentException(ctx, order.ID, expectedAmount, reason, req, msg)
 	}
 
 	assignOrderTicketRowCol := model.AssignOrderTicketRowCol{
 		ID:         order.ID,
 		CategoryId: order.CategoryID,
+		Quantity:   order.Quantity,
 		Email:      order.Email,
 		Name:       order.Name,
+		Price:      pricing.FromOrder(order.Quantity, order.BasePrice, order.PlatformFee, order.VatAmount, order.TotalAmount),
+	}
+
+	// General admission tickets have no seat to assign, their admission
+	// numbers are issued together with the status change.
+	if isGeneralAdmission(order.CategoryID) {
+		return in.completeGeneralAdmission(ctx, assignOrderTicketRowCol)
+	}
+
+	cmd, err := in.Querier.UpdateOrderStatusToCompleted(ctx, order.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update order status", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		slog.WarnContext(ctx, "order status is not pending", traceIdAttr)
+		return nil
 	}
 
 	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectAssignOrderTicketRowCol, assignOrderTicketRowCol)
@@ -131,6 +176,378 @@ func (in OrderEvent) CompleteHandler(ctx context.Context, msg []byte) error {
 	return nil
 }
 
+func (in OrderEvent) completeGeneralAdmission(ctx context.Context, req model.AssignOrderTicketRowCol) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	withTx := in.Querier.WithTx(tx)
+
+	cmd, err := withTx.UpdateOrderStatusToCompleted(ctx, req.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update order status", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		slog.WarnContext(ctx, "order status is not pending", traceIdAttr)
+		return nil
+	}
+
+	numbers, err := in.issueAdmissionNumbers(ctx, withTx, req.ID, req.CategoryId, req.Quantity)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
+		To:      req.Email,
+		Subject: "Order Confirmation",
+		Body:    in.buildOrderAdmissionEmailBody(req, numbers),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish email payload", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	slog.InfoContext(ctx, "order status updated to completed", traceIdAttr)
+
+	return nil
+}
+
+func isGeneralAdmission(categoryId int16) bool {
+	category, _ := vars.GetCategory(categoryId)
+	return category.SeatingType == constant.SeatingTypeGeneralAdmission
+}
+
+func paymentExceptionReason(req model.PaymentCallbackRequest, expectedAmount int64) string {
+	switch {
+	case req.Currency != constant.PaymentCurrencyIDR:
+		return constant.PaymentExceptionCurrencyMismatch
+	case req.Amount != expectedAmount:
+		return constant.PaymentExceptionAmountMismatch
+	}
+
+	return ""
+}
+
+func (in OrderEvent) flagPaymentException(ctx context.Context, orderId int32, expectedAmount int64, reason string, req model.PaymentCallbackRequest, msg []byte) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	err := in.Querier.InsertPaymentException(ctx, sqlgen.InsertPaymentExceptionParams{
+		OrderID:               orderId,
+		ExternalID:            req.ExternalId,
+		ProviderTransactionID: req.ProviderTransactionId,
+		Reason:                reason,
+		ExpectedAmount:        expectedAmount,
+		PaidAmount:            req.Amount,
+		Currency:              req.Currency,
+		Payload:               msg,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to insert payment exception", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	slog.WarnContext(ctx, "payment flagged as exception", slog.String("reason", reason), traceIdAttr)
+
+	return nil
+}
+
+// handleLatePayment honours a payment that arrived after the order was
+// cancelled. The order is reinstated when the category still has stock,
+// otherwise the payment is refunded. Either way the outcome is recorded in
+// late_payments and the follow-up messages go through the outbox, so they are
+// only emitted with the status change.
+func (in OrderEvent) handleLatePayment(ctx context.Context, req model.PaymentCallbackRequest, msg []byte) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	order, err := in.Querier.FindOrderByExternalIdAndStatusCancelled(ctx, req.ExternalId)
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "failed to get cancelled order", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if err == pgx.ErrNoRows {
+		slog.WarnContext(ctx, "order not found", traceIdAttr)
+		return nil
+	}
+
+	expectedAmount := order.TotalAmount
+	if reason := paymentExceptionReason(req, expectedAmount); reason != "" {
+		return in.flagPaymentException(ctx, order.ID, expectedAmount, reason, req, msg)
+	}
+
+	quantityKey := fmt.Sprintf(constant.EachCategoryQuantityKey, order.EventID, order.CategoryID)
+	remaining, err := in.Cache.DecrBy(ctx, quantityKey, int64(order.Quantity)).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to decrement category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	releaseStock := func() {
+		redisErr := in.Cache.IncrBy(ctx, quantityKey, int64(order.Quantity)).Err()
+		if redisErr != nil {
+			slog.ErrorContext(ctx, "failed to increment category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, redisErr))
+		}
+	}
+
+	reinstate := remaining >= 0
+	if !reinstate {
+		releaseStock()
+	}
+
+	defer func() {
+		if err != nil && reinstate {
+			releaseStock()
+		}
+	}()
+
+	status := sqlgen.OrderStatusRefundRequired
+	outcome := constant.LatePaymentOutcomeRefundRequired
+	if reinstate {
+		status = sqlgen.OrderStatusCompleted
+		outcome = constant.LatePaymentOutcomeReinstated
+	}
+
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	withTx := in.Querier.WithTx(tx)
+
+	cmd, err := withTx.UpdateOrderStatusFromCancelled(ctx, sqlgen.UpdateOrderStatusFromCancelledParams{
+		Status:    sqlgen.NullOrderStatus{OrderStatus: status, Valid: true},
+		UpdatedAt: pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+		ID:        order.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update cancelled order status", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		slog.WarnContext(ctx, "order status is not cancelled", traceIdAttr)
+		if reinstate {
+			releaseStock()
+		}
+		return nil
+	}
+
+	err = withTx.InsertLatePayment(ctx, sqlgen.InsertLatePaymentParams{
+		OrderID:               order.ID,
+		ExternalID:            order.ExternalID,
+		ProviderTransactionID: req.ProviderTransactionId,
+		Amount:                req.Amount,
+		Outcome:               outcome,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to insert late payment", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	var outboxMessages []outboxMessage
+	if reinstate {
+		outboxMessages = []outboxMessage{
+			{constant.SubjectIncrementCategoryQuantity, model.IncrementCategoryQuantityEventMessage{
+				ID:       order.CategoryID,
+				Quantity: -order.Quantity,
+			}},
+			{constant.SubjectAssignOrderTicketRowCol, model.AssignOrderTicketRowCol{
+				ID:         order.ID,
+				CategoryId: order.CategoryID,
+				Quantity:   order.Quantity,
+				Email:      order.Email,
+				Name:       order.Name,
+				Price:      pricing.FromOrder(order.Quantity, order.BasePrice, order.PlatformFee, order.VatAmount, order.TotalAmount),
+			}},
+		}
+	} else {
+		outboxMessages = []outboxMessage{
+			{constant.SubjectRefundPayment, model.RefundPaymentEventMessage{
+				OrderId:               order.ID,
+				ExternalId:            order.ExternalID,
+				ProviderTransactionId: req.ProviderTransactionId,
+				Amount:                req.Amount,
+			}},
+			{constant.SubjectSendEmail, model.SendEmailEventMessage{
+				To:      order.Email,
+				Subject: "Order Refund",
+				Body:    in.buildOrderRefundEmailBody(order.ID, order.CategoryID, order.Quantity, order.Name, req.Amount),
+			}},
+		}
+	}
+
+	for _, outboxMsg := range outboxMessages {
+		payload, marshalErr := json.Marshal(outboxMsg.body)
+		if marshalErr != nil {
+			err = marshalErr
+			slog.ErrorContext(ctx, "failed to marshal outbox message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+
+		err = withTx.InsertOutbox(ctx, sqlgen.InsertOutboxParams{Subject: outboxMsg.subject, Payload: payload})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to insert outbox", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	slog.InfoContext(ctx, "late payment handled", slog.String("outcome", outcome), traceIdAttr)
+
+	return nil
+}
+
+type outboxMessage struct {
+	subject string
+	body    any
+}
+
+// RefundHandler returns a late payment to the buyer through the payment
+// gateway. Gateways deduplicate refunds by provider transaction id, so a
+// redelivered message does not refund twice.
+func (in OrderEvent) RefundHandler(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, in.Timeout)
+	defer cancel()
+
+	var req model.RefundPaymentEventMessage
+	err := json.Unmarshal(msg, &req)
+	if err != nil {
+		slog.WarnContext(ctx, "refund payment event unmarshal error", slog.Any(constant.LogFieldErr, err))
+		return nil
+	}
+
+	ctx, span := otel.Tracer.Start(ctx, "OrderEvent.refund")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	slog.InfoContext(ctx, "refund payment event receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
+
+	refund, err := in.PaymentGateway.Refund(ctx, payment.RefundRequest{
+		ExternalId:            req.ExternalId,
+		ProviderTransactionId: req.ProviderTransactionId,
+		Amount:                req.Amount,
+		Reason:                constant.LatePaymentOutcomeRefundRequired,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to refund payment", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	err = in.Querier.UpdateLatePaymentRefunded(ctx, sqlgen.UpdateLatePaymentRefundedParams{
+		ProviderTransactionID: req.ProviderTransactionId,
+		RefundID:              pgtype.Text{String: refund.RefundId, Valid: true},
+		RefundedAt:            pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update late payment refund", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	slog.InfoContext(ctx, "refund payment success", slog.String("refund_id", refund.RefundId), traceIdAttr)
+
+	return nil
+}
+
+func (in OrderEvent) buildOrderRefundEmailBody(id int32, categoryId int16, quantity int32, name string, amount int64) string {
+	category, _ := vars.GetCategory(categoryId)
+	event, _ := vars.GetEvent(category.EventId)
+
+	return fmt.Sprintf(constant.EmailOrderRefundTemplate,
+		name,
+		event.Name,
+		common.OrderNumber(event, id),
+		category.Name,
+		quantity,
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", amount),
+		event.SupportEmail,
+		event.BrandName,
+	)
+}
+
+// cancelUnpaidOrder cancels a pending order whose payment failed or expired at
+// the gateway and puts its tickets back on sale.
+func (in OrderEvent) cancelUnpaidOrder(ctx context.Context, orderId int32, req model.PaymentCallbackRequest) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	order, err := in.Querier.CancelOrderByIdAndStatusPending(ctx, sqlgen.CancelOrderByIdAndStatusPendingParams{
+		ID:        orderId,
+		UpdatedAt: pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+	})
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "failed to cancel order", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	if err == pgx.ErrNoRows {
+		slog.WarnContext(ctx, "order status is not pending", traceIdAttr)
+		return nil
+	}
+
+	waitlisted, err := common.Restock(ctx, in.Cache, order.EventID, order.CategoryID, order.Quantity)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to restock category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	err = in.Cache.Del(ctx, fmt.Sprintf(constant.OrderEmailLock, order.EventID, order.Email)).Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete email lock", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	payment.CancelOpenCharge(ctx, in.PaymentGateway, order.PaymentCode)
+
+	err = common.PublishRestock(ctx, in.Publisher, order.EventID, order.CategoryID, order.Quantity, waitlisted)
+	if err != nil {
+		return err
+	}
+
+	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
+		To:      order.Email,
+		Subject: "Order Cancellation",
+		Body:    common.BuildOrderCancellationEmailBody(in.IdrCurrencyFormatter, order.ID, order.CategoryID, order.Quantity, order.Name, order.TotalAmount),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish cancel order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return err
+	}
+
+	slog.InfoContext(ctx, "order cancelled by payment status", slog.String("status", req.Status), traceIdAttr)
+
+	return nil
+}
+
 func (in OrderEvent) AssignTicketColHandler(ctx context.Context, msg []byte) error {
 	ctx, cancel := context.WithTimeout(ctx, in.Timeout)
 	defer cancel()
@@ -163,26 +580,72 @@ func (in OrderEvent) AssignTicketColHandler(ctx context.Context, msg []byte) err
 
 	withTx := in.Querier.WithTx(tx)
 
-	decrementedTicket, err := withTx.DecrementCategoryQuantityCol(ctx, req.CategoryId)
+	// Orders published before quantity existed carry a zero value and hold a single ticket.
+	quantity := max(req.Quantity, 1)
+
+	// Reinstated late payments still come through here, general admission
+	// orders get admission numbers instead of seats.
+	if isGeneralAdmission(req.CategoryId) {
+		numbers, err := in.issueAdmissionNumbers(ctx, withTx, req.ID, req.CategoryId, quantity)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Commit(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+
+		return in.publishCompletionEmail(ctx, req.Email, in.buildOrderAdmissionEmailBody(req, numbers))
+	}
+
+	// Seats the buyer picked are held by the order and only confirmed here.
+	held, err := withTx.ConfirmHeldSeats(ctx, req.ID)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to decrement category quantity col", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		slog.ErrorContext(ctx, "failed to confirm held seats", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		return err
 	}
 
-	if decrementedTicket.Row == 0 {
-		slog.ErrorContext(ctx, "category quantity row is 0", traceIdAttr)
-		return fmt.Errorf("category quantity row is 0")
+	seats := make([]model.SeatPosition, 0, quantity)
+	for _, seat := range held {
+		seats = append(seats, model.SeatPosition{Row: seat.Row, Col: seat.Col})
 	}
 
-	if decrementedTicket.Col < 1 {
-		slog.ErrorContext(ctx, "category quantity col is 0", traceIdAttr)
-		return fmt.Errorf("category quantity col is 0")
+	slices.SortFunc(seats, func(a, b model.SeatPosition) int {
+		return cmp.Or(cmp.Compare(a.Row, b.Row), cmp.Compare(a.Col, b.Col))
+	})
+
+	if need := int(quantity) - len(seats); need > 0 {
+		allocated, err := in.allocateSeats(ctx, withTx, req.ID, req.CategoryId, need)
+		if err != nil {
+			return err
+		}
+
+		seats = append(seats, allocated...)
+	}
+
+	for _, seat := range seats {
+		cmd, err := withTx.UpdateOrderItemTicketRowCol(ctx, sqlgen.UpdateOrderItemTicketRowColParams{
+			OrderID:   req.ID,
+			TicketRow: pgtype.Int4{Int32: seat.Row, Valid: true},
+			TicketCol: pgtype.Int4{Int32: seat.Col, Valid: true},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update order item ticket row col", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return err
+		}
+
+		if cmd.RowsAffected() == 0 {
+			slog.ErrorContext(ctx, "order item ticket row col is not updated", traceIdAttr)
+			return fmt.Errorf("order item ticket row col is not updated")
+		}
 	}
 
 	cmd, err := withTx.UpdateOrderTicketRowCol(ctx, sqlgen.UpdateOrderTicketRowColParams{
 		ID:        req.ID,
-		TicketRow: pgtype.Int4{Int32: decrementedTicket.Row, Valid: true},
-		TicketCol: pgtype.Int4{Int32: decrementedTicket.Col, Valid: true},
+		TicketRow: pgtype.Int4{Int32: seats[0].Row, Valid: true},
+		TicketCol: pgtype.Int4{Int32: seats[0].Col, Valid: true},
 	})
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to update order ticket row col", traceIdAttr, slog.Any(constant.LogFieldErr, err))
@@ -200,13 +663,19 @@ func (in OrderEvent) AssignTicketColHandler(ctx context.Context, msg []byte) err
 		return err
 	}
 
+	return in.publishCompletionEmail(ctx, req.Email, in.buildOrderCompletionEmailBody(req, seats))
+}
+
+func (in OrderEvent) publishCompletionEmail(ctx context.Context, to, body string) error {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
 	emailPayload := model.SendEmailEventMessage{
-		To:      req.Email,
+		To:      to,
 		Subject: "Order Confirmation",
-		Body:    in.buildOrderCompletionEmailBody(req, decrementedTicket.Row, decrementedTicket.Col),
+		Body:    body,
 	}
 
-	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, emailPayload)
+	err := common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, emailPayload)
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to publish email payload", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		return err
@@ -217,10 +686,146 @@ func (in OrderEvent) AssignTicketColHandler(ctx context.Context, msg []byte) err
 	return nil
 }
 
-func (in OrderEvent) buildOrderCompletionEmailBody(req model.AssignOrderTicketRowCol, row, col int32) string {
-	priceFormattedIdr := in.IdrCurrencyFormatter.Sprintf("Rp%d", constant.CategoryPriceById[req.CategoryId])
-	categoryName := constant.CategoryNameById[req.CategoryId]
-	orderID := fmt.Sprintf("CLDPLY-%d", req.ID)
+// issueAdmissionNumbers takes the next quantity numbers from the category
+// counter and writes them to the order items. The counter row stays locked
+// until the transaction ends, so concurrent orders get consecutive ranges and
+// a rolled back order leaves no gap.
+func (in OrderEvent) issueAdmissionNumbers(ctx context.Context, querier *sqlgen.Queries, orderId int32, categoryId int16, quantity int32) ([]int32, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	last, err := querier.IssueAdmissionNumbers(ctx, sqlgen.IssueAdmissionNumbersParams{
+		Quantity: quantity,
+		ID:       categoryId,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to issue admission numbers", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, err
+	}
+
+	numbers := make([]int32, 0, quantity)
+	for number := last - quantity + 1; number <= last; number++ {
+		cmd, err := querier.UpdateOrderItemAdmissionNumber(ctx, sqlgen.UpdateOrderItemAdmissionNumberParams{
+			AdmissionNumber: pgtype.Int4{Int32: number, Valid: true},
+			OrderID:         orderId,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update order item admission number", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return nil, err
+		}
 
-	return fmt.Sprintf(constant.EmailOrderCompletionTemplate, req.Name, orderID, categoryName, priceFormattedIdr, row, col)
+		if cmd.RowsAffected() == 0 {
+			slog.ErrorContext(ctx, "order item admission number is not updated", traceIdAttr)
+			return nil, fmt.Errorf("order item admission number is not updated")
+		}
+
+		numbers = append(numbers, number)
+	}
+
+	return numbers, nil
+}
+
+// allocateSeats claims need seats in the order the category's SeatAllocator
+// ranks them. The ranking runs in ClaimSeats, seats locked or taken by a
+// concurrent worker are skipped and replaced with the next ranked ones until
+// enough are claimed. The claimed seats are listed by row then column.
+func (in OrderEvent) allocateSeats(ctx context.Context, querier *sqlgen.Queries, orderId int32, categoryId int16, need int) ([]model.SeatPosition, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	layout, err := querier.FindCategoryLayoutById(ctx, categoryId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find category layout", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return nil, err
+	}
+
+	ranking := in.SeatAllocators.For(categoryId).Ranking()
+
+	claimed := make([]model.SeatPosition, 0, need)
+	for len(claimed) < need {
+		seats, err := querier.ClaimSeats(ctx, sqlgen.ClaimSeatsParams{
+			OrderID:         orderId,
+			CategoryID:      categoryId,
+			RowWeight:       ranking.Row,
+			RowCenterWeight: ranking.RowCenter,
+			MaxRow:          layout.MaxRow,
+			ColCenterWeight: ranking.ColCenter,
+			MaxCol:          layout.MaxCol,
+			Shuffle:         ranking.Shuffle,
+			Quantity:        int32(need - len(claimed)),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim seats", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return nil, err
+		}
+
+		if len(seats) == 0 {
+			break
+		}
+
+		for _, seat := range seats {
+			claimed = append(claimed, model.SeatPosition{Row: seat.Row, Col: seat.Col})
+		}
+	}
+
+	if len(claimed) < need {
+		slog.ErrorContext(ctx, "no seat available", traceIdAttr)
+		return nil, fmt.Errorf("no seat available in category %d", categoryId)
+	}
+
+	slices.SortFunc(claimed, func(a, b model.SeatPosition) int {
+		return cmp.Or(cmp.Compare(a.Row, b.Row), cmp.Compare(a.Col, b.Col))
+	})
+
+	return claimed, nil
+}
+
+func (in OrderEvent) buildOrderCompletionEmailBody(req model.AssignOrderTicketRowCol, seats []model.SeatPosition) string {
+	category, _ := vars.GetCategory(req.CategoryId)
+	event, _ := vars.GetEvent(category.EventId)
+
+	seatLabels := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		seatLabels = append(seatLabels, fmt.Sprintf("Row %d, Seat %d", seat.Row, seat.Col))
+	}
+
+	return fmt.Sprintf(constant.EmailOrderCompletionTemplate,
+		req.Name,
+		event.Name,
+		common.OrderNumber(event, req.ID),
+		category.Name,
+		len(seats),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Subtotal),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.PlatformFee),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Vat),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Total),
+		strings.Join(seatLabels, "; "),
+		event.SupportEmail,
+		event.Name,
+		event.BrandName,
+	)
+}
+
+func (in OrderEvent) buildOrderAdmissionEmailBody(req model.AssignOrderTicketRowCol, numbers []int32) string {
+	category, _ := vars.GetCategory(req.CategoryId)
+	event, _ := vars.GetEvent(category.EventId)
+
+	labels := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		labels = append(labels, fmt.Sprintf("#%d", number))
+	}
+
+	return fmt.Sprintf(constant.EmailOrderAdmissionTemplate,
+		req.Name,
+		event.Name,
+		common.OrderNumber(event, req.ID),
+		category.Name,
+		len(numbers),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Subtotal),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.PlatformFee),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Vat),
+		in.IdrCurrencyFormatter.Sprintf("Rp%d", req.Price.Total),
+		strings.Join(labels, ", "),
+		event.SupportEmail,
+		event.Name,
+		event.BrandName,
+	)
 }
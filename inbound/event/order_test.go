@@ -1,16 +1,25 @@
 package event
 
 import (
+	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/common/seating"
+	"concert-ticket/common/vars"
 	"concert-ticket/model"
+	"concert-ticket/outbound/payment"
+	paymentMock "concert-ticket/outbound/payment/mocks"
 	"concert-ticket/outbound/sqlgen"
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/go-redis/redismock/v9"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/text/language"
@@ -24,18 +33,58 @@ type OrderEventTestSuite struct {
 	suite.Suite
 	ctrl       *gomock.Controller
 	publisher  *jetsteamMock.MockPublisher
+	gateway    *paymentMock.MockPaymentGateway
 	Querier    *sqlgen.Queries
 	PgxMock    pgxmock.PgxPoolIface
+	Cache      *redis.Client
+	CacheMock  redismock.ClientMock
 	orderEvent OrderEvent
 }
 
+// testEvents and testCategories stand in for the registry loaded by
+// CategoryCron.
+var testEvents = []model.Event{
+	{
+		Id:           1,
+		Name:         "Coldplay Music of the Spheres World Tour",
+		Timezone:     "UTC",
+		Location:     time.UTC,
+		SaleStartsAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		SaleEndsAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		OrderPrefix:  "CLDPLY",
+		BrandName:    "Concert Ticket Team",
+		SupportEmail: "support@concert-ticket.com",
+	},
+}
+
+var testCategories = []model.CategoryResponse{
+	{Id: 1, EventId: 1, Name: "Ultimate Experience", Price: 11_000_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 2, EventId: 1, Name: "My Universe", Price: 7_500_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 3, EventId: 1, Name: "CAT 1", Price: 5_800_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 4, EventId: 1, Name: "CAT 2", Price: 5_200_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 5, EventId: 1, Name: "CAT 3", Price: 4_600_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 6, EventId: 1, Name: "CAT 4", Price: 3_800_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 7, EventId: 1, Name: "CAT 5", Price: 3_000_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 8, EventId: 1, Name: "CAT 6", Price: 1_500_000, SeatingType: constant.SeatingTypeReserved},
+	{Id: 9, EventId: 1, Name: "Festival", Price: 2_500_000, SeatingType: constant.SeatingTypeGeneralAdmission},
+}
+
 func (s *OrderEventTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
 	s.ctrl = gomock.NewController(s.T())
 	s.publisher = jetsteamMock.NewMockPublisher(s.ctrl)
+	s.gateway = paymentMock.NewMockPaymentGateway(s.ctrl)
 
 	idrPrinter := message.NewPrinter(language.Indonesian)
+	seatAllocators := &seating.Allocators{Cfg: viper.New()}
+	seatAllocators.Init()
+
 	s.orderEvent = OrderEvent{
 		Publisher:            s.publisher,
+		PaymentGateway:       s.gateway,
+		SeatAllocators:       seatAllocators,
 		IdrCurrencyFormatter: idrPrinter,
 	}
 
@@ -48,12 +97,24 @@ func (s *OrderEventTestSuite) SetupTest() {
 	s.PgxMock = pool
 	s.Querier = sqlgen.New(pool)
 
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+	s.orderEvent.Cache = rdb
+
 	s.orderEvent.Timeout = 10 * time.Second
+	s.orderEvent.TimeNow = func() time.Time {
+		return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 }
 
 func (s *OrderEventTestSuite) TearDownTest() {
 	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
 	s.ctrl.Finish()
 }
 
@@ -98,12 +159,19 @@ func (s *OrderEventTestSuite) TestCreate() {
 				ExpiredAt:   time.Now().Add(15 * time.Minute).Format(time.DateTime),
 			},
 			setupMock: func(msg []byte) {
-
 				s.publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectSendEmail,
 					gomock.Any(),
-				).Return(nil, nil)
+				).DoAndReturn(func(_ context.Context, _ string, payload []byte, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+					var email model.SendEmailEventMessage
+					s.Require().NoError(json.Unmarshal(payload, &email))
+					s.Contains(email.Body, "Thank you for ordering tickets for Coldplay Music of the Spheres World Tour!")
+					s.Contains(email.Body, "Order ID: CLDPLY-123")
+					s.Contains(email.Body, "support team at support@concert-ticket.com.")
+					s.Contains(email.Body, "Best regards,\nConcert Ticket Team")
+					return nil, nil
+				})
 			},
 			expectError: false,
 		},
@@ -131,6 +199,26 @@ func (s *OrderEventTestSuite) TestCreate() {
 
 func (s *OrderEventTestSuite) TestComplete() {
 	fixedTime := time.Now()
+	orderColumns := []string{"id", "category_id", "quantity", "external_id", "name", "email", "payment_code", "expired_at", "base_price", "platform_fee", "vat_amount", "total_amount"}
+	cancelledOrderColumns := []string{"id", "event_id", "category_id", "quantity", "external_id", "name", "email", "base_price", "platform_fee", "vat_amount", "total_amount"}
+	pendingOrderQuery := `SELECT (.+) FROM orders WHERE external_id = \$1 AND status = 'pending'`
+	cancelledOrderQuery := `SELECT (.+) FROM orders WHERE external_id = \$1 AND status = 'cancelled'`
+	reinstateOrderQuery := `UPDATE orders SET status = \$1, updated_at = \$2 WHERE id = \$3 AND status = 'cancelled'`
+	cancelOrderQuery := `UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id = \$1 AND status = 'pending' RETURNING id, event_id, category_id, quantity, name, email`
+
+	issueAdmissionNumbersQuery := `UPDATE categories SET last_admission_number = last_admission_number \+ \$1::integer WHERE id = \$2 RETURNING last_admission_number`
+	updateAdmissionNumberQuery := `UPDATE order_items SET admission_number = \$1`
+
+	paidCallback := model.PaymentCallbackRequest{
+		ExternalId:            "order-123",
+		ProviderTransactionId: "trx-1",
+		Status:                constant.PaymentStatusPaid,
+		Amount:                11_000_000,
+		Currency:              constant.PaymentCurrencyIDR,
+	}
+
+	gaPaidCallback := paidCallback
+	gaPaidCallback.Amount = 5_000_000
 
 	testCases := []struct {
 		name        string
@@ -139,10 +227,8 @@ func (s *OrderEventTestSuite) TestComplete() {
 		expectError bool
 	}{
 		{
-			name: "find order error",
-			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
-			},
+			name:  "find order error",
+			input: paidCallback,
 			setupMock: func(msg []byte) {
 				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
 					WithArgs("order-123").
@@ -153,7 +239,10 @@ func (s *OrderEventTestSuite) TestComplete() {
 		{
 			name: "order not found",
 			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusFailed,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
 				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
@@ -163,281 +252,1029 @@ func (s *OrderEventTestSuite) TestComplete() {
 			expectError: false,
 		},
 		{
-			name: "update order status error",
-			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
+			name:  "late payment order not found",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
 			},
+			expectError: false,
+		},
+		{
+			name:  "late payment find cancelled order error",
+			input: paidCallback,
 			setupMock: func(msg []byte) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "external_id", "name", "email", "payment_code", "expired_at"})
-				rows.AddRow(int32(1), int16(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime)
-
-				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
 					WithArgs("order-123").
-					WillReturnRows(rows)
-
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(int32(1)).
-					WillReturnError(fmt.Errorf("update error"))
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(fmt.Errorf("database error"))
 			},
 			expectError: true,
 		},
 		{
-			name: "no rows affected",
+			name: "late payment amount mismatch",
 			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusPaid,
+				Amount:                1_000,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "external_id", "name", "email", "payment_code", "expired_at"})
-				rows.AddRow(int32(1), int16(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime)
-
-				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
 					WithArgs("order-123").
-					WillReturnRows(rows)
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
 
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(int32(1)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectExec("INSERT INTO payment_exceptions").
+					WithArgs(int32(1), "order-123", "trx-1", constant.PaymentExceptionAmountMismatch, int64(11_000_000), int64(1_000), "IDR", msg).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			},
 			expectError: false,
 		},
 		{
-			name: "publish error",
-			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
-			},
+			name:  "late payment decrement stock error",
+			input: paidCallback,
 			setupMock: func(msg []byte) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "external_id", "name", "email", "payment_code", "expired_at"})
-				rows.AddRow(int32(1), int16(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime)
-
-				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
 					WithArgs("order-123").
-					WillReturnRows(rows)
-
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(int32(1)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
 
-				s.publisher.EXPECT().Publish(
-					gomock.Any(),
-					constant.SubjectAssignOrderTicketRowCol,
-					gomock.Any(),
-				).Return(nil, fmt.Errorf("publish error"))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetErr(redis.ErrClosed)
 			},
 			expectError: true,
 		},
 		{
-			name: "success",
-			input: model.PaymentCallbackRequest{
-				ExternalId: "order-123",
-			},
+			name:  "late payment reinstates order",
+			input: paidCallback,
 			setupMock: func(msg []byte) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "external_id", "name", "email", "payment_code", "expired_at"})
-				rows.AddRow(int32(1), int16(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime)
-
-				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
 					WithArgs("order-123").
-					WillReturnRows(rows)
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
 
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(int32(1)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(4)
 
-				s.publisher.EXPECT().Publish(
-					gomock.Any(),
-					constant.SubjectAssignOrderTicketRowCol,
-					gomock.Any(),
-				).Return(nil, nil)
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec(reinstateOrderQuery).
+					WithArgs(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}, pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("INSERT INTO late_payments").
+					WithArgs(int32(1), "order-123", "trx-1", int64(11_000_000), constant.LatePaymentOutcomeReinstated).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectIncrementCategoryQuantity, []byte(`{"id":1,"quantity":-1}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectAssignOrderTicketRowCol, []byte(`{"id":1,"category_id":1,"quantity":1,"email":"john@example.com","name":"John Doe","price":{"base_price":11000000,"subtotal":11000000,"platform_fee":0,"vat":0,"total":11000000}}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
 			},
 			expectError: false,
 		},
-	}
+		{
+			name:  "late payment order no longer cancelled",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
 
-	for _, tc := range testCases {
-		s.Run(tc.name, func() {
-			var msg []byte
-			var err error
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(4)
 
-			msg, err = json.Marshal(tc.input)
-			s.Require().NoError(err)
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec(reinstateOrderQuery).
+					WithArgs(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}, pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback()
 
-			s.orderEvent.Querier = sqlgen.New(s.PgxMock)
-			tc.setupMock(msg)
-			err = s.orderEvent.CompleteHandler(context.Background(), msg)
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(5)
+			},
+			expectError: false,
+		},
+		{
+			name:  "late payment commit error releases stock",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
 
-			if tc.expectError {
-				s.Error(err)
-			} else {
-				s.NoError(err)
-			}
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(4)
 
-			s.NoError(s.PgxMock.ExpectationsWereMet())
-		})
-	}
-}
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec(reinstateOrderQuery).
+					WithArgs(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}, pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("INSERT INTO late_payments").
+					WithArgs(int32(1), "order-123", "trx-1", int64(11_000_000), constant.LatePaymentOutcomeReinstated).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectIncrementCategoryQuantity, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectAssignOrderTicketRowCol, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(fmt.Errorf("commit error"))
+				s.PgxMock.ExpectRollback()
 
-func (s *OrderEventTestSuite) TestAssignTicketCol() {
-	testCases := []struct {
-		name        string
-		input       model.AssignOrderTicketRowCol
-		setupMock   func(msg []byte)
-		expectError bool
-	}{
-		{
-			name: "transaction begin error",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(5)
 			},
+			expectError: true,
+		},
+		{
+			name:  "late payment sold out requires refund",
+			input: paidCallback,
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin().WillReturnError(fmt.Errorf("begin error"))
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(cancelledOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(cancelledOrderColumns).
+						AddRow(int32(1), int16(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
+
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(-1)
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(0)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec(reinstateOrderQuery).
+					WithArgs(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusRefundRequired, Valid: true}, pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("INSERT INTO late_payments").
+					WithArgs(int32(1), "order-123", "trx-1", int64(11_000_000), constant.LatePaymentOutcomeRefundRequired).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectRefundPayment, []byte(`{"order_id":1,"external_id":"order-123","provider_transaction_id":"trx-1","amount":11000000}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectSendEmail, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
 			},
-			expectError: true,
+			expectError: false,
 		},
 		{
-			name: "decrement category quantity error",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "amount mismatch",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusPaid,
+				Amount:                1_000,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnError(fmt.Errorf("decrement error"))
-				s.PgxMock.ExpectRollback().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
+
+				s.PgxMock.ExpectExec("INSERT INTO payment_exceptions").
+					WithArgs(int32(1), "order-123", "trx-1", constant.PaymentExceptionAmountMismatch, int64(11_000_000), int64(1_000), "IDR", msg).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			},
-			expectError: true,
+			expectError: false,
 		},
 		{
-			name: "category quantity row is 0",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "currency mismatch",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusPaid,
+				Amount:                11_000_000,
+				Currency:              "USD",
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(0), int32(5))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnRows(rows)
-				s.PgxMock.ExpectRollback().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000)))
+
+				s.PgxMock.ExpectExec("INSERT INTO payment_exceptions").
+					WithArgs(int32(1), "order-123", "trx-1", constant.PaymentExceptionCurrencyMismatch, int64(11_000_000), int64(11_000_000), "USD", msg).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			},
-			expectError: true,
+			expectError: false,
 		},
 		{
-			name: "category quantity col is negative",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "insert payment exception error",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusPaid,
+				Amount:                11_000_000,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(1), int32(-1))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnRows(rows)
-				s.PgxMock.ExpectRollback().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
+
+				s.PgxMock.ExpectExec("INSERT INTO payment_exceptions").
+					WithArgs(int32(1), "order-123", "trx-1", constant.PaymentExceptionAmountMismatch, int64(22_000_000), int64(11_000_000), "IDR", msg).
+					WillReturnError(fmt.Errorf("database error"))
 			},
 			expectError: true,
 		},
 		{
-			name: "update order ticket row col error",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "failed payment cancel order error",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusFailed,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(1), int32(5))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnRows(rows)
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
-					WillReturnError(fmt.Errorf("update error"))
-				s.PgxMock.ExpectRollback().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
+
+				s.PgxMock.ExpectQuery(cancelOrderQuery).
+					WithArgs(int32(1), pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+					WillReturnError(fmt.Errorf("database error"))
 			},
 			expectError: true,
 		},
 		{
-			name: "commit transaction error",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "failed payment order no longer pending",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusFailed,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(1), int32(5))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnRows(rows)
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
-				s.PgxMock.ExpectCommit().WillReturnError(fmt.Errorf("commit error"))
-				s.PgxMock.ExpectRollback().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
+
+				s.PgxMock.ExpectQuery(cancelOrderQuery).
+					WithArgs(int32(1), pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+					WillReturnError(pgx.ErrNoRows)
 			},
-			expectError: true,
+			expectError: false,
 		},
 		{
-			name: "publish message error",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "failed payment restock error",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusFailed,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(1), int32(5))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
-					WillReturnRows(rows)
-				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
-					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
-					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
-				s.PgxMock.ExpectCommit().WillReturnError(nil)
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
 
-				s.publisher.EXPECT().Publish(
-					gomock.Any(),
-					constant.SubjectSendEmail,
-					gomock.Any(),
-				).Return(nil, fmt.Errorf("publish error"))
+				s.PgxMock.ExpectQuery(cancelOrderQuery).
+					WithArgs(int32(1), pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "PAY123", int64(22_000_000)))
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetErr(redis.ErrClosed)
 			},
 			expectError: true,
 		},
 		{
-			name: "success",
-			input: model.AssignOrderTicketRowCol{
-				ID:         1,
-				CategoryId: 1,
-				Email:      "john@example.com",
-				Name:       "John Doe",
+			name: "expired payment cancels order and hands tickets to the waitlist",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusExpired,
+				Currency:              constant.PaymentCurrencyIDR,
 			},
 			setupMock: func(msg []byte) {
-				s.PgxMock.ExpectBegin()
-				rows := pgxmock.NewRows([]string{"row", "col"}).
-					AddRow(int32(1), int32(5))
-				s.PgxMock.ExpectQuery("WITH selected_quantity AS").
-					WithArgs(int16(1)).
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
+
+				s.PgxMock.ExpectQuery(cancelOrderQuery).
+					WithArgs(int32(1), pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "PAY123", int64(22_000_000)))
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(2))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+				s.gateway.EXPECT().CancelCharge(gomock.Any(), "PAY123").Return(payment.ErrChargeNotFound)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					[]byte(`{"event_id":1,"category_id":1,"quantity":2}`),
+				).Return(nil, nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name: "failed payment cancels order even when the charge cannot be cancelled",
+			input: model.PaymentCallbackRequest{
+				ExternalId:            "order-123",
+				ProviderTransactionId: "trx-1",
+				Status:                constant.PaymentStatusFailed,
+				Currency:              constant.PaymentCurrencyIDR,
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(pgxmock.NewRows(orderColumns).
+						AddRow(int32(1), int16(1), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(22_000_000)))
+
+				s.PgxMock.ExpectQuery(cancelOrderQuery).
+					WithArgs(int32(1), pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "PAY123", int64(22_000_000)))
+
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(0))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+				s.gateway.EXPECT().CancelCharge(gomock.Any(), "PAY123").Return(fmt.Errorf("gateway error"))
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectBulkIncrementCategoryQuantity,
+					[]byte(`[{"id":1,"quantity":2}]`),
+				).Return(nil, nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name:  "update order status error",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows([]string{"id", "category_id", "quantity", "external_id", "name", "email", "payment_code", "expired_at", "base_price", "platform_fee", "vat_amount", "total_amount"})
+				rows.AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000))
+
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnError(fmt.Errorf("update error"))
+			},
+			expectError: true,
+		},
+		{
+			name:  "no rows affected",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows([]string{"id", "category_id", "quantity", "external_id", "name", "email", "payment_code", "expired_at", "base_price", "platform_fee", "vat_amount", "total_amount"})
+				rows.AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000))
+
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			},
+			expectError: false,
+		},
+		{
+			name:  "publish error",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows([]string{"id", "category_id", "quantity", "external_id", "name", "email", "payment_code", "expired_at", "base_price", "platform_fee", "vat_amount", "total_amount"})
+				rows.AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000))
+
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
 					WillReturnRows(rows)
+
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectAssignOrderTicketRowCol,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			expectError: true,
+		},
+		{
+			name:  "success",
+			input: paidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows([]string{"id", "category_id", "quantity", "external_id", "name", "email", "payment_code", "expired_at", "base_price", "platform_fee", "vat_amount", "total_amount"})
+				rows.AddRow(int32(1), int16(1), int32(1), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(11_000_000), int64(0), int64(0), int64(11_000_000))
+
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectAssignOrderTicketRowCol,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name:  "general admission issue admission numbers error",
+			input: gaPaidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows(orderColumns)
+				rows.AddRow(int32(1), int16(9), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(2_500_000), int64(0), int64(0), int64(5_000_000))
+
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectQuery(issueAdmissionNumbersQuery).
+					WithArgs(int32(2), int16(9)).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name:  "general admission order no longer pending",
+			input: gaPaidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows(orderColumns)
+				rows.AddRow(int32(1), int16(9), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(2_500_000), int64(0), int64(0), int64(5_000_000))
+
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: false,
+		},
+		{
+			name:  "general admission success skips seat assignment",
+			input: gaPaidCallback,
+			setupMock: func(msg []byte) {
+				rows := pgxmock.NewRows(orderColumns)
+				rows.AddRow(int32(1), int16(9), int32(2), "order-123", "John Doe", "john@example.com", "PAY123", fixedTime, int64(2_500_000), int64(0), int64(0), int64(5_000_000))
+
+				s.PgxMock.ExpectQuery(pendingOrderQuery).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectQuery(issueAdmissionNumbersQuery).
+					WithArgs(int32(2), int16(9)).
+					WillReturnRows(pgxmock.NewRows([]string{"last_admission_number"}).AddRow(int32(42)))
+				s.PgxMock.ExpectExec(updateAdmissionNumberQuery).
+					WithArgs(pgtype.Int4{Int32: 41, Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec(updateAdmissionNumberQuery).
+					WithArgs(pgtype.Int4{Int32: 42, Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).DoAndReturn(func(_ context.Context, _ string, payload []byte, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+					var email model.SendEmailEventMessage
+					s.Require().NoError(json.Unmarshal(payload, &email))
+					s.Contains(email.Body, "Admission Numbers: #41, #42")
+					s.NotContains(email.Body, "Seats:")
+					return nil, nil
+				})
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			var msg []byte
+			var err error
+
+			msg, err = json.Marshal(tc.input)
+			s.Require().NoError(err)
+
+			s.orderEvent.Querier = sqlgen.New(s.PgxMock)
+			tc.setupMock(msg)
+			err = s.orderEvent.CompleteHandler(context.Background(), msg)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+			}
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderEventTestSuite) TestRefund() {
+	fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	input := model.RefundPaymentEventMessage{
+		OrderId:               1,
+		ExternalId:            "order-123",
+		ProviderTransactionId: "trx-1",
+		Amount:                11_000_000,
+	}
+	refundRequest := payment.RefundRequest{
+		ExternalId:            "order-123",
+		ProviderTransactionId: "trx-1",
+		Amount:                11_000_000,
+		Reason:                constant.LatePaymentOutcomeRefundRequired,
+	}
+
+	testCases := []struct {
+		name        string
+		setupMock   func()
+		expectError bool
+	}{
+		{
+			name: "gateway error",
+			setupMock: func() {
+				s.gateway.EXPECT().Refund(gomock.Any(), refundRequest).
+					Return(payment.Refund{}, fmt.Errorf("gateway error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "update late payment error",
+			setupMock: func() {
+				s.gateway.EXPECT().Refund(gomock.Any(), refundRequest).
+					Return(payment.Refund{RefundId: "refund-1", Status: payment.RefundStatusSucceeded}, nil)
+
+				s.PgxMock.ExpectExec("UPDATE late_payments").
+					WithArgs("trx-1", pgtype.Text{String: "refund-1", Valid: true}, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "success",
+			setupMock: func() {
+				s.gateway.EXPECT().Refund(gomock.Any(), refundRequest).
+					Return(payment.Refund{RefundId: "refund-1", Status: payment.RefundStatusSucceeded}, nil)
+
+				s.PgxMock.ExpectExec("UPDATE late_payments").
+					WithArgs("trx-1", pgtype.Text{String: "refund-1", Valid: true}, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			msg, err := json.Marshal(input)
+			s.Require().NoError(err)
+
+			s.orderEvent.Querier = sqlgen.New(s.PgxMock)
+			tc.setupMock()
+			err = s.orderEvent.RefundHandler(context.Background(), msg)
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+			}
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderEventTestSuite) TestAllocateSeatsRanking() {
+	categoryLayoutQuery := `SELECT max_row, max_col FROM categories WHERE id = \$1`
+	claimSeatsQuery := `UPDATE seats SET status = 'sold', order_id = \$1::integer, updated_at = NOW\(\) WHERE id IN \(SELECT id FROM seats WHERE category_id = \$2 AND status = 'available' ORDER BY \$3::integer \* row, \$4::integer \* \(2 \* row - \$5::integer - 1\) \* \(2 \* row - \$5::integer - 1\) \+ \$6::integer \* \(2 \* col - \$7::integer - 1\) \* \(2 \* col - \$7::integer - 1\), CASE WHEN \$8::boolean THEN RANDOM\(\) ELSE 0 END, row, col LIMIT \$9::integer FOR UPDATE SKIP LOCKED\) AND status = 'available' RETURNING row, col`
+
+	tests := []struct {
+		name            string
+		strategy        string
+		rowWeight       int32
+		rowCenterWeight int32
+		colCenterWeight int32
+		shuffle         bool
+	}{
+		{
+			name:            "best available",
+			strategy:        seating.StrategyBestAvailable,
+			rowWeight:       1,
+			colCenterWeight: 1,
+		},
+		{
+			name:            "back to front",
+			strategy:        seating.StrategyBackToFront,
+			rowWeight:       -1,
+			colCenterWeight: 1,
+		},
+		{
+			name:            "center out",
+			strategy:        seating.StrategyCenterOut,
+			rowCenterWeight: 1,
+			colCenterWeight: 1,
+		},
+		{
+			name:     "random",
+			strategy: seating.StrategyRandom,
+			shuffle:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			cfg := viper.New()
+			cfg.Set("seat.allocation.default", tc.strategy)
+			seatAllocators := &seating.Allocators{Cfg: cfg}
+			seatAllocators.Init()
+
+			orderEvent := s.orderEvent
+			orderEvent.SeatAllocators = seatAllocators
+
+			s.PgxMock.ExpectQuery(categoryLayoutQuery).
+				WithArgs(int16(1)).
+				WillReturnRows(pgxmock.NewRows([]string{"max_row", "max_col"}).AddRow(int32(3), int32(4)))
+			s.PgxMock.ExpectQuery(claimSeatsQuery).
+				WithArgs(int32(1), int16(1), tc.rowWeight, tc.rowCenterWeight, int32(3), tc.colCenterWeight, int32(4), tc.shuffle, int32(2)).
+				WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).
+					AddRow(int32(2), int32(3)).
+					AddRow(int32(2), int32(2)))
+
+			seats, err := orderEvent.allocateSeats(context.Background(), s.Querier, 1, 1, 2)
+
+			s.NoError(err)
+			s.Equal([]model.SeatPosition{{Row: 2, Col: 2}, {Row: 2, Col: 3}}, seats)
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderEventTestSuite) TestAssignTicketCol() {
+	confirmHeldSeatsQuery := `UPDATE seats SET status = 'sold', updated_at = NOW\(\) WHERE order_id = \$1::integer AND status = 'held' RETURNING row, col`
+	categoryLayoutQuery := `SELECT max_row, max_col FROM categories WHERE id = \$1`
+	issueAdmissionNumbersQuery := `UPDATE categories SET last_admission_number = last_admission_number \+ \$1::integer WHERE id = \$2 RETURNING last_admission_number`
+	updateAdmissionNumberQuery := `UPDATE order_items SET admission_number = \$1`
+	claimSeatsQuery := `UPDATE seats SET status = 'sold', order_id = \$1::integer, updated_at = NOW\(\) WHERE id IN \(SELECT id FROM seats WHERE category_id = \$2 AND status = 'available' ORDER BY (.+) LIMIT \$9::integer FOR UPDATE SKIP LOCKED\) AND status = 'available' RETURNING row, col`
+
+	// ClaimSeats ranks by best available in a 10 by 10 layout, which claims
+	// the center seats 5, 6, 4 of the first row first.
+	expectCategoryLayout := func() {
+		s.PgxMock.ExpectQuery(categoryLayoutQuery).
+			WithArgs(int16(1)).
+			WillReturnRows(pgxmock.NewRows([]string{"max_row", "max_col"}).AddRow(int32(10), int32(10)))
+	}
+	claimSeatsArgs := func(quantity int32) []any {
+		return []any{int32(1), int16(1), int32(1), int32(0), int32(10), int32(1), int32(10), false, quantity}
+	}
+
+	testCases := []struct {
+		name        string
+		input       model.AssignOrderTicketRowCol
+		setupMock   func(msg []byte)
+		expectError bool
+	}{
+		{
+			name: "transaction begin error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin().WillReturnError(fmt.Errorf("begin error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "confirm held seats error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "find category layout error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				s.PgxMock.ExpectQuery(categoryLayoutQuery).
+					WithArgs(int16(1)).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "claim seats error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "no seat available",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "update order item ticket row col error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnError(fmt.Errorf("update error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "order item ticket row col not updated",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "update order ticket row col error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnError(fmt.Errorf("update error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "commit transaction error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(fmt.Errorf("commit error"))
+				s.PgxMock.ExpectRollback().WillReturnError(nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "publish message error",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "success",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name: "success multiple tickets",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Quantity:   2,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(2)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).
+						AddRow(int32(1), int32(6)).
+						AddRow(int32(1), int32(5)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(6), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
 					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(5), Valid: true}, int32(1)).
 					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
@@ -451,6 +1288,164 @@ func (s *OrderEventTestSuite) TestAssignTicketCol() {
 			},
 			expectError: false,
 		},
+		{
+			name: "success seat taken by concurrent worker",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Quantity:   2,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(2)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(6)))
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(4)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(4), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(6), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(4), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			// Seat 1, 5 ranks first but is held by an open seat hold, which
+			// marks it held in the database, so ClaimSeats passes over it.
+			name: "success skips seat of an open hold",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}))
+				expectCategoryLayout()
+				s.PgxMock.ExpectQuery(claimSeatsQuery).
+					WithArgs(claimSeatsArgs(1)...).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).AddRow(int32(1), int32(6)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(6), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(1), Valid: true}, pgtype.Int4{Int32: int32(6), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name: "success held seats",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 1,
+				Quantity:   2,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(confirmHeldSeatsQuery).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col"}).
+						AddRow(int32(2), int32(3)).
+						AddRow(int32(2), int32(2)))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(2), Valid: true}, pgtype.Int4{Int32: int32(2), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE order_items SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(2), Valid: true}, pgtype.Int4{Int32: int32(3), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("UPDATE orders SET (.+)").
+					WithArgs(pgtype.Int4{Int32: int32(2), Valid: true}, pgtype.Int4{Int32: int32(2), Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(nil)
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
+		{
+			name: "general admission admission number not updated",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 9,
+				Quantity:   1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(issueAdmissionNumbersQuery).
+					WithArgs(int32(1), int16(9)).
+					WillReturnRows(pgxmock.NewRows([]string{"last_admission_number"}).AddRow(int32(7)))
+				s.PgxMock.ExpectExec(updateAdmissionNumberQuery).
+					WithArgs(pgtype.Int4{Int32: 7, Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name: "success general admission",
+			input: model.AssignOrderTicketRowCol{
+				ID:         1,
+				CategoryId: 9,
+				Quantity:   1,
+				Email:      "john@example.com",
+				Name:       "John Doe",
+			},
+			setupMock: func(msg []byte) {
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery(issueAdmissionNumbersQuery).
+					WithArgs(int32(1), int16(9)).
+					WillReturnRows(pgxmock.NewRows([]string{"last_admission_number"}).AddRow(int32(7)))
+				s.PgxMock.ExpectExec(updateAdmissionNumberQuery).
+					WithArgs(pgtype.Int4{Int32: 7, Valid: true}, int32(1)).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, nil)
+			},
+			expectError: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,163 @@
+package event
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/common/otel"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
+)
+
+// reserveWaitlistScript issues the claim of the next buyer a ticket was handed
+// to by common.Restock. KEYS are the pending list, the waitlist emails, the
+// claim and the claim expiry index, ARGV is the claim token hash, its expiry
+// in unix seconds, the event id, the category id and the expiry shown to the
+// buyer. It returns the claim, or nil when the pending list is empty.
+var reserveWaitlistScript = redis.NewScript(`
+local entry = redis.call("LPOP", KEYS[1])
+if not entry then
+	return false
+end
+local claim = cjson.decode(entry)
+redis.call("SREM", KEYS[2], claim.email)
+claim.joined_at = nil
+claim.event_id = tonumber(ARGV[3])
+claim.category_id = tonumber(ARGV[4])
+claim.expired_at = ARGV[5]
+local data = cjson.encode(claim)
+redis.call("SET", KEYS[3], data)
+redis.call("ZADD", KEYS[4], ARGV[2], ARGV[1])
+return data
+`)
+
+type WaitlistEvent struct {
+	Cache     *redis.Client
+	Publisher jetstream.Publisher
+
+	// ClaimUrl is formatted with the claim token into the link sent to the
+	// buyer, ClaimTTL is how long the ticket stays reserved for them.
+	ClaimUrl string
+	ClaimTTL time.Duration
+
+	Timeout time.Duration
+	TimeNow func() time.Time
+}
+
+// RestockHandler issues the claims of the buyers restocked tickets were handed
+// to. The pending list is drained rather than only Quantity entries, so
+// entries left by a lost restock message are picked up by the next one.
+func (in WaitlistEvent) RestockHandler(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, in.Timeout)
+	defer cancel()
+
+	var req model.WaitlistRestockEventMessage
+	err := json.Unmarshal(msg, &req)
+	if err != nil {
+		slog.WarnContext(ctx, "waitlist restock event unmarshal error", slog.Any(constant.LogFieldErr, err))
+		return nil
+	}
+
+	ctx, span := otel.Tracer.Start(ctx, "WaitlistEvent.RestockHandler")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	slog.InfoContext(ctx, "waitlist restock event receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
+
+	for {
+		reserved, err := in.reserve(ctx, req.EventId, req.CategoryId)
+		if err != nil {
+			return err
+		}
+
+		if !reserved {
+			break
+		}
+	}
+
+	return nil
+}
+
+// reserve issues a claim to the next pending buyer, it reports false when the
+// pending list is empty.
+func (in WaitlistEvent) reserve(ctx context.Context, eventId, categoryId int16) (bool, error) {
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	token, err := common.GenerateToken()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to generate claim token", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return false, err
+	}
+
+	event, ok := vars.GetEvent(eventId)
+	if !ok {
+		slog.ErrorContext(ctx, "waitlist event not found", slog.Int("event_id", int(eventId)), traceIdAttr)
+		return false, fmt.Errorf("event %d not found", eventId)
+	}
+
+	tokenHash := common.HashToken(token)
+	expiredAt := in.TimeNow().Add(in.ClaimTTL).In(event.Location)
+
+	data, err := reserveWaitlistScript.Run(ctx, in.Cache,
+		[]string{
+			fmt.Sprintf(constant.WaitlistPendingKey, eventId, categoryId),
+			fmt.Sprintf(constant.WaitlistEmailsKey, eventId, categoryId),
+			fmt.Sprintf(constant.WaitlistClaimKey, tokenHash),
+			constant.WaitlistClaimsKey,
+		},
+		tokenHash, expiredAt.Unix(), eventId, categoryId, expiredAt.Format(time.RFC3339),
+	).Text()
+	if err == redis.Nil {
+		slog.DebugContext(ctx, "no waitlist entry to reserve for", traceIdAttr)
+		return false, nil
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to reserve waitlist ticket", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return false, err
+	}
+
+	var claim model.WaitlistClaim
+	if err := json.Unmarshal([]byte(data), &claim); err != nil {
+		slog.ErrorContext(ctx, "failed to unmarshal waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return false, err
+	}
+
+	// The claim is issued from here on, a redelivered restock would issue the
+	// next one, so failures are only logged. A buyer who never gets the email
+	// loses the claim to the expiry cron, which releases the ticket again.
+	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
+		To:      claim.Email,
+		Subject: "Waitlist Ticket Reserved",
+		Body:    in.buildWaitlistClaimEmailBody(claim, token),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish waitlist claim email", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+	}
+
+	slog.InfoContext(ctx, "waitlist ticket reserved", slog.Int("category_id", int(categoryId)), traceIdAttr)
+
+	return true, nil
+}
+
+func (in WaitlistEvent) buildWaitlistClaimEmailBody(claim model.WaitlistClaim, token string) string {
+	category, _ := vars.GetCategory(claim.CategoryId)
+	event, _ := vars.GetEvent(claim.EventId)
+
+	return fmt.Sprintf(constant.EmailWaitlistClaimTemplate,
+		claim.Name,
+		event.Name,
+		category.Name,
+		claim.ExpiredAt,
+		fmt.Sprintf(in.ClaimUrl, token),
+		event.SupportEmail,
+		event.BrandName,
+	)
+}
@@ -0,0 +1,171 @@
+package event
+
+import (
+	"concert-ticket/common/constant"
+	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"context"
+	"fmt"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type WaitlistEventTestSuite struct {
+	suite.Suite
+	ctrl          *gomock.Controller
+	publisher     *jetsteamMock.MockPublisher
+	Cache         *redis.Client
+	CacheMock     redismock.ClientMock
+	waitlistEvent WaitlistEvent
+}
+
+func (s *WaitlistEventTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
+	s.ctrl = gomock.NewController(s.T())
+	s.publisher = jetsteamMock.NewMockPublisher(s.ctrl)
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.waitlistEvent = WaitlistEvent{
+		Cache:     rdb,
+		Publisher: s.publisher,
+		ClaimUrl:  "http://localhost:3000/waitlist/claim?token=%s",
+		ClaimTTL:  10 * time.Minute,
+		Timeout:   10 * time.Second,
+		TimeNow: func() time.Time {
+			return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *WaitlistEventTestSuite) TearDownTest() {
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+	s.ctrl.Finish()
+}
+
+func TestWaitlistEventTestSuite(t *testing.T) {
+	suite.Run(t, new(WaitlistEventTestSuite))
+}
+
+func (s *WaitlistEventTestSuite) TestRestockHandler() {
+	claim := `{"event_id":1,"category_id":9,"name":"Jane Doe","email":"jane@example.com","expired_at":"2023-01-01T00:10:00Z"}`
+
+	expectReserve := func() *redismock.ExpectedCmd {
+		return s.CacheMock.Regexp().ExpectEvalSha(reserveWaitlistScript.Hash(), []string{
+			fmt.Sprintf("^%s$", fmt.Sprintf(constant.WaitlistPendingKey, 1, 9)),
+			fmt.Sprintf("^%s$", fmt.Sprintf(constant.WaitlistEmailsKey, 1, 9)),
+			`^waitlist:claim:[0-9a-f]{64}$`,
+			fmt.Sprintf("^%s$", constant.WaitlistClaimsKey),
+		}, `^[0-9a-f]{64}$`, int64(1672531800), int16(1), int16(9), `^2023-01-01T00:10:00Z$`)
+	}
+
+	expectPublish := func() {
+		s.publisher.EXPECT().Publish(
+			gomock.Any(),
+			constant.SubjectSendEmail,
+			gomock.Any(),
+		).Return(nil, nil)
+	}
+
+	testCases := []struct {
+		name        string
+		msg         string
+		setupMock   func()
+		expectError bool
+	}{
+		{
+			name:        "invalid json",
+			msg:         `{invalid json`,
+			setupMock:   func() {},
+			expectError: false,
+		},
+		{
+			name: "reserve error",
+			msg:  `{"event_id":1,"category_id":9,"quantity":1}`,
+			setupMock: func() {
+				expectReserve().SetErr(fmt.Errorf("redis error"))
+			},
+			expectError: true,
+		},
+		{
+			name: "nothing pending",
+			msg:  `{"event_id":1,"category_id":9,"quantity":2}`,
+			setupMock: func() {
+				expectReserve().RedisNil()
+			},
+			expectError: false,
+		},
+		{
+			name: "drain the pending list",
+			msg:  `{"event_id":1,"category_id":9,"quantity":1}`,
+			setupMock: func() {
+				expectReserve().SetVal(claim)
+				expectPublish()
+				expectReserve().SetVal(claim)
+				expectPublish()
+				expectReserve().RedisNil()
+			},
+			expectError: false,
+		},
+		{
+			name: "publish errors do not undo the claim",
+			msg:  `{"event_id":1,"category_id":9,"quantity":1}`,
+			setupMock: func() {
+				expectReserve().SetVal(claim)
+				s.publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectSendEmail,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+				expectReserve().RedisNil()
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			tc.setupMock()
+
+			err := s.waitlistEvent.RestockHandler(context.Background(), []byte(tc.msg))
+
+			if tc.expectError {
+				s.Error(err)
+			} else {
+				s.NoError(err)
+			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *WaitlistEventTestSuite) TestBuildWaitlistClaimEmailBody() {
+	body := s.waitlistEvent.buildWaitlistClaimEmailBody(model.WaitlistClaim{
+		EventId:    1,
+		CategoryId: 9,
+		Name:       "Jane Doe",
+		Email:      "jane@example.com",
+		ExpiredAt:  "2023-01-01T00:10:00Z",
+	}, "claim-token")
+
+	s.Contains(body, "Jane Doe")
+	s.Contains(body, "Coldplay Music of the Spheres World Tour")
+	s.Contains(body, "Festival")
+	s.Contains(body, "2023-01-01T00:10:00Z")
+	s.Contains(body, "http://localhost:3000/waitlist/claim?token=claim-token")
+}
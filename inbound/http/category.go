@@ -1,10 +1,18 @@
 package http
 
 import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/common/errs"
+	"concert-ticket/common/otel"
 	"concert-ticket/common/vars"
+	"concert-ticket/model"
 	"concert-ticket/outbound/sqlgen"
 	"github.com/redis/go-redis/v9"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type CategoryHttp struct {
@@ -15,11 +23,117 @@ type CategoryHttp struct {
 func RegisterCategoryHttp(mux *http.ServeMux, querier *sqlgen.Queries, cache *redis.Client) *CategoryHttp {
 	in := &CategoryHttp{Querier: querier, Cache: cache}
 
-	mux.HandleFunc("GET /api/categories", in.list)
+	mux.HandleFunc("GET /api/categories/{id}/seats", in.seats)
 
 	return in
 }
 
-func (in *CategoryHttp) list(w http.ResponseWriter, r *http.Request) {
-	writeJSONResponse(w, http.StatusOK, vars.GetCategories())
+// checkOnSale returns a forbidden error when the category cannot be ordered at
+// now, its reason tells clients whether the sale has not started or has ended.
+// The window of a category is already narrowed to the sale window of its
+// event, see FindAllCategories.
+func checkOnSale(category model.CategoryResponse, now time.Time) error {
+	var reason string
+	switch {
+	case now.Before(category.SaleStartsAt):
+		reason = "sale_not_started"
+	case !now.Before(category.SaleEndsAt):
+		reason = "sale_ended"
+	default:
+		return nil
+	}
+
+	return &errs.HttpError{
+		Code:    http.StatusForbidden,
+		Message: "Category is not on sale",
+		Data:    map[string]any{"reason": reason},
+	}
+}
+
+func (in *CategoryHttp) seats(w http.ResponseWriter, r *http.Request) {
+	categoryId, err := strconv.ParseInt(r.PathValue("id"), 10, 16)
+	if err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	category, ok := vars.GetCategory(int16(categoryId))
+	if !ok {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Category not found"})
+		return
+	}
+
+	if category.SeatingType == constant.SeatingTypeGeneralAdmission {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Category has no seat map"})
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "CategoryHttp.seats")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	seats, err := in.Querier.FindSeatsByCategoryId(ctx, int16(categoryId))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find seats", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, buildSeatMap(int16(categoryId), seats))
+}
+
+// buildSeatMap lays the seats out on a grid sized by the highest row and
+// column, positions without a seat are marked with constant.SeatMapNoSeat.
+// The layout lists the seats with their labels and coordinates for venues that
+// do not fit a grid.
+func buildSeatMap(categoryId int16, seats []sqlgen.FindSeatsByCategoryIdRow) model.SeatMapResponse {
+	resp := model.SeatMapResponse{
+		CategoryId: categoryId,
+		Seats:      []string{},
+		Layout:     make([]model.SeatMapSeat, 0, len(seats)),
+	}
+	for _, seat := range seats {
+		resp.Rows = max(resp.Rows, seat.Row)
+		resp.Cols = max(resp.Cols, seat.Col)
+	}
+
+	grid := make([][]byte, resp.Rows)
+	for i := range grid {
+		grid[i] = make([]byte, resp.Cols)
+		for j := range grid[i] {
+			grid[i][j] = constant.SeatMapNoSeat
+		}
+	}
+
+	for _, seat := range seats {
+		var mark byte
+		switch seat.Status {
+		case sqlgen.SeatStatusAvailable:
+			mark = constant.SeatMapAvailable
+			resp.Available++
+		case sqlgen.SeatStatusHeld:
+			mark = constant.SeatMapHeld
+		default:
+			mark = constant.SeatMapSold
+		}
+
+		grid[seat.Row-1][seat.Col-1] = mark
+		resp.Layout = append(resp.Layout, model.SeatMapSeat{
+			Section:   seat.Section,
+			RowLabel:  seat.RowLabel,
+			SeatLabel: seat.SeatLabel,
+			Row:       seat.Row,
+			Col:       seat.Col,
+			X:         seat.X,
+			Y:         seat.Y,
+			Status:    string(seat.Status),
+		})
+	}
+
+	for _, row := range grid {
+		resp.Seats = append(resp.Seats, string(row))
+	}
+
+	return resp
 }
@@ -1,9 +1,11 @@
 package http
 
 import (
+	"concert-ticket/common/constant"
 	"concert-ticket/common/vars"
 	"concert-ticket/model"
 	"concert-ticket/outbound/sqlgen"
+	"fmt"
 	"github.com/go-redis/redismock/v9"
 	"github.com/pashagolub/pgxmock/v4"
 	"github.com/redis/go-redis/v9"
@@ -13,6 +15,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 type CategoryHttpTestSuite struct {
@@ -27,6 +30,76 @@ type CategoryHttpTestSuite struct {
 	Categories []model.CategoryResponse
 }
 
+// testEvents and testCategories stand in for the registry loaded by
+// CategoryCron.
+var testEvents = []model.Event{
+	{
+		Id:           1,
+		Name:         "Coldplay Music of the Spheres World Tour",
+		Venue:        "Gelora Bung Karno Stadium",
+		City:         "Jakarta",
+		Timezone:     "UTC",
+		Location:     time.UTC,
+		StartsAt:     time.Date(2100, 1, 1, 19, 0, 0, 0, time.UTC),
+		SaleStartsAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		SaleEndsAt:   time.Date(2100, 1, 1, 17, 0, 0, 0, time.UTC),
+		OrderPrefix:  "CLDPLY",
+		BrandName:    "Concert Ticket Team",
+		SupportEmail: "support@concert-ticket.com",
+	},
+	{
+		Id:           2,
+		Name:         "Coldplay Music of the Spheres World Tour - Second Night",
+		Venue:        "Gelora Bung Karno Stadium",
+		City:         "Jakarta",
+		Timezone:     "UTC",
+		Location:     time.UTC,
+		StartsAt:     time.Date(2100, 1, 2, 19, 0, 0, 0, time.UTC),
+		SaleStartsAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		SaleEndsAt:   time.Date(2100, 1, 2, 17, 0, 0, 0, time.UTC),
+		OrderPrefix:  "CLDPLY2",
+		BrandName:    "Concert Ticket Team",
+		SupportEmail: "support@concert-ticket.com",
+	},
+}
+
+// testSaleStartsAt and testSaleEndsAt open every test category for the whole
+// sale window of its event.
+var (
+	testSaleStartsAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	testSaleEndsAt   = time.Date(2100, 1, 1, 17, 0, 0, 0, time.UTC)
+)
+
+var testCategories = []model.CategoryResponse{
+	{Id: 1, EventId: 1, Name: "Ultimate Experience", Price: 11_000_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 2, EventId: 1, Name: "My Universe", Price: 7_500_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 3, EventId: 1, Name: "CAT 1", Price: 5_800_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 4, EventId: 1, Name: "CAT 2", Price: 5_200_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 5, EventId: 1, Name: "CAT 3", Price: 4_600_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 6, EventId: 1, Name: "CAT 4", Price: 3_800_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 7, EventId: 1, Name: "CAT 5", Price: 3_000_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 8, EventId: 1, Name: "CAT 6", Price: 1_500_000, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+	{Id: 9, EventId: 1, Name: "Festival", Price: 2_500_000, SeatingType: constant.SeatingTypeGeneralAdmission, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+}
+
+// setCategorySaleWindow narrows the sale window of one category in the
+// registry until the running test ends.
+func setCategorySaleWindow(t *testing.T, id int16, startsAt, endsAt time.Time) {
+	categories := make([]model.CategoryResponse, len(testCategories))
+	copy(categories, testCategories)
+	for i := range categories {
+		if categories[i].Id == id {
+			categories[i].SaleStartsAt = startsAt
+			categories[i].SaleEndsAt = endsAt
+		}
+	}
+
+	vars.SetCategories(categories)
+	t.Cleanup(func() {
+		vars.SetCategories(testCategories)
+	})
+}
+
 func (s *CategoryHttpTestSuite) SetupTest() {
 	rdb, mock := redismock.NewClientMock()
 	s.Cache = rdb
@@ -40,6 +113,9 @@ func (s *CategoryHttpTestSuite) SetupTest() {
 	s.PgxMock = pool
 	s.Querier = sqlgen.New(pool)
 
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 }
 
@@ -55,55 +131,82 @@ func TestCategoryHttpTestSuite(t *testing.T) {
 	suite.Run(t, new(CategoryHttpTestSuite))
 }
 
-func (s *CategoryHttpTestSuite) TestList() {
+func (s *CategoryHttpTestSuite) TestSeats() {
+	seatsQuery := `SELECT row, col, section, row_label, seat_label, x, y, status FROM seats WHERE category_id = \$1 ORDER BY row, col`
+
 	tests := []struct {
 		name           string
-		setupVars      func()
+		categoryId     string
+		setupMock      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
-			name: "success with categories",
-			setupVars: func() {
-				categories := []model.CategoryResponse{
-					{
-						Id:       1,
-						Name:     "Category 1",
-						Price:    100,
-						Quantity: 10,
-					},
-				}
-				vars.SetCategories(categories)
+			name:           "invalid category id",
+			categoryId:     "abc",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "category not found",
+			categoryId:     "99",
+			setupMock:      func() {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Category not found"}`,
+		},
+		{
+			name:           "general admission category",
+			categoryId:     "9",
+			setupMock:      func() {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Category has no seat map"}`,
+		},
+		{
+			name:       "find seats error",
+			categoryId: "1",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(seatsQuery).
+					WithArgs(int16(1)).
+					WillReturnError(fmt.Errorf("database error"))
 			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   `[{"id":1,"name":"Category 1","price":100,"quantity":10}]`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
 		},
 		{
-			name: "success with empty categories",
-			setupVars: func() {
-				vars.SetCategories([]model.CategoryResponse{})
+			name:       "success",
+			categoryId: "1",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(seatsQuery).
+					WithArgs(int16(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"row", "col", "section", "row_label", "seat_label", "x", "y", "status"}).
+						AddRow(int32(1), int32(1), "A", "AA", "1", float32(10), float32(20), sqlgen.SeatStatusSold).
+						AddRow(int32(1), int32(2), "A", "AA", "2", float32(11.5), float32(20.5), sqlgen.SeatStatusAvailable).
+						AddRow(int32(2), int32(2), "A", "BB", "2", float32(11.5), float32(22), sqlgen.SeatStatusHeld))
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `null`,
+			expectedBody: `{"category_id":1,"rows":2,"cols":2,"available":1,"seats":["SA","-H"],"layout":[` +
+				`{"section":"A","row_label":"AA","seat_label":"1","row":1,"col":1,"x":10,"y":20,"status":"sold"},` +
+				`{"section":"A","row_label":"AA","seat_label":"2","row":1,"col":2,"x":11.5,"y":20.5,"status":"available"},` +
+				`{"section":"A","row_label":"BB","seat_label":"2","row":2,"col":2,"x":11.5,"y":22,"status":"held"}]}`,
 		},
 	}
 
 	for _, tc := range tests {
 		s.Run(tc.name, func() {
-			// Setup test data in vars package
-			tc.setupVars()
+			tc.setupMock()
 
 			categoryHttp := RegisterCategoryHttp(http.NewServeMux(), s.Querier, s.Cache)
 
-			req := httptest.NewRequest(http.MethodGet, "/api/categories", nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/categories/"+tc.categoryId+"/seats", nil)
+			req.SetPathValue("id", tc.categoryId)
 			w := httptest.NewRecorder()
 
-			categoryHttp.list(w, req)
+			categoryHttp.seats(w, req)
 
 			s.Equal(tc.expectedStatus, w.Code)
-
-			actual := strings.TrimSpace(w.Body.String())
-			s.Equal(tc.expectedBody, actual)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+			s.NoError(s.PgxMock.ExpectationsWereMet())
 		})
 	}
 }
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"concert-ticket/common/errs"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type EventHttp struct {
+	TimeNow func() time.Time
+}
+
+func RegisterEventHttp(mux *http.ServeMux) *EventHttp {
+	in := &EventHttp{
+		TimeNow: time.Now,
+	}
+
+	mux.HandleFunc("GET /api/events", in.list)
+	mux.HandleFunc("GET /api/events/{id}/categories", in.categories)
+
+	return in
+}
+
+// list returns every event, its times are given in the timezone of the event.
+func (in *EventHttp) list(w http.ResponseWriter, r *http.Request) {
+	events := vars.GetEvents()
+
+	resp := make([]model.EventResponse, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, model.EventResponse{
+			Id:           event.Id,
+			Name:         event.Name,
+			Venue:        event.Venue,
+			City:         event.City,
+			Timezone:     event.Timezone,
+			StartsAt:     event.StartsAt.Format(time.RFC3339),
+			SaleStartsAt: event.SaleStartsAt.Format(time.RFC3339),
+			SaleEndsAt:   event.SaleEndsAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+// categories returns the categories of an event with their sale window, the
+// window and the server time are given in the timezone of the event.
+func (in *EventHttp) categories(w http.ResponseWriter, r *http.Request) {
+	eventId, err := strconv.ParseInt(r.PathValue("id"), 10, 16)
+	if err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	event, ok := vars.GetEvent(int16(eventId))
+	if !ok {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Event not found"})
+		return
+	}
+
+	now := in.TimeNow().In(event.Location)
+
+	resp := model.ListCategoriesResponse{
+		ServerTime: now.Format(time.RFC3339),
+		Categories: []model.CategoryResponse{},
+	}
+	for _, category := range vars.GetCategories() {
+		if category.EventId == int16(eventId) {
+			category.OnSale = category.OnSaleAt(now)
+			resp.Categories = append(resp.Categories, category)
+		}
+	}
+
+	writeJSONResponse(w, http.StatusOK, resp)
+}
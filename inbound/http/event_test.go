@@ -0,0 +1,170 @@
+package http
+
+import (
+	"concert-ticket/common/constant"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"github.com/stretchr/testify/suite"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type EventHttpTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventHttpTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func TestEventHttpTestSuite(t *testing.T) {
+	suite.Run(t, new(EventHttpTestSuite))
+}
+
+func (s *EventHttpTestSuite) TestList() {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name           string
+		setupVars      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success with events in their timezone",
+			setupVars: func() {
+				vars.SetEvents([]model.Event{
+					{
+						Id:           1,
+						Name:         "Event 1",
+						Venue:        "Venue 1",
+						City:         "Jakarta",
+						Timezone:     "Asia/Jakarta",
+						Location:     jakarta,
+						StartsAt:     time.Date(2023, 11, 15, 19, 0, 0, 0, jakarta),
+						SaleStartsAt: time.Date(2023, 1, 1, 10, 0, 0, 0, jakarta),
+						SaleEndsAt:   time.Date(2023, 11, 15, 17, 0, 0, 0, jakarta),
+						OrderPrefix:  "EVT",
+					},
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":1,"name":"Event 1","venue":"Venue 1","city":"Jakarta","timezone":"Asia/Jakarta","starts_at":"2023-11-15T19:00:00+07:00","sale_starts_at":"2023-01-01T10:00:00+07:00","sale_ends_at":"2023-11-15T17:00:00+07:00"}]`,
+		},
+		{
+			name: "success with empty events",
+			setupVars: func() {
+				vars.SetEvents(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupVars()
+
+			eventHttp := RegisterEventHttp(http.NewServeMux())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+			w := httptest.NewRecorder()
+
+			eventHttp.list(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+
+			actual := strings.TrimSpace(w.Body.String())
+			s.Equal(tc.expectedBody, actual)
+		})
+	}
+}
+
+func (s *EventHttpTestSuite) TestCategories() {
+	tests := []struct {
+		name           string
+		eventId        string
+		setupVars      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "invalid event id",
+			eventId:        "abc",
+			setupVars:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "event not found",
+			eventId:        "99",
+			setupVars:      func() {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Event not found"}`,
+		},
+		{
+			name:    "success with categories of the event only",
+			eventId: "1",
+			setupVars: func() {
+				vars.SetEvents(append(testEvents, model.Event{Id: 2, Name: "Event 2"}))
+				vars.SetCategories([]model.CategoryResponse{
+					{Id: 1, EventId: 1, Name: "Category 1", Price: 100, Quantity: 10, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+					{Id: 2, EventId: 2, Name: "Category 2", Price: 200, Quantity: 20, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: testSaleEndsAt},
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"server_time":"2023-01-01T00:00:00Z","categories":[{"id":1,"event_id":1,"name":"Category 1","price":100,"quantity":10,"seating_type":"reserved","sale_starts_at":"2022-01-01T00:00:00Z","sale_ends_at":"2100-01-01T17:00:00Z","on_sale":true}]}`,
+		},
+		{
+			name:    "success with sale windows not open or closed",
+			eventId: "1",
+			setupVars: func() {
+				vars.SetCategories([]model.CategoryResponse{
+					{Id: 1, EventId: 1, Name: "Category 1", Price: 100, Quantity: 10, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: time.Date(2023, 1, 1, 0, 0, 1, 0, time.UTC), SaleEndsAt: testSaleEndsAt},
+					{Id: 2, EventId: 1, Name: "Category 2", Price: 200, Quantity: 20, SeatingType: constant.SeatingTypeReserved, SaleStartsAt: testSaleStartsAt, SaleEndsAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"server_time":"2023-01-01T00:00:00Z","categories":[{"id":1,"event_id":1,"name":"Category 1","price":100,"quantity":10,"seating_type":"reserved","sale_starts_at":"2023-01-01T00:00:01Z","sale_ends_at":"2100-01-01T17:00:00Z","on_sale":false},{"id":2,"event_id":1,"name":"Category 2","price":200,"quantity":20,"seating_type":"reserved","sale_starts_at":"2022-01-01T00:00:00Z","sale_ends_at":"2023-01-01T00:00:00Z","on_sale":false}]}`,
+		},
+		{
+			name:    "success with empty categories",
+			eventId: "1",
+			setupVars: func() {
+				vars.SetCategories(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"server_time":"2023-01-01T00:00:00Z","categories":[]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			tc.setupVars()
+
+			eventHttp := RegisterEventHttp(http.NewServeMux())
+			eventHttp.TimeNow = func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/events/"+tc.eventId+"/categories", nil)
+			req.SetPathValue("id", tc.eventId)
+			w := httptest.NewRecorder()
+
+			eventHttp.categories(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+
+			actual := strings.TrimSpace(w.Body.String())
+			s.Equal(tc.expectedBody, actual)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"concert-ticket/common/errs"
+	"crypto/subtle"
 	"net/http"
 	"time"
 )
@@ -15,7 +17,7 @@ func CorsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -25,3 +27,24 @@ func CorsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// AdminAuthMiddleware only lets requests carrying the admin bearer token
+// through. An empty token disables the admin routes altogether.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := bearerToken(r)
+			if given == "" {
+				writeErrorResponse(w, &errs.HttpError{Code: http.StatusUnauthorized, Message: "Unauthorized"})
+				return
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeErrorResponse(w, &errs.HttpError{Code: http.StatusForbidden, Message: "Forbidden"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,7 +33,7 @@ func (s *MiddlewareTestSuite) TestCorsMiddleware() {
 			expectedHeaders: map[string]string{
 				"Access-Control-Allow-Origin":  "*",
 				"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
-				"Access-Control-Allow-Headers": "Content-Type, Authorization",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization, Idempotency-Key",
 			},
 			handlerCalled: false,
 		},
@@ -43,7 +44,7 @@ func (s *MiddlewareTestSuite) TestCorsMiddleware() {
 			expectedHeaders: map[string]string{
 				"Access-Control-Allow-Origin":  "*",
 				"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
-				"Access-Control-Allow-Headers": "Content-Type, Authorization",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization, Idempotency-Key",
 			},
 			handlerCalled: true,
 		},
@@ -117,3 +118,66 @@ func (s *MiddlewareTestSuite) TestTimeoutMiddleware() {
 		})
 	}
 }
+
+func (s *MiddlewareTestSuite) TestAdminAuthMiddleware() {
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+		handlerCalled  bool
+	}{
+		{
+			name:           "missing token",
+			token:          "admin-token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:           "wrong token",
+			token:          "admin-token",
+			authorization:  "Bearer other-token",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Forbidden"}`,
+		},
+		{
+			name:           "admin routes disabled",
+			token:          "",
+			authorization:  "Bearer admin-token",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Forbidden"}`,
+		},
+		{
+			name:           "valid token",
+			token:          "admin-token",
+			authorization:  "Bearer admin-token",
+			expectedStatus: http.StatusOK,
+			handlerCalled:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			handlerCalled := false
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerCalled = true
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := AdminAuthMiddleware(tc.token)(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/test", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			middleware.ServeHTTP(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+			s.Equal(tc.handlerCalled, handlerCalled)
+		})
+	}
+}
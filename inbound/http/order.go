@@ -3,13 +3,19 @@ package http
 import (
 	"concert-ticket/common"
 	"concert-ticket/common/constant"
+	"concert-ticket/common/contract"
 	"concert-ticket/common/errs"
 	"concert-ticket/common/otel"
+	"concert-ticket/common/pricing"
+	"concert-ticket/common/vars"
 	"concert-ticket/model"
+	"concert-ticket/outbound/payment"
 	"concert-ticket/outbound/sqlgen"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/oklog/ulid/v2"
@@ -18,56 +24,231 @@ import (
 	"golang.org/x/text/message"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 )
 
 type OrderHttp struct {
+	Db                   contract.DbConn
 	Querier              *sqlgen.Queries
 	Cache                *redis.Client
 	Publisher            jetstream.Publisher
+	PaymentGateway       payment.PaymentGateway
 	Validate             *validator.Validate
 	IdrCurrencyFormatter *message.Printer
 
 	TimeNow func() time.Time
 
-	sizeBulkCancel int32
-	expiredAfter   time.Duration
+	maxQuantity int32
+
+	priceCalculator *pricing.Calculator
+
+	// paymentMethods holds the enabled methods and how long the buyer has to
+	// pay with each, categoryPaymentMethods restricts some categories to a
+	// subset of them. defaultPaymentMethod is used for orders that do not
+	// name a method, as clients did before methods could be chosen.
+	paymentMethods         map[string]time.Duration
+	categoryPaymentMethods map[int16][]string
+	defaultPaymentMethod   string
 }
 
 func RegisterOrderHttp(
 	mux *http.ServeMux,
 	cfg *viper.Viper,
+	db contract.DbConn,
 	querier *sqlgen.Queries,
 	cache *redis.Client,
 	publisher jetstream.Publisher,
+	paymentGateway payment.PaymentGateway,
 	validate *validator.Validate,
 	idrCurrencyFormatter *message.Printer,
 ) *OrderHttp {
 	in := &OrderHttp{
+		Db:                   db,
 		Querier:              querier,
 		Cache:                cache,
 		Publisher:            publisher,
+		PaymentGateway:       paymentGateway,
 		Validate:             validate,
 		IdrCurrencyFormatter: idrCurrencyFormatter,
 		TimeNow:              time.Now,
 
-		sizeBulkCancel: cfg.GetInt32("order.bulk_cancel_size"),
-		expiredAfter:   cfg.GetDuration("order.expired_after"),
+		maxQuantity: cfg.GetInt32("order.max_quantity"),
+
+		priceCalculator: newPriceCalculator(cfg),
+
+		paymentMethods:         loadPaymentMethods(cfg),
+		categoryPaymentMethods: loadCategoryPaymentMethods(cfg),
+		defaultPaymentMethod:   cfg.GetString("payment.default_method"),
 	}
 
-	mux.HandleFunc("POST /api/orders", in.create)
-	mux.HandleFunc("POST /api/orders/cancel", in.cancel)
+	mux.HandleFunc("POST /api/events/{id}/orders", in.create)
+	mux.HandleFunc("GET /api/orders/{external_id}", in.get)
+	mux.HandleFunc("POST /api/orders/{external_id}/cancel", in.cancelByExternalId)
+	mux.HandleFunc("POST /api/waitlist/claims/{token}", in.claimWaitlist)
 
 	return in
 }
 
+func newPriceCalculator(cfg *viper.Viper) *pricing.Calculator {
+	calculator := &pricing.Calculator{Cfg: cfg}
+	calculator.Init()
+	return calculator
+}
+
 func (in OrderHttp) create(w http.ResponseWriter, r *http.Request) {
+	eventId, err := strconv.ParseInt(r.PathValue("id"), 10, 16)
+	if err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
 	var req model.CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
 		return
 	}
 
+	req.EventId = int16(eventId)
+
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = in.defaultPaymentMethodFor(req.CategoryId)
+	}
+
+	idempotencyKey := r.Header.Get(constant.HeaderIdempotencyKey)
+	if idempotencyKey != "" {
+		in.createIdempotent(w, r, req, idempotencyKey)
+		return
+	}
+
+	in.createOrder(w, r, req, false)
+}
+
+// createIdempotent runs createOrder at most once per Idempotency-Key of a buyer,
+// the key is scoped to the event and email so another client reusing it cannot
+// read the order. The first response is stored in cache and replayed for
+// retries carrying the same payload, without the cancel token.
+func (in OrderHttp) createIdempotent(w http.ResponseWriter, r *http.Request, req model.CreateOrderRequest, idempotencyKey string) {
+	ctx := r.Context()
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	reqHash, err := hashRequest(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to hash request", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	cacheKey := fmt.Sprintf(constant.OrderIdempotencyKey, req.EventId, req.Email, idempotencyKey)
+	inProgress, err := json.Marshal(model.IdempotencyRecord{RequestHash: reqHash})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	acquired, err := in.Cache.SetNX(ctx, cacheKey, string(inProgress), constant.OrderIdempotencyInProgressTTL).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to set idempotency key", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if !acquired {
+		in.replayIdempotent(w, r, cacheKey, reqHash)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	in.createOrder(rec, r, req, false)
+
+	// Server errors are not final, so the key is released to let the client retry.
+	if rec.status >= http.StatusInternalServerError {
+		if err := in.Cache.Del(ctx, cacheKey).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to delete idempotency key", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+		return
+	}
+
+	body := rec.body.String()
+	if rec.status == http.StatusOK {
+		body, err = withoutCancelToken(body)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to remove cancel token from idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			return
+		}
+	}
+
+	completed, err := json.Marshal(model.IdempotencyRecord{RequestHash: reqHash, Status: rec.status, Body: body})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		return
+	}
+
+	if err := in.Cache.Set(ctx, cacheKey, string(completed), constant.OrderIdempotencyDefaultTTL).Err(); err != nil {
+		slog.ErrorContext(ctx, "failed to store idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+	}
+}
+
+// withoutCancelToken drops the cancel token from a create order response, it
+// is only handed to the request that placed the order.
+func withoutCancelToken(body string) (string, error) {
+	var resp model.CreateOrderResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return "", err
+	}
+
+	resp.CancelToken = ""
+	data, err := json.Marshal(resp)
+	return string(data), err
+}
+
+func (in OrderHttp) replayIdempotent(w http.ResponseWriter, r *http.Request, cacheKey, reqHash string) {
+	ctx := r.Context()
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	data, err := in.Cache.Get(ctx, cacheKey).Result()
+	if err != nil && err != redis.Nil {
+		slog.ErrorContext(ctx, "failed to get idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	var record model.IdempotencyRecord
+	if err == nil {
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			slog.ErrorContext(ctx, "failed to unmarshal idempotency record", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+	}
+
+	if record.RequestHash != "" && record.RequestHash != reqHash {
+		slog.DebugContext(ctx, "idempotency key reused with different payload", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusUnprocessableEntity, Message: "Idempotency key reused with different payload"})
+		return
+	}
+
+	if record.Status == 0 {
+		slog.DebugContext(ctx, "idempotency key in progress", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Request with the same idempotency key is in progress"})
+		return
+	}
+
+	slog.DebugContext(ctx, "replaying idempotent response", traceIdAttr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(constant.HeaderIdempotentReplayed, "true")
+	w.WriteHeader(record.Status)
+	w.Write([]byte(record.Body))
+}
+
+func (in OrderHttp) createOrder(w http.ResponseWriter, r *http.Request, req model.CreateOrderRequest, reserved bool) {
 	if err := in.validateCreateOrderRequest(req); err != nil {
 		writeErrorResponse(w, err)
 		return
@@ -79,7 +260,42 @@ func (in OrderHttp) create(w http.ResponseWriter, r *http.Request) {
 	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
 	slog.InfoContext(ctx, "create order receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
 
-	emailLock, err := in.Cache.SetNX(ctx, fmt.Sprintf(constant.OrderEmailLock, req.Email), true, constant.OrderEmailLockDefaultTTL).Result()
+	// Priced before anything is reserved, the category may have been removed
+	// since the request was validated.
+	price, ok := in.priceCalculator.Calculate(req.CategoryId, req.Quantity)
+	if !ok {
+		slog.ErrorContext(ctx, "failed to price order, category not found", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"CategoryId": "not found",
+			},
+		})
+		return
+	}
+
+	// A seat hold is checked before anything is reserved, its seats are
+	// only claimed in the order transaction below.
+	var seatHold model.SeatHold
+	if req.HoldId != "" {
+		hold, err := getSeatHold(ctx, in.Cache, req.HoldId)
+		if err != nil && err != redis.Nil {
+			slog.ErrorContext(ctx, "failed to get seat hold", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if err := validateSeatHold(req, hold, err == redis.Nil); err != nil {
+			writeErrorResponse(w, err)
+			return
+		}
+
+		seatHold = hold
+	}
+
+	emailLockKey := fmt.Sprintf(constant.OrderEmailLock, req.EventId, req.Email)
+	emailLock, err := in.Cache.SetNX(ctx, emailLockKey, true, constant.OrderEmailLockDefaultTTL).Result()
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to set email lock", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
@@ -92,7 +308,23 @@ func (in OrderHttp) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	emailExist, err := in.Querier.FindOrderByEmailAndStatusPending(ctx, req.Email)
+	// The lock is only kept for a committed order, an attempt that fails
+	// before that releases it so the buyer can retry right away.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+
+		if err := in.Cache.Del(ctx, emailLockKey).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to delete email lock", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	emailExist, err := in.Querier.FindOrderByEmailAndStatusPending(ctx, sqlgen.FindOrderByEmailAndStatusPendingParams{
+		EventID: req.EventId,
+		Email:   req.Email,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to find order by email", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
@@ -105,59 +337,116 @@ func (in OrderHttp) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	atomicVal, err := in.Cache.Decr(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, req.CategoryId)).Result()
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to decrement category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
-		writeErrorResponse(w, err)
-		return
-	}
+	// A claimed waitlist ticket was already taken from the counter when it was
+	// reserved for the buyer.
+	if !reserved {
+		var atomicVal int64
+		atomicVal, err = in.Cache.DecrBy(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, req.EventId, req.CategoryId), int64(req.Quantity)).Result()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to decrement category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if atomicVal < 0 {
+			slog.DebugContext(ctx, "category sold out", traceIdAttr)
+
+			redisErr := in.Cache.IncrBy(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, req.EventId, req.CategoryId), int64(req.Quantity)).Err()
+			if redisErr != nil {
+				slog.ErrorContext(ctx, "failed to increment category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, redisErr))
+			}
 
-	if atomicVal < 0 {
-		slog.DebugContext(ctx, "category sold out", traceIdAttr)
+			writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Category sold out"})
+			return
+		}
 
-		redisErr := in.Cache.Incr(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, req.CategoryId)).Err()
-		if redisErr != nil {
-			slog.ErrorContext(ctx, "failed to increment category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, redisErr))
+		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectIncrementCategoryQuantity, model.IncrementCategoryQuantityEventMessage{
+			ID:       req.CategoryId,
+			Quantity: -req.Quantity,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
 		}
 
-		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Category sold out"})
+		defer func() {
+			if err != nil {
+				err2 := common.PublishMessage(ctx, in.Publisher, constant.SubjectIncrementCategoryQuantity, model.IncrementCategoryQuantityEventMessage{
+					ID:       req.CategoryId,
+					Quantity: req.Quantity,
+				})
+				if err2 != nil {
+					slog.ErrorContext(ctx, "failed to publish increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err2))
+				}
+			}
+		}()
+	}
+
+	cancelToken, err := common.GenerateToken()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to generate cancel token", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
 		return
 	}
 
-	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectIncrementCategoryQuantity, model.IncrementCategoryQuantityEventMessage{
-		ID:       req.CategoryId,
-		Quantity: -1,
+	externalId := ulid.Make().String()
+	expiredAt := in.TimeNow().Add(in.paymentMethods[req.PaymentMethod])
+
+	charge, err := in.PaymentGateway.CreateCharge(ctx, payment.CreateChargeRequest{
+		ExternalId: externalId,
+		Method:     req.PaymentMethod,
+		Amount:     price.Total,
+		ExpiredAt:  expiredAt,
+		Customer:   payment.Customer{Name: req.Name, Email: req.Email},
 	})
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to publish increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		slog.ErrorContext(ctx, "failed to create payment charge", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
 		return
 	}
 
+	vaCode := charge.PaymentCode
+
 	defer func() {
 		if err != nil {
-			err2 := common.PublishMessage(ctx, in.Publisher, constant.SubjectIncrementCategoryQuantity, model.IncrementCategoryQuantityEventMessage{
-				ID:       req.CategoryId,
-				Quantity: 1,
-			})
+			err2 := in.PaymentGateway.CancelCharge(ctx, vaCode)
 			if err2 != nil {
-				slog.ErrorContext(ctx, "failed to publish increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err2))
+				slog.ErrorContext(ctx, "failed to cancel payment charge", traceIdAttr, slog.Any(constant.LogFieldErr, err2))
 			}
 		}
 	}()
 
-	externalId := ulid.Make().String()
-	price, _ := constant.CategoryPriceById[req.CategoryId]
-	vaCode := generateDummyPaymentCode(externalId, price)
-
-	expiredAt := in.TimeNow().Add(in.expiredAfter)
-	returnId, err := in.Querier.InsertOrder(ctx, sqlgen.InsertOrderParams{
-		CategoryID:  req.CategoryId,
-		ExternalID:  externalId,
-		Name:        req.Name,
-		Email:       req.Email,
-		PaymentCode: vaCode,
-		ExpiredAt:   pgtype.Timestamp{Time: expiredAt, Valid: true},
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	withTx := in.Querier.WithTx(tx)
+
+	returnId, err := withTx.InsertOrder(ctx, sqlgen.InsertOrderParams{
+		EventID:         req.EventId,
+		CategoryID:      req.CategoryId,
+		Quantity:        req.Quantity,
+		ExternalID:      externalId,
+		Name:            req.Name,
+		Email:           req.Email,
+		PaymentCode:     vaCode,
+		PaymentMethod:   req.PaymentMethod,
+		CancelTokenHash: common.HashToken(cancelToken),
+		ExpiredAt:       pgtype.Timestamp{Time: expiredAt, Valid: true},
+		BasePrice:       price.BasePrice,
+		PlatformFee:     price.PlatformFee,
+		VatAmount:       price.Vat,
+		TotalAmount:     price.Total,
 	})
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to insert order", traceIdAttr, slog.Any(constant.LogFieldErr, err))
@@ -165,101 +454,342 @@ func (in OrderHttp) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectCreateOrder, model.CreateOrderEventMessage{
-		ID:          returnId,
-		CategoryID:  req.CategoryId,
-		ExternalID:  externalId,
-		Name:        req.Name,
-		Email:       req.Email,
-		PaymentCode: vaCode,
-		ExpiredAt:   expiredAt.Format(time.RFC3339),
+	if req.HoldId != "" {
+		err = holdOrderSeats(ctx, withTx, returnId, req.CategoryId, req.HoldId, seatHold)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to hold seats", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+	}
+
+	// The create order event is relayed to the queue by serve-outbox, so it is
+	// only emitted when the order itself is committed. Its expiry is shown to
+	// the buyer in the timezone of the event.
+	event, _ := vars.GetEvent(req.EventId)
+	createOrderPayload, err := json.Marshal(model.CreateOrderEventMessage{
+		ID:            returnId,
+		CategoryID:    req.CategoryId,
+		Quantity:      req.Quantity,
+		ExternalID:    externalId,
+		Name:          req.Name,
+		Email:         req.Email,
+		PaymentMethod: req.PaymentMethod,
+		PaymentCode:   vaCode,
+		ExpiredAt:     expiredAt.In(event.Location).Format(time.RFC3339),
+		Price:         price,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal create order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	err = withTx.InsertOutbox(ctx, sqlgen.InsertOutboxParams{
+		Subject: constant.SubjectCreateOrder,
+		Payload: createOrderPayload,
 	})
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to publish create order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		slog.ErrorContext(ctx, "failed to insert create order outbox", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
 		return
 	}
 
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	committed = true
+
 	slog.InfoContext(ctx, "insert order success", traceIdAttr, slog.Any(constant.LogFieldResponse, returnId))
 
+	// The seats are held by the order from here on, a hold left in cache
+	// only expires on its own.
+	if req.HoldId != "" {
+		if err := releaseSeatHold(ctx, in.Cache, req.HoldId); err != nil {
+			slog.ErrorContext(ctx, "failed to release seat hold", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}
+
 	writeJSONResponse(w, http.StatusOK, model.CreateOrderResponse{
-		Id:          returnId,
-		ExternalId:  externalId,
-		PaymentCode: vaCode,
+		Id:            returnId,
+		ExternalId:    externalId,
+		PaymentMethod: req.PaymentMethod,
+		PaymentCode:   vaCode,
+		ExpiredAt:     expiredAt.Format(time.RFC3339),
+		CancelToken:   cancelToken,
+		Price:         price,
 	})
 }
 
-func (in OrderHttp) cancel(w http.ResponseWriter, r *http.Request) {
-	ctx, span := otel.Tracer.Start(r.Context(), "OrderHttp.cancel")
+func (in OrderHttp) cancelByExternalId(w http.ResponseWriter, r *http.Request) {
+	externalId := r.PathValue("external_id")
+	if externalId == "" {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusUnauthorized, Message: "Unauthorized"})
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "OrderHttp.cancelByExternalId")
 	defer span.End()
 
 	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
-	slog.InfoContext(ctx, "cancel order receive request", traceIdAttr)
+	slog.InfoContext(ctx, "cancel order by external id receive request", slog.String("external_id", externalId), traceIdAttr)
 
-	cancelableOrders, err := in.Querier.BulkCancelOrders(ctx, sqlgen.BulkCancelOrdersParams{
-		Limit:     in.sizeBulkCancel,
-		UpdatedAt: pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+	tokenHash := common.HashToken(token)
+	order, err := in.Querier.CancelOrderByExternalIdAndCancelToken(ctx, sqlgen.CancelOrderByExternalIdAndCancelTokenParams{
+		ExternalID:      externalId,
+		CancelTokenHash: tokenHash,
+		UpdatedAt:       pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
 	})
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "failed to cancel order", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if err == pgx.ErrNoRows {
+		_, err = in.Querier.FindOrderStatusByExternalIdAndCancelToken(ctx, sqlgen.FindOrderStatusByExternalIdAndCancelTokenParams{
+			ExternalID:      externalId,
+			CancelTokenHash: tokenHash,
+		})
+		if err != nil && err != pgx.ErrNoRows {
+			slog.ErrorContext(ctx, "failed to find order status", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if err == pgx.ErrNoRows {
+			slog.DebugContext(ctx, "order not found", traceIdAttr)
+			writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Order not found"})
+			return
+		}
+
+		slog.DebugContext(ctx, "order is not pending", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Order is not pending"})
+		return
+	}
+
+	waitlisted, err := common.Restock(ctx, in.Cache, order.EventID, order.CategoryID, order.Quantity)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to restock category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	err = in.Cache.Del(ctx, fmt.Sprintf(constant.OrderEmailLock, order.EventID, order.Email)).Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete email lock", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	payment.CancelOpenCharge(ctx, in.PaymentGateway, order.PaymentCode)
+
+	err = common.PublishRestock(ctx, in.Publisher, order.EventID, order.CategoryID, order.Quantity, waitlisted)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to find cancelable orders", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
 		return
 	}
 
-	if len(cancelableOrders) == 0 {
-		slog.DebugContext(ctx, "no cancelable orders", traceIdAttr)
-		writeJSONResponse(w, http.StatusOK, nil)
+	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
+		To:      order.Email,
+		Subject: "Order Cancellation",
+		Body:    common.BuildOrderCancellationEmailBody(in.IdrCurrencyFormatter, order.ID, order.CategoryID, order.Quantity, order.Name, order.TotalAmount),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish cancel order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	slog.InfoContext(ctx, "cancel order by external id success", traceIdAttr)
+
+	writeJSONResponse(w, http.StatusOK, nil)
+}
+
+// claimWaitlist turns the ticket reserved for a waitlisted buyer into a pending
+// order. The claim is taken out of the expiry index while the order is
+// created, so the expiry cron cannot restock it meanwhile, and is put back
+// when the order fails so the buyer can try again.
+func (in OrderHttp) claimWaitlist(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if token == "" {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	var req model.ClaimWaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "OrderHttp.claimWaitlist")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+	slog.InfoContext(ctx, "claim waitlist receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
+
+	tokenHash := common.HashToken(token)
+	claimKey := fmt.Sprintf(constant.WaitlistClaimKey, tokenHash)
+
+	data, err := in.Cache.Get(ctx, claimKey).Result()
+	if err != nil && err != redis.Nil {
+		slog.ErrorContext(ctx, "failed to get waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
 		return
 	}
 
-	categoryIdValMap := make(map[int16]int32)
-	for _, order := range cancelableOrders {
-		categoryIdValMap[order.CategoryID]++
+	if err == redis.Nil {
+		slog.DebugContext(ctx, "waitlist claim not found", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Claim not found"})
+		return
 	}
 
-	pipeline := in.Cache.Pipeline()
-	for categoryId, val := range categoryIdValMap {
-		pipeline.IncrBy(ctx, fmt.Sprintf(constant.EachCategoryQuantityKey, categoryId), int64(val))
+	var claim model.WaitlistClaim
+	if err := json.Unmarshal([]byte(data), &claim); err != nil {
+		slog.ErrorContext(ctx, "failed to unmarshal waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
 	}
 
-	_, err = pipeline.Exec(ctx)
+	expiredAt, err := time.Parse(time.RFC3339, claim.ExpiredAt)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to increment category quantity", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		slog.ErrorContext(ctx, "failed to parse waitlist claim expiry", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
 		return
 	}
 
-	incrementPayload := make([]model.IncrementCategoryQuantityEventMessage, 0, len(categoryIdValMap))
-	for categoryId, val := range categoryIdValMap {
-		incrementPayload = append(incrementPayload, model.IncrementCategoryQuantityEventMessage{
-			ID:       categoryId,
-			Quantity: val,
-		})
+	if !in.TimeNow().Before(expiredAt) {
+		slog.DebugContext(ctx, "waitlist claim expired", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusGone, Message: "Claim expired"})
+		return
 	}
 
-	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectBulkIncrementCategoryQuantity, incrementPayload)
+	removed, err := in.Cache.ZRem(ctx, constant.WaitlistClaimsKey, tokenHash).Result()
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to publish bulk increment category quantity message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		slog.ErrorContext(ctx, "failed to take waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 		writeErrorResponse(w, err)
 		return
 	}
 
-	for _, order := range cancelableOrders {
-		err = common.PublishMessage(ctx, in.Publisher, constant.SubjectSendEmail, model.SendEmailEventMessage{
-			To:      order.Email,
-			Subject: "Order Cancellation",
-			Body:    in.buildOrderCancellationEmailBody(order),
-		})
+	if removed == 0 {
+		slog.DebugContext(ctx, "waitlist claim already used", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Claim already used"})
+		return
+	}
+
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = in.defaultPaymentMethodFor(claim.CategoryId)
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	in.createOrder(rec, r, model.CreateOrderRequest{
+		EventId:       claim.EventId,
+		Name:          claim.Name,
+		Email:         claim.Email,
+		CategoryId:    claim.CategoryId,
+		Quantity:      1,
+		PaymentMethod: req.PaymentMethod,
+	}, true)
+
+	if rec.status != http.StatusOK {
+		err = in.Cache.ZAdd(ctx, constant.WaitlistClaimsKey, redis.Z{Score: float64(expiredAt.Unix()), Member: tokenHash}).Err()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to restore waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+		return
+	}
+
+	if err := in.Cache.Del(ctx, claimKey).Err(); err != nil {
+		slog.ErrorContext(ctx, "failed to delete waitlist claim", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+	}
+
+	slog.InfoContext(ctx, "claim waitlist success", traceIdAttr)
+}
+
+func (in OrderHttp) get(w http.ResponseWriter, r *http.Request) {
+	externalId := r.PathValue("external_id")
+	if externalId == "" {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "OrderHttp.get")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+	slog.DebugContext(ctx, "get order receive request", slog.String("external_id", externalId), traceIdAttr)
+
+	order, err := in.Querier.FindOrderByExternalId(ctx, externalId)
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "failed to find order by external id", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if err == pgx.ErrNoRows {
+		slog.DebugContext(ctx, "order not found", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Order not found"})
+		return
+	}
+
+	category, _ := vars.GetCategory(order.CategoryID)
+	event, _ := vars.GetEvent(order.EventID)
+
+	resp := model.GetOrderResponse{
+		Id:            order.ID,
+		ExternalId:    order.ExternalID,
+		Status:        string(order.Status.OrderStatus),
+		EventId:       order.EventID,
+		EventName:     event.Name,
+		CategoryId:    order.CategoryID,
+		CategoryName:  category.Name,
+		SeatingType:   category.SeatingType,
+		Quantity:      order.Quantity,
+		PaymentMethod: order.PaymentMethod,
+		Price:         pricing.FromOrder(order.Quantity, order.BasePrice, order.PlatformFee, order.VatAmount, order.TotalAmount),
+		PaymentCode:   order.PaymentCode,
+		ExpiredAt:     order.ExpiredAt.Time.Format(time.RFC3339),
+	}
+
+	if order.TicketRow.Valid && order.TicketCol.Valid {
+		resp.TicketRow = &order.TicketRow.Int32
+		resp.TicketCol = &order.TicketCol.Int32
+	}
+
+	// General admission orders never get a row and col, their tickets carry an
+	// admission number once the order is completed.
+	generalAdmission := resp.SeatingType == constant.SeatingTypeGeneralAdmission
+	if resp.TicketRow != nil || (generalAdmission && order.Status.OrderStatus == sqlgen.OrderStatusCompleted) {
+		items, err := in.Querier.FindOrderItemsByOrderId(ctx, order.ID)
 		if err != nil {
-			slog.ErrorContext(ctx, "failed to publish cancel order message", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			slog.ErrorContext(ctx, "failed to find order items", traceIdAttr, slog.Any(constant.LogFieldErr, err))
 			writeErrorResponse(w, err)
 			return
 		}
-	}
 
-	slog.InfoContext(ctx, "cancel order success", slog.Any(constant.LogFieldResponse, len(cancelableOrders)), traceIdAttr)
+		resp.Tickets = make([]model.OrderTicketResponse, 0, len(items))
+		for _, item := range items {
+			switch {
+			case item.AdmissionNumber.Valid:
+				resp.Tickets = append(resp.Tickets, model.OrderTicketResponse{AdmissionNumber: item.AdmissionNumber.Int32})
+			case item.TicketRow.Valid && item.TicketCol.Valid:
+				resp.Tickets = append(resp.Tickets, model.OrderTicketResponse{Row: item.TicketRow.Int32, Col: item.TicketCol.Int32})
+			}
+		}
+	}
 
-	writeJSONResponse(w, http.StatusOK, nil)
+	writeJSONResponse(w, http.StatusOK, resp)
 }
 
 func (in OrderHttp) validateCreateOrderRequest(req model.CreateOrderRequest) error {
@@ -267,8 +797,12 @@ func (in OrderHttp) validateCreateOrderRequest(req model.CreateOrderRequest) err
 		return err
 	}
 
-	_, ok := constant.CategoryPriceById[req.CategoryId]
-	if !ok {
+	if _, ok := vars.GetEvent(req.EventId); !ok {
+		return &errs.HttpError{Code: http.StatusNotFound, Message: "Event not found"}
+	}
+
+	category, ok := vars.GetCategory(req.CategoryId)
+	if !ok || category.EventId != req.EventId {
 		return &errs.HttpError{
 			Code:    http.StatusBadRequest,
 			Message: "Validation failed",
@@ -278,13 +812,146 @@ func (in OrderHttp) validateCreateOrderRequest(req model.CreateOrderRequest) err
 		}
 	}
 
+	if err := checkOnSale(category, in.TimeNow()); err != nil {
+		return err
+	}
+
+	if req.Quantity > in.maxQuantity {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"Quantity": "max",
+			},
+		}
+	}
+
+	if _, ok := in.paymentMethods[req.PaymentMethod]; !ok {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"PaymentMethod": "not found",
+			},
+		}
+	}
+
+	allowed, restricted := in.categoryPaymentMethods[req.CategoryId]
+	if restricted && !slices.Contains(allowed, req.PaymentMethod) {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"PaymentMethod": "not allowed",
+			},
+		}
+	}
+
 	return nil
 }
 
-func (in OrderHttp) buildOrderCancellationEmailBody(row sqlgen.BulkCancelOrdersRow) string {
-	priceFormattedIdr := in.IdrCurrencyFormatter.Sprintf("Rp%d", constant.CategoryPriceById[row.CategoryID])
-	categoryName := constant.CategoryNameById[row.CategoryID]
-	orderID := fmt.Sprintf("CLDPLY-%d", row.ID)
+// validateSeatHold checks the hold the order consumes belongs to the ordered
+// category and covers exactly the ordered quantity.
+func validateSeatHold(req model.CreateOrderRequest, hold model.SeatHold, notFound bool) error {
+	if notFound {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"HoldId": "not found",
+			},
+		}
+	}
+
+	if hold.CategoryId != req.CategoryId {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"HoldId": "category mismatch",
+			},
+		}
+	}
+
+	if int(req.Quantity) != len(hold.Seats) {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"Quantity": "hold mismatch",
+			},
+		}
+	}
+
+	return nil
+}
+
+// holdOrderSeats moves the seats of the hold onto the order, it fails with a
+// conflict when any of them was released since the hold was placed.
+func holdOrderSeats(ctx context.Context, querier *sqlgen.Queries, orderId int32, categoryId int16, holdId string, hold model.SeatHold) error {
+	tag, err := querier.HoldSeats(ctx, sqlgen.HoldSeatsParams{
+		OrderID:    orderId,
+		CategoryID: categoryId,
+		HoldID:     pgtype.Text{String: holdId, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() != int64(len(hold.Seats)) {
+		return &errs.HttpError{Code: http.StatusConflict, Message: "Seats are no longer available"}
+	}
+
+	return nil
+}
+
+// defaultPaymentMethodFor returns payment.default_method, or the first method
+// of a category that does not accept it.
+func (in OrderHttp) defaultPaymentMethodFor(categoryId int16) string {
+	allowed, restricted := in.categoryPaymentMethods[categoryId]
+	if restricted && len(allowed) > 0 && !slices.Contains(allowed, in.defaultPaymentMethod) {
+		return allowed[0]
+	}
+
+	return in.defaultPaymentMethod
+}
+
+// loadPaymentMethods reads the enabled payment methods and their expiry
+// windows, methods without their own window fall back to order.expired_after.
+func loadPaymentMethods(cfg *viper.Viper) map[string]time.Duration {
+	defaultExpiredAfter := cfg.GetDuration("order.expired_after")
+
+	methods := make(map[string]time.Duration)
+	for method := range cfg.GetStringMap("payment.methods") {
+		if _, ok := constant.PaymentMethodNameByMethod[method]; !ok {
+			slog.Warn("unknown payment method ignored", slog.String("method", method))
+			continue
+		}
+
+		expiredAfter := cfg.GetDuration(fmt.Sprintf("payment.methods.%s.expired_after", method))
+		if expiredAfter <= 0 {
+			expiredAfter = defaultExpiredAfter
+		}
+
+		methods[method] = expiredAfter
+	}
+
+	return methods
+}
+
+// loadCategoryPaymentMethods reads which payment methods each category is
+// restricted to, categories that are not listed accept every enabled method.
+func loadCategoryPaymentMethods(cfg *viper.Viper) map[int16][]string {
+	categoryMethods := make(map[int16][]string)
+	for key, methods := range cfg.GetStringMapStringSlice("payment.category_methods") {
+		categoryId, err := strconv.ParseInt(key, 10, 16)
+		if err != nil {
+			slog.Warn("invalid category id in payment category methods ignored", slog.String("category_id", key))
+			continue
+		}
+
+		categoryMethods[int16(categoryId)] = methods
+	}
 
-	return fmt.Sprintf(constant.EmailOrderCancellationTemplate, row.Name, orderID, categoryName, priceFormattedIdr)
+	return categoryMethods
 }
@@ -1,12 +1,19 @@
 package http
 
 import (
+	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"concert-ticket/outbound/payment"
+	paymentMock "concert-ticket/outbound/payment/mocks"
 	"concert-ticket/outbound/sqlgen"
+	"encoding/json"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/pashagolub/pgxmock/v4"
 	"github.com/redis/go-redis/v9"
@@ -19,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,11 +42,15 @@ type OrderHttpTestSuite struct {
 	Cache     *redis.Client
 	CacheMock redismock.ClientMock
 
-	Validate  *validator.Validate
-	Publisher *jetsteamMock.MockPublisher
+	Validate       *validator.Validate
+	Publisher      *jetsteamMock.MockPublisher
+	PaymentGateway *paymentMock.MockPaymentGateway
 }
 
 func (s *OrderHttpTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
 	ctrl := gomock.NewController(s.T())
 
 	rdb, mock := redismock.NewClientMock()
@@ -55,10 +67,21 @@ func (s *OrderHttpTestSuite) SetupTest() {
 
 	s.Validate = validator.New()
 	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
+	s.PaymentGateway = paymentMock.NewMockPaymentGateway(ctrl)
 
 	s.Cfg = viper.New()
 	s.Cfg.Set("order.expired_after", "15m")
 	s.Cfg.Set("order.bulk_cancel_size", 10)
+	s.Cfg.Set("order.max_quantity", 4)
+	s.Cfg.Set("payment.methods", map[string]any{
+		"bca_va": map[string]any{},
+		"qris":   map[string]any{"expired_after": "5m"},
+	})
+	s.Cfg.Set("payment.category_methods", map[string]any{"2": []string{"qris"}})
+	s.Cfg.Set("pricing.categories", map[string]any{
+		"1": map[string]any{"platform_fee_type": "flat", "platform_fee": 150_000, "vat_bps": 1100},
+		"2": map[string]any{"platform_fee_type": "percentage", "platform_fee": 500, "vat_bps": 1100},
+	})
 
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 }
@@ -78,12 +101,17 @@ func TestOrderHttpTestSuite(t *testing.T) {
 func (s *OrderHttpTestSuite) TestCreate() {
 	tests := []struct {
 		name           string
+		eventId        string
 		reqBody        string
 		setupMock      func()
 		expectedStatus int
 		expectedBody   string
 		isTestBody     bool
 		timeNow        func() time.Time
+
+		// releasesEmailLock is set for requests that take the email lock
+		// and fail before the order is committed.
+		releasesEmailLock bool
 	}{
 		{
 			name:           "invalid json",
@@ -92,25 +120,128 @@ func (s *OrderHttpTestSuite) TestCreate() {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"error":"Invalid request"}`,
 		},
+		{
+			name:           "invalid event id",
+			eventId:        "abc",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "event not found",
+			eventId:        "99",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Event not found"}`,
+		},
+		{
+			name:           "event not on sale yet",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_not_started"}}`,
+			timeNow: func() time.Time {
+				return time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC)
+			},
+		},
+		{
+			name:           "event sale ended",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_ended"}}`,
+			timeNow: func() time.Time {
+				return time.Date(2100, 1, 1, 17, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name:    "category not on sale yet",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				setCategorySaleWindow(s.T(), 1, time.Date(2023, 1, 1, 0, 0, 1, 0, time.UTC), testSaleEndsAt)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_not_started"}}`,
+			timeNow: func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name:    "category sale ended",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				setCategorySaleWindow(s.T(), 1, testSaleStartsAt, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_ended"}}`,
+			timeNow: func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name:           "validation error - category of another event",
+			eventId:        "2",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"CategoryId":"not found"}}`,
+		},
 		{
 			name:           "validation error - invalid category #1",
-			reqBody:        `{"name": "John Doe", "email": "john@example.com"}`,
+			reqBody:        `{"name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock:      func() {},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"error":"Validation failed","data":{"CategoryId":"required"}}`,
 		},
 		{
 			name:           "validation error - invalid category #2",
-			reqBody:        `{"category_id": 99, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody:        `{"category_id": 99, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock:      func() {},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"error":"Validation failed","data":{"CategoryId":"not found"}}`,
 		},
+		{
+			name:           "validation error - quantity above max",
+			reqBody:        `{"category_id": 1, "quantity": 5, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Quantity":"max"}}`,
+		},
+		{
+			name:           "validation error - negative quantity",
+			reqBody:        `{"category_id": 1, "quantity": -1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Quantity":"min"}}`,
+		},
+		{
+			name:           "validation error - missing payment method",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"PaymentMethod":"required"}}`,
+		},
+		{
+			name:           "validation error - unknown payment method",
+			reqBody:        `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bri_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"PaymentMethod":"not found"}}`,
+		},
+		{
+			name:           "validation error - payment method not allowed for category",
+			reqBody:        `{"category_id": 2, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"PaymentMethod":"not allowed"}}`,
+		},
 		{
 			name:    "email lock error",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetErr(redis.ErrClosed)
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -118,9 +249,9 @@ func (s *OrderHttpTestSuite) TestCreate() {
 		},
 		{
 			name:    "email already ordered - from cache",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(false)
 			},
 			expectedStatus: http.StatusConflict,
@@ -128,89 +259,94 @@ func (s *OrderHttpTestSuite) TestCreate() {
 		},
 		{
 			name:    "check email from db error",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnError(fmt.Errorf("database error"))
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "check email already ordered",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
 			},
-			expectedStatus: http.StatusConflict,
-			expectedBody:   `{"error":"Email already ordered"}`,
+			expectedStatus:    http.StatusConflict,
+			expectedBody:      `{"error":"Email already ordered"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "decrement category error",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetErr(redis.ErrClosed)
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "increment category error",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(-1)
-				s.CacheMock.ExpectIncr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetErr(redis.ErrClosed)
 			},
-			expectedStatus: http.StatusConflict,
-			expectedBody:   `{"error":"Category sold out"}`,
+			expectedStatus:    http.StatusConflict,
+			expectedBody:      `{"error":"Category sold out"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "category sold out",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(-1)
-				s.CacheMock.ExpectIncr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(0)
 			},
-			expectedStatus: http.StatusConflict,
-			expectedBody:   `{"error":"Category sold out"}`,
+			expectedStatus:    http.StatusConflict,
+			expectedBody:      `{"error":"Category sold out"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "publish message error - increment category",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(0)
 
 				s.Publisher.EXPECT().Publish(
@@ -219,72 +355,130 @@ func (s *OrderHttpTestSuite) TestCreate() {
 					gomock.Any(),
 				).Return(nil, fmt.Errorf("publish error"))
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
+		},
+		{
+			name:    "create charge error",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
+					SetVal(0)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{}, fmt.Errorf("gateway error"))
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, nil).Times(2)
+			},
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
 		},
 		{
 			name:    "create order error",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(0)
 
 				fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 				expiredAt := fixedTime.Add(15 * time.Minute)
 
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
 				s.PgxMock.ExpectQuery("INSERT INTO orders").
 					WithArgs(
+						int16(1),           // event_id
 						int16(1),           // category_id
+						int32(1),           // quantity
 						pgxmock.AnyArg(),   // external_id
 						"John Doe",         // name
 						"john@example.com", // email
 						pgxmock.AnyArg(),   // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
 						pgtype.Timestamp{Time: expiredAt, Valid: true}, // expired_at with fixed time
+						int64(11_000_000), // base_price
+						int64(150_000),    // platform_fee
+						int64(1_226_500),  // vat_amount
+						int64(12_376_500), // total_amount
 					).
 					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectIncrementCategoryQuantity,
 					gomock.Any(),
 				).Return(nil, nil).Times(2)
+
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
 			timeNow: func() time.Time {
 				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 			},
 		},
 		{
-			name:    "publish message error - create order",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			name:    "insert outbox error",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(0)
 
 				fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 				expiredAt := fixedTime.Add(15 * time.Minute)
 
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
 				s.PgxMock.ExpectQuery("INSERT INTO orders").
 					WithArgs(
+						int16(1),           // event_id
 						int16(1),           // category_id
+						int32(1),           // quantity
 						pgxmock.AnyArg(),   // external_id
 						"John Doe",         // name
 						"john@example.com", // email
 						pgxmock.AnyArg(),   // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
 						pgtype.Timestamp{Time: expiredAt, Valid: true}, // expired_at
+						int64(11_000_000), // base_price
+						int64(150_000),    // platform_fee
+						int64(1_226_500),  // vat_amount
+						int64(12_376_500), // total_amount
 					).
 					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
@@ -292,43 +486,94 @@ func (s *OrderHttpTestSuite) TestCreate() {
 					gomock.Any(),
 				).Return(nil, nil).Times(2)
 
-				s.Publisher.EXPECT().Publish(
-					gomock.Any(),
-					constant.SubjectCreateOrder,
-					gomock.Any(),
-				).Return(nil, fmt.Errorf("publish error"))
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
 			timeNow: func() time.Time {
 				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 			},
 		},
+		{
+			name:    "commit transaction error",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
+					SetVal(0)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(int16(1), int16(1), int32(1), pgxmock.AnyArg(), "John Doe", "john@example.com", pgxmock.AnyArg(), "bca_va", pgxmock.AnyArg(), pgxmock.AnyArg(),
+						int64(11_000_000), int64(150_000), int64(1_226_500), int64(12_376_500)).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit().WillReturnError(fmt.Errorf("commit error"))
+				s.PgxMock.ExpectRollback()
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					gomock.Any(),
+				).Return(nil, nil).Times(2)
+
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
+			},
+			expectedStatus:    http.StatusInternalServerError,
+			expectedBody:      `{"error":"Internal Server Error"}`,
+			releasesEmailLock: true,
+		},
 		{
 			name:    "success",
-			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com"}`,
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
 			setupMock: func() {
-				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
 					SetVal(true)
-				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE email = \$1 AND status = 'pending'\) AS "exists"`).
-					WithArgs("john@example.com").
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
 					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
-				s.CacheMock.ExpectDecr(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1))).
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
 					SetVal(0)
 
 				fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 				expiredAt := fixedTime.Add(15 * time.Minute)
 
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
 				s.PgxMock.ExpectQuery("INSERT INTO orders").
 					WithArgs(
+						int16(1),           // event_id
 						int16(1),           // category_id
+						int32(1),           // quantity
 						pgxmock.AnyArg(),   // external_id
 						"John Doe",         // name
 						"john@example.com", // email
 						pgxmock.AnyArg(),   // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
 						pgtype.Timestamp{Time: expiredAt, Valid: true}, // expired_at
+						int64(11_000_000), // base_price
+						int64(150_000),    // platform_fee
+						int64(1_226_500),  // vat_amount
+						int64(12_376_500), // total_amount
 					).
 					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
@@ -336,27 +581,280 @@ func (s *OrderHttpTestSuite) TestCreate() {
 					gomock.Any(),
 				).Return(nil, nil)
 
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"payment_code":"8808000000000001"`,
+			timeNow: func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name:    "success with method expiry",
+			reqBody: `{"category_id": 2, "name": "John Doe", "email": "john@example.com", "payment_method": "qris"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(2)), int64(1)).
+					SetVal(0)
+
+				fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+				expiredAt := fixedTime.Add(5 * time.Minute)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Cond(func(req payment.CreateChargeRequest) bool {
+					return req.Method == "qris" && req.Amount == 8_741_250 && req.ExpiredAt.Equal(expiredAt)
+				})).Return(payment.Charge{Method: "qris", PaymentCode: "QRIS0000000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),               // event_id
+						int16(2),               // category_id
+						int32(1),               // quantity
+						pgxmock.AnyArg(),       // external_id
+						"John Doe",             // name
+						"john@example.com",     // email
+						"QRIS0000000000000001", // payment_code
+						"qris",                 // payment_method
+						pgxmock.AnyArg(),       // cancel_token_hash
+						pgtype.Timestamp{Time: expiredAt, Valid: true}, // expired_at
+						int64(7_500_000), // base_price
+						int64(375_000),   // platform_fee
+						int64(866_250),   // vat_amount
+						int64(8_741_250), // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
+
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
-					constant.SubjectCreateOrder,
+					constant.SubjectIncrementCategoryQuantity,
 					gomock.Any(),
 				).Return(nil, nil)
 			},
 			expectedStatus: http.StatusOK,
+			expectedBody:   `"price":{"base_price":7500000,"subtotal":7500000,"platform_fee":375000,"vat":866250,"total":8741250}`,
 			timeNow: func() time.Time {
 				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 			},
 		},
-	}
+		{
+			name:    "success multiple tickets",
+			reqBody: `{"category_id": 1, "quantity": 3, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(3)).
+					SetVal(7)
+
+				fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+				expiredAt := fixedTime.Add(15 * time.Minute)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Cond(func(req payment.CreateChargeRequest) bool {
+					return req.Method == "bca_va" && req.Amount == 37_129_500 && req.ExpiredAt.Equal(expiredAt) && req.Customer.Email == "john@example.com"
+				})).Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),           // event_id
+						int16(1),           // category_id
+						int32(3),           // quantity
+						pgxmock.AnyArg(),   // external_id
+						"John Doe",         // name
+						"john@example.com", // email
+						pgxmock.AnyArg(),   // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
+						pgtype.Timestamp{Time: expiredAt, Valid: true}, // expired_at
+						int64(11_000_000), // base_price
+						int64(450_000),    // platform_fee
+						int64(3_679_500),  // vat_amount
+						int64(37_129_500), // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					[]byte(`{"id":1,"quantity":-3}`),
+				).Return(nil, nil)
+
+			},
+			expectedStatus: http.StatusOK,
+			timeNow: func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			},
+		},
+
+		{
+			name:    "seat hold not found",
+			reqBody: `{"category_id": 1, "quantity": 1, "hold_id": "HOLD1", "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).RedisNil()
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"HoldId":"not found"}}`,
+		},
+		{
+			name:    "seat hold category mismatch",
+			reqBody: `{"category_id": 2, "quantity": 1, "hold_id": "HOLD1", "name": "John Doe", "email": "john@example.com", "payment_method": "qris"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).
+					SetVal(`{"category_id":1,"seats":[{"row":1,"col":2}]}`)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"HoldId":"category mismatch"}}`,
+		},
+		{
+			name:    "seat hold quantity mismatch",
+			reqBody: `{"category_id": 1, "quantity": 2, "hold_id": "HOLD1", "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).
+					SetVal(`{"category_id":1,"seats":[{"row":1,"col":2}]}`)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Quantity":"hold mismatch"}}`,
+		},
+		{
+			name:    "held seats no longer available",
+			reqBody: `{"category_id": 1, "quantity": 1, "hold_id": "HOLD1", "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).
+					SetVal(`{"category_id":1,"seats":[{"row":1,"col":2}]}`)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
+					SetVal(9)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),           // event_id
+						int16(1),           // category_id
+						int32(1),           // quantity
+						pgxmock.AnyArg(),   // external_id
+						"John Doe",         // name
+						"john@example.com", // email
+						"8808000000000001", // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
+						pgxmock.AnyArg(),   // expired_at
+						int64(11_000_000),  // base_price
+						int64(150_000),     // platform_fee
+						int64(1_226_500),   // vat_amount
+						int64(12_376_500),  // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec(`UPDATE seats SET order_id = \$1::integer, hold_id = NULL`).
+					WithArgs(int32(1), int16(1), pgtype.Text{String: "HOLD1", Valid: true}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback()
+
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					[]byte(`{"id":1,"quantity":-1}`),
+				).Return(nil, nil)
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					[]byte(`{"id":1,"quantity":1}`),
+				).Return(nil, nil)
+			},
+			expectedStatus:    http.StatusConflict,
+			expectedBody:      `{"error":"Seats are no longer available"}`,
+			releasesEmailLock: true,
+		},
+		{
+			name:    "success with seat hold",
+			reqBody: `{"category_id": 1, "quantity": 1, "hold_id": "HOLD1", "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).
+					SetVal(`{"category_id":1,"seats":[{"row":1,"col":2}]}`)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "john@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+				s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).
+					SetVal(9)
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),           // event_id
+						int16(1),           // category_id
+						int32(1),           // quantity
+						pgxmock.AnyArg(),   // external_id
+						"John Doe",         // name
+						"john@example.com", // email
+						"8808000000000001", // payment_code
+						"bca_va",           // payment_method
+						pgxmock.AnyArg(),   // cancel_token_hash
+						pgxmock.AnyArg(),   // expired_at
+						int64(11_000_000),  // base_price
+						int64(150_000),     // platform_fee
+						int64(1_226_500),   // vat_amount
+						int64(12_376_500),  // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec(`UPDATE seats SET order_id = \$1::integer, hold_id = NULL`).
+					WithArgs(int32(1), int16(1), pgtype.Text{String: "HOLD1", Valid: true}).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.SeatHoldKey, "HOLD1")).SetVal(1)
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectIncrementCategoryQuantity,
+					[]byte(`{"id":1,"quantity":-1}`),
+				).Return(nil, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"payment_code":"8808000000000001"`,
+			timeNow: func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			},
+		}}
 
 	for _, tc := range tests {
 		s.Run(tc.name, func() {
 			orderHttp := RegisterOrderHttp(
 				http.NewServeMux(),
 				s.Cfg,
+				s.PgxMock,
 				s.Querier,
 				s.Cache,
 				s.Publisher,
+				s.PaymentGateway,
 				s.Validate,
 				message.NewPrinter(language.Indonesian),
 			)
@@ -366,8 +864,17 @@ func (s *OrderHttpTestSuite) TestCreate() {
 			}
 
 			tc.setupMock()
+			if tc.releasesEmailLock {
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+			}
 
-			req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(tc.reqBody))
+			eventId := tc.eventId
+			if eventId == "" {
+				eventId = "1"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events/"+eventId+"/orders", strings.NewReader(tc.reqBody))
+			req.SetPathValue("id", eventId)
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -388,69 +895,541 @@ func (s *OrderHttpTestSuite) TestCreate() {
 	}
 }
 
-func (s *OrderHttpTestSuite) TestCancel() {
+func (s *OrderHttpTestSuite) TestDefaultPaymentMethodFor() {
 	tests := []struct {
-		name           string
-		setupMock      func(time.Time)
-		expectedStatus int
-		expectedBody   string
-		timeNow        func() time.Time
+		name          string
+		defaultMethod string
+		categoryId    int16
+		expected      string
 	}{
 		{
-			name: "database error",
-			setupMock: func(fixedTime time.Time) {
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
-					WillReturnError(fmt.Errorf("database error"))
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			},
+			name:          "category accepts every method",
+			defaultMethod: "bca_va",
+			categoryId:    1,
+			expected:      "bca_va",
 		},
 		{
-			name: "no cancelable orders",
-			setupMock: func(fixedTime time.Time) {
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
-					WillReturnRows(pgxmock.NewRows([]string{"id", "category_id", "name", "email"}))
-			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   ``,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			},
+			name:          "category does not accept the default",
+			defaultMethod: "bca_va",
+			categoryId:    2,
+			expected:      "qris",
 		},
 		{
-			name: "redis incrby error",
-			setupMock: func(fixedTime time.Time) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "name", "email"}).
-					AddRow(int32(1), int16(1), "John Doe", "john@example.com")
-
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+			name:          "category accepts the default",
+			defaultMethod: "qris",
+			categoryId:    2,
+			expected:      "qris",
+		},
+		{
+			name:       "no default method",
+			categoryId: 1,
+			expected:   "",
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			orderHttp := OrderHttp{
+				categoryPaymentMethods: loadCategoryPaymentMethods(s.Cfg),
+				defaultPaymentMethod:   tc.defaultMethod,
+			}
+
+			s.Equal(tc.expected, orderHttp.defaultPaymentMethodFor(tc.categoryId))
+		})
+	}
+}
+
+func (s *OrderHttpTestSuite) TestGet() {
+	columns := []string{"id", "event_id", "category_id", "quantity", "external_id", "status", "payment_code", "payment_method", "base_price", "platform_fee", "vat_amount", "total_amount", "expired_at", "ticket_row", "ticket_col", "created_at", "updated_at"}
+	fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		externalId     string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing external id",
+			externalId:     "",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:       "database error",
+			externalId: "order-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:       "order not found",
+			externalId: "order-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
+					WillReturnError(pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Order not found"}`,
+		},
+		{
+			name:       "success pending",
+			externalId: "order-123",
+			setupMock: func() {
+				rows := pgxmock.NewRows(columns).AddRow(
+					int32(1), int16(1), int16(1), int32(1), "order-123",
+					sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true},
+					"PAY123", "bca_va", int64(11_000_000), int64(150_000), int64(1_226_500), int64(12_376_500), pgtype.Timestamp{Time: fixedTime, Valid: true},
+					pgtype.Int4{}, pgtype.Int4{},
+					pgtype.Timestamp{Time: fixedTime, Valid: true}, pgtype.Timestamp{},
+				)
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
 					WillReturnRows(rows)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"external_id":"order-123","status":"pending","event_id":1,"event_name":"Coldplay Music of the Spheres World Tour","category_id":1,"category_name":"Ultimate Experience","seating_type":"reserved","quantity":1,"payment_method":"bca_va","payment_code":"PAY123","price":{"base_price":11000000,"subtotal":11000000,"platform_fee":150000,"vat":1226500,"total":12376500},"expired_at":"2023-01-01T00:00:00Z"}`,
+		},
+		{
+			name:       "find order items error",
+			externalId: "order-123",
+			setupMock: func() {
+				rows := pgxmock.NewRows(columns).AddRow(
+					int32(1), int16(1), int16(1), int32(2), "order-123",
+					sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true},
+					"PAY123", "bca_va", int64(11_000_000), int64(300_000), int64(2_453_000), int64(24_753_000), pgtype.Timestamp{Time: fixedTime, Valid: true},
+					pgtype.Int4{Int32: 3, Valid: true}, pgtype.Int4{Int32: 7, Valid: true},
+					pgtype.Timestamp{Time: fixedTime, Valid: true}, pgtype.Timestamp{Time: fixedTime, Valid: true},
+				)
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+				s.PgxMock.ExpectQuery(`SELECT ticket_row, ticket_col, admission_number FROM order_items WHERE order_id = \$1`).
+					WithArgs(int32(1)).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:       "success completed with seat",
+			externalId: "order-123",
+			setupMock: func() {
+				rows := pgxmock.NewRows(columns).AddRow(
+					int32(1), int16(1), int16(1), int32(2), "order-123",
+					sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true},
+					"PAY123", "bca_va", int64(11_000_000), int64(300_000), int64(2_453_000), int64(24_753_000), pgtype.Timestamp{Time: fixedTime, Valid: true},
+					pgtype.Int4{Int32: 3, Valid: true}, pgtype.Int4{Int32: 7, Valid: true},
+					pgtype.Timestamp{Time: fixedTime, Valid: true}, pgtype.Timestamp{Time: fixedTime, Valid: true},
+				)
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+				s.PgxMock.ExpectQuery(`SELECT ticket_row, ticket_col, admission_number FROM order_items WHERE order_id = \$1`).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"ticket_row", "ticket_col", "admission_number"}).
+						AddRow(pgtype.Int4{Int32: 3, Valid: true}, pgtype.Int4{Int32: 7, Valid: true}, pgtype.Int4{}).
+						AddRow(pgtype.Int4{Int32: 3, Valid: true}, pgtype.Int4{Int32: 6, Valid: true}, pgtype.Int4{}))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"external_id":"order-123","status":"completed","event_id":1,"event_name":"Coldplay Music of the Spheres World Tour","category_id":1,"category_name":"Ultimate Experience","seating_type":"reserved","quantity":2,"payment_method":"bca_va","payment_code":"PAY123","price":{"base_price":11000000,"subtotal":22000000,"platform_fee":300000,"vat":2453000,"total":24753000},"expired_at":"2023-01-01T00:00:00Z","ticket_row":3,"ticket_col":7,"tickets":[{"row":3,"col":7},{"row":3,"col":6}]}`,
+		},
+		{
+			name:       "success completed general admission",
+			externalId: "order-123",
+			setupMock: func() {
+				rows := pgxmock.NewRows(columns).AddRow(
+					int32(1), int16(1), int16(9), int32(2), "order-123",
+					sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true},
+					"PAY123", "bca_va", int64(2_500_000), int64(300_000), int64(583_000), int64(5_883_000), pgtype.Timestamp{Time: fixedTime, Valid: true},
+					pgtype.Int4{}, pgtype.Int4{},
+					pgtype.Timestamp{Time: fixedTime, Valid: true}, pgtype.Timestamp{Time: fixedTime, Valid: true},
+				)
+				s.PgxMock.ExpectQuery(`SELECT (.+) FROM orders WHERE external_id = \$1`).
+					WithArgs("order-123").
+					WillReturnRows(rows)
+				s.PgxMock.ExpectQuery(`SELECT ticket_row, ticket_col, admission_number FROM order_items WHERE order_id = \$1`).
+					WithArgs(int32(1)).
+					WillReturnRows(pgxmock.NewRows([]string{"ticket_row", "ticket_col", "admission_number"}).
+						AddRow(pgtype.Int4{}, pgtype.Int4{}, pgtype.Int4{Int32: 41, Valid: true}).
+						AddRow(pgtype.Int4{}, pgtype.Int4{}, pgtype.Int4{Int32: 42, Valid: true}))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"external_id":"order-123","status":"completed","event_id":1,"event_name":"Coldplay Music of the Spheres World Tour","category_id":9,"category_name":"Festival","seating_type":"general_admission","quantity":2,"payment_method":"bca_va","payment_code":"PAY123","price":{"base_price":2500000,"subtotal":5000000,"platform_fee":300000,"vat":583000,"total":5883000},"expired_at":"2023-01-01T00:00:00Z","tickets":[{"admission_number":41},{"admission_number":42}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			orderHttp := RegisterOrderHttp(
+				http.NewServeMux(),
+				s.Cfg,
+				s.PgxMock,
+				s.Querier,
+				s.Cache,
+				s.Publisher,
+				s.PaymentGateway,
+				s.Validate,
+				message.NewPrinter(language.Indonesian),
+			)
+
+			tc.setupMock()
 
-				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1)), int64(1)).SetErr(redis.ErrClosed)
+			req := httptest.NewRequest(http.MethodGet, "/api/orders/"+tc.externalId, nil)
+			req.SetPathValue("external_id", tc.externalId)
+			w := httptest.NewRecorder()
+
+			orderHttp.get(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderHttpTestSuite) TestCreateIdempotent() {
+	reqBody := `{"category_id": 99, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`
+	reqHash, err := hashRequest(model.CreateOrderRequest{EventId: 1, Name: "John Doe", Email: "john@example.com", CategoryId: 99, Quantity: 1, PaymentMethod: "bca_va"})
+	s.Require().NoError(err)
+
+	cacheKey := fmt.Sprintf(constant.OrderIdempotencyKey, int16(1), "john@example.com", "key-123")
+	inProgress := fmt.Sprintf(`{"request_hash":"%s"}`, reqHash)
+	validationBody := `{"error":"Validation failed","data":{"CategoryId":"not found"}}`
+
+	tests := []struct {
+		name             string
+		reqBody          string
+		setupMock        func()
+		expectedStatus   int
+		expectedBody     string
+		expectedReplayed bool
+	}{
+		{
+			name:    "set idempotency key error",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetErr(redis.ErrClosed)
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"Internal Server Error"}`,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+		{
+			name:    "first request stores response",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetVal(true)
+				completed, _ := json.Marshal(model.IdempotencyRecord{RequestHash: reqHash, Status: http.StatusBadRequest, Body: validationBody + "\n"})
+				s.CacheMock.ExpectSet(cacheKey, string(completed), constant.OrderIdempotencyDefaultTTL).
+					SetVal("OK")
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   validationBody,
+		},
+		{
+			name:    "first request server error releases key",
+			reqBody: `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				hash, _ := hashRequest(model.CreateOrderRequest{EventId: 1, Name: "John Doe", Email: "john@example.com", CategoryId: 1, Quantity: 1, PaymentMethod: "bca_va"})
+				s.CacheMock.ExpectSetNX(cacheKey, fmt.Sprintf(`{"request_hash":"%s"}`, hash), constant.OrderIdempotencyInProgressTTL).
+					SetVal(true)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetErr(redis.ErrClosed)
+				s.CacheMock.ExpectDel(cacheKey).SetVal(1)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "retry replays stored response",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetVal(false)
+				completed, _ := json.Marshal(model.IdempotencyRecord{RequestHash: reqHash, Status: http.StatusOK, Body: `{"id":1,"external_id":"ext-1","payment_code":"PAY123"}`})
+				s.CacheMock.ExpectGet(cacheKey).SetVal(string(completed))
+			},
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"id":1,"external_id":"ext-1","payment_code":"PAY123"}`,
+			expectedReplayed: true,
+		},
+		{
+			name:    "same key from another buyer is not replayed",
+			reqBody: `{"category_id": 99, "name": "Jane Doe", "email": "jane@example.com", "payment_method": "bca_va"}`,
+			setupMock: func() {
+				otherKey := fmt.Sprintf(constant.OrderIdempotencyKey, int16(1), "jane@example.com", "key-123")
+				hash, _ := hashRequest(model.CreateOrderRequest{EventId: 1, Name: "Jane Doe", Email: "jane@example.com", CategoryId: 99, Quantity: 1, PaymentMethod: "bca_va"})
+				s.CacheMock.ExpectSetNX(otherKey, fmt.Sprintf(`{"request_hash":"%s"}`, hash), constant.OrderIdempotencyInProgressTTL).
+					SetVal(true)
+				completed, _ := json.Marshal(model.IdempotencyRecord{RequestHash: hash, Status: http.StatusBadRequest, Body: validationBody + "\n"})
+				s.CacheMock.ExpectSet(otherKey, string(completed), constant.OrderIdempotencyDefaultTTL).
+					SetVal("OK")
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   validationBody,
+		},
+		{
+			name:    "retry with different payload",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetVal(false)
+				s.CacheMock.ExpectGet(cacheKey).SetVal(`{"request_hash":"other-hash","status":200,"body":"{}"}`)
 			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"Idempotency key reused with different payload"}`,
 		},
 		{
-			name: "publish increment category error",
-			setupMock: func(fixedTime time.Time) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "name", "email"}).
-					AddRow(int32(1), int16(1), "John Doe", "john@example.com")
+			name:    "retry while in progress",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetVal(false)
+				s.CacheMock.ExpectGet(cacheKey).SetVal(inProgress)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Request with the same idempotency key is in progress"}`,
+		},
+		{
+			name:    "get idempotency record error",
+			reqBody: reqBody,
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).
+					SetVal(false)
+				s.CacheMock.ExpectGet(cacheKey).SetErr(redis.ErrClosed)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+	}
 
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
-					WillReturnRows(rows)
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			orderHttp := RegisterOrderHttp(
+				http.NewServeMux(),
+				s.Cfg,
+				s.PgxMock,
+				s.Querier,
+				s.Cache,
+				s.Publisher,
+				s.PaymentGateway,
+				s.Validate,
+				message.NewPrinter(language.Indonesian),
+			)
+
+			tc.setupMock()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events/1/orders", strings.NewReader(tc.reqBody))
+			req.SetPathValue("id", "1")
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(constant.HeaderIdempotencyKey, "key-123")
+			w := httptest.NewRecorder()
+
+			orderHttp.create(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+			s.Equal(tc.expectedReplayed, w.Header().Get(constant.HeaderIdempotentReplayed) == "true")
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *OrderHttpTestSuite) TestCreateRetryAfterServerError() {
+	reqBody := `{"category_id": 1, "name": "John Doe", "email": "john@example.com", "payment_method": "bca_va"}`
+	reqHash, err := hashRequest(model.CreateOrderRequest{EventId: 1, Name: "John Doe", Email: "john@example.com", CategoryId: 1, Quantity: 1, PaymentMethod: "bca_va"})
+	s.Require().NoError(err)
+
+	cacheKey := fmt.Sprintf(constant.OrderIdempotencyKey, int16(1), "john@example.com", "key-123")
+	inProgress := fmt.Sprintf(`{"request_hash":"%s"}`, reqHash)
+	emailLockKey := fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")
+	findPendingQuery := `SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`
+
+	orderHttp := RegisterOrderHttp(
+		http.NewServeMux(),
+		s.Cfg,
+		s.PgxMock,
+		s.Querier,
+		s.Cache,
+		s.Publisher,
+		s.PaymentGateway,
+		s.Validate,
+		message.NewPrinter(language.Indonesian),
+	)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events/1/orders", strings.NewReader(reqBody))
+		req.SetPathValue("id", "1")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(constant.HeaderIdempotencyKey, "key-123")
+		w := httptest.NewRecorder()
 
-				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1)), int64(1)).SetVal(1)
+		orderHttp.create(w, req)
+		return w
+	}
+
+	// The first attempt fails after the email lock is taken, both the lock and
+	// the idempotency key are released.
+	s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).SetVal(true)
+	s.CacheMock.ExpectSetNX(emailLockKey, true, constant.OrderEmailLockDefaultTTL).SetVal(true)
+	s.PgxMock.ExpectQuery(findPendingQuery).
+		WithArgs(int16(1), "john@example.com").
+		WillReturnError(fmt.Errorf("database error"))
+	s.CacheMock.ExpectDel(emailLockKey).SetVal(1)
+	s.CacheMock.ExpectDel(cacheKey).SetVal(1)
+
+	w := send()
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.NoError(s.CacheMock.ExpectationsWereMet())
+	s.NoError(s.PgxMock.ExpectationsWereMet())
+
+	// The retry takes the email lock again and places the order.
+	s.CacheMock.ExpectSetNX(cacheKey, inProgress, constant.OrderIdempotencyInProgressTTL).SetVal(true)
+	s.CacheMock.ExpectSetNX(emailLockKey, true, constant.OrderEmailLockDefaultTTL).SetVal(true)
+	s.PgxMock.ExpectQuery(findPendingQuery).
+		WithArgs(int16(1), "john@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	s.CacheMock.ExpectDecrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1)), int64(1)).SetVal(0)
+	s.Publisher.EXPECT().Publish(gomock.Any(), constant.SubjectIncrementCategoryQuantity, gomock.Any()).Return(nil, nil)
+	s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+		Return(payment.Charge{PaymentCode: "8808000000000001"}, nil)
+	s.PgxMock.ExpectBegin()
+	s.PgxMock.ExpectQuery("INSERT INTO orders").
+		WithArgs(
+			int16(1),           // event_id
+			int16(1),           // category_id
+			int32(1),           // quantity
+			pgxmock.AnyArg(),   // external_id
+			"John Doe",         // name
+			"john@example.com", // email
+			"8808000000000001", // payment_code
+			"bca_va",           // payment_method
+			pgxmock.AnyArg(),   // cancel_token_hash
+			pgxmock.AnyArg(),   // expired_at
+			int64(11_000_000),  // base_price
+			int64(150_000),     // platform_fee
+			int64(1_226_500),   // vat_amount
+			int64(12_376_500),  // total_amount
+		).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+	s.PgxMock.ExpectExec("INSERT INTO outbox").
+		WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	s.PgxMock.ExpectCommit()
+	s.CacheMock.Regexp().ExpectSet(fmt.Sprintf("^%s$", cacheKey), fmt.Sprintf(`^\{"request_hash":"%s","status":200,`, reqHash), constant.OrderIdempotencyDefaultTTL).
+		SetVal("OK")
+
+	w = send()
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"payment_code":"8808000000000001"`)
+	s.NoError(s.CacheMock.ExpectationsWereMet())
+	s.NoError(s.PgxMock.ExpectationsWereMet())
+}
+
+func (s *OrderHttpTestSuite) TestWithoutCancelToken() {
+	body, err := withoutCancelToken(`{"id":1,"external_id":"ext-1","payment_method":"bca_va","payment_code":"PAY123","expired_at":"2023-01-01T00:15:00Z","cancel_token":"secret","price":{"base_price":0,"subtotal":0,"platform_fee":0,"vat":0,"total":0}}` + "\n")
+
+	s.NoError(err)
+	s.Equal(`{"id":1,"external_id":"ext-1","payment_method":"bca_va","payment_code":"PAY123","expired_at":"2023-01-01T00:15:00Z","price":{"base_price":0,"subtotal":0,"platform_fee":0,"vat":0,"total":0}}`, body)
+}
+
+func (s *OrderHttpTestSuite) TestCancelByExternalId() {
+	cancelQuery := `UPDATE orders SET status = 'cancelled', updated_at = \$3 WHERE external_id = \$1 AND cancel_token_hash = \$2 AND status = 'pending' RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount`
+	statusQuery := `SELECT status FROM orders WHERE external_id = \$1 AND cancel_token_hash = \$2`
+	fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokenHash := common.HashToken("token-123")
+
+	tests := []struct {
+		name           string
+		authorization  string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing token",
+			authorization:  "",
+			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:          "cancel order error",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:          "order not found or wrong token",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(statusQuery).
+					WithArgs("order-123", tokenHash).
+					WillReturnError(pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Order not found"}`,
+		},
+		{
+			name:          "order is not pending",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnError(pgx.ErrNoRows)
+				s.PgxMock.ExpectQuery(statusQuery).
+					WithArgs("order-123", tokenHash).
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Order is not pending"}`,
+		},
+		{
+			name:          "restock error",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "8808000000000001", int64(24_753_000)))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetErr(redis.ErrClosed)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:          "publish increment category error",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "8808000000000001", int64(24_753_000)))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(0))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
@@ -460,51 +1439,75 @@ func (s *OrderHttpTestSuite) TestCancel() {
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"Internal Server Error"}`,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			},
 		},
 		{
-			name: "publish email error",
-			setupMock: func(fixedTime time.Time) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "name", "email"}).
-					AddRow(int32(1), int16(1), "John Doe", "john@example.com")
+			name:          "publish waitlist restock error",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "8808000000000001", int64(24_753_000)))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(2))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
 
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
-					WillReturnRows(rows)
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
 
-				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1)), int64(1)).SetVal(1)
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					gomock.Any(),
+				).Return(nil, fmt.Errorf("publish error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:          "success hands tickets to the waitlist first",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "8808000000000001", int64(24_753_000)))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(1))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectBulkIncrementCategoryQuantity,
+					[]byte(`[{"id":1,"quantity":1}]`),
+				).Return(nil, nil)
+
+				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
+					constant.SubjectWaitlistRestock,
+					[]byte(`{"event_id":1,"category_id":1,"quantity":1}`),
 				).Return(nil, nil)
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectSendEmail,
 					gomock.Any(),
-				).Return(nil, fmt.Errorf("publish error"))
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error"}`,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+				).Return(nil, nil)
 			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   ``,
 		},
 		{
-			name: "success",
-			setupMock: func(fixedTime time.Time) {
-				rows := pgxmock.NewRows([]string{"id", "category_id", "name", "email"}).
-					AddRow(int32(1), int16(1), "John Doe", "john@example.com")
-
-				s.PgxMock.ExpectQuery(`UPDATE orders SET status = 'cancelled', updated_at = \$2 WHERE id IN \(SELECT id FROM orders WHERE status = 'pending' AND expired_at < \$2 LIMIT \$1\) RETURNING id, category_id, name, email`).
-					WithArgs(int32(10), pgtype.Timestamp{Time: fixedTime, Valid: true}).
-					WillReturnRows(rows)
+			name:          "success when cancel charge fails",
+			authorization: "Bearer token-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery(cancelQuery).
+					WithArgs("order-123", tokenHash, pgtype.Timestamp{Time: fixedTime, Valid: true}).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+						AddRow(int32(1), int16(1), int16(1), int32(2), "John Doe", "john@example.com", "8808000000000001", int64(24_753_000)))
+				s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(2)).SetVal(int64(0))
+				s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
 
-				s.CacheMock.ExpectIncrBy(fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1)), int64(1)).SetVal(1)
+				s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(fmt.Errorf("gateway error"))
 
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
@@ -520,9 +1523,6 @@ func (s *OrderHttpTestSuite) TestCancel() {
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   ``,
-			timeNow: func() time.Time {
-				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			},
 		},
 	}
 
@@ -531,24 +1531,322 @@ func (s *OrderHttpTestSuite) TestCancel() {
 			orderHttp := RegisterOrderHttp(
 				http.NewServeMux(),
 				s.Cfg,
+				s.PgxMock,
 				s.Querier,
 				s.Cache,
 				s.Publisher,
+				s.PaymentGateway,
 				s.Validate,
 				message.NewPrinter(language.Indonesian),
 			)
+			orderHttp.TimeNow = func() time.Time { return fixedTime }
 
-			if tc.timeNow != nil {
-				orderHttp.TimeNow = tc.timeNow
+			tc.setupMock()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/orders/order-123/cancel", nil)
+			req.SetPathValue("external_id", "order-123")
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			orderHttp.cancelByExternalId(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+// TestCancelRacingCreate runs a buyer cancel and a regular create for the same
+// category at once. The cancelled ticket goes to the head of the waitlist in
+// the restock script, the counter is never raised, so the create can only see
+// the category as sold out whichever request runs first.
+func (s *OrderHttpTestSuite) TestCancelRacingCreate() {
+	cancelQuery := `UPDATE orders SET status = 'cancelled', updated_at = \$3 WHERE external_id = \$1 AND cancel_token_hash = \$2 AND status = 'pending' RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount`
+	fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	quantityKey := fmt.Sprintf(constant.EachCategoryQuantityKey, int16(1), int16(1))
+
+	s.CacheMock.MatchExpectationsInOrder(false)
+	s.PgxMock.MatchExpectationsInOrder(false)
+
+	s.PgxMock.ExpectQuery(cancelQuery).
+		WithArgs("order-123", common.HashToken("token-123"), pgtype.Timestamp{Time: fixedTime, Valid: true}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "category_id", "quantity", "name", "email", "payment_code", "total_amount"}).
+			AddRow(int32(1), int16(1), int16(1), int32(1), "John Doe", "john@example.com", "8808000000000001", int64(12_376_500)))
+	s.CacheMock.ExpectEvalSha(common.RestockScript.Hash(), common.RestockKeys(1, 1), int32(1)).SetVal(int64(1))
+	s.CacheMock.ExpectDel(fmt.Sprintf(constant.OrderEmailLock, int16(1), "john@example.com")).SetVal(1)
+	s.PaymentGateway.EXPECT().CancelCharge(gomock.Any(), "8808000000000001").Return(nil)
+	s.Publisher.EXPECT().Publish(
+		gomock.Any(),
+		constant.SubjectWaitlistRestock,
+		[]byte(`{"event_id":1,"category_id":1,"quantity":1}`),
+	).Return(nil, nil)
+	s.Publisher.EXPECT().Publish(
+		gomock.Any(),
+		constant.SubjectSendEmail,
+		gomock.Any(),
+	).Return(nil, nil)
+
+	s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "jane@example.com"), true, constant.OrderEmailLockDefaultTTL).
+		SetVal(true)
+	s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+		WithArgs(int16(1), "jane@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	s.CacheMock.ExpectDecrBy(quantityKey, int64(1)).SetVal(-1)
+	s.CacheMock.ExpectIncrBy(quantityKey, int64(1)).SetVal(0)
+
+	orderHttp := RegisterOrderHttp(
+		http.NewServeMux(),
+		s.Cfg,
+		s.PgxMock,
+		s.Querier,
+		s.Cache,
+		s.Publisher,
+		s.PaymentGateway,
+		s.Validate,
+		message.NewPrinter(language.Indonesian),
+	)
+	orderHttp.TimeNow = func() time.Time { return fixedTime }
+
+	cancelReq := httptest.NewRequest(http.MethodPost, "/api/orders/order-123/cancel", nil)
+	cancelReq.SetPathValue("external_id", "order-123")
+	cancelReq.Header.Set("Authorization", "Bearer token-123")
+	cancelRes := httptest.NewRecorder()
+
+	createReq := httptest.NewRequest(http.MethodPost, "/api/events/1/orders", strings.NewReader(`{"category_id": 1, "name": "Jane Doe", "email": "jane@example.com", "payment_method": "bca_va"}`))
+	createReq.SetPathValue("id", "1")
+	createReq.Header.Set("Content-Type", "application/json")
+	createRes := httptest.NewRecorder()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		orderHttp.cancelByExternalId(cancelRes, cancelReq)
+	}()
+	go func() {
+		defer wg.Done()
+		orderHttp.create(createRes, createReq)
+	}()
+	wg.Wait()
+
+	s.Equal(http.StatusOK, cancelRes.Code)
+	s.Equal(http.StatusConflict, createRes.Code)
+	s.Equal(`{"error":"Category sold out"}`, strings.TrimSpace(createRes.Body.String()))
+
+	s.NoError(s.CacheMock.ExpectationsWereMet())
+	s.NoError(s.PgxMock.ExpectationsWereMet())
+}
+
+func (s *OrderHttpTestSuite) TestClaimWaitlist() {
+	tokenHash := common.HashToken("claim-token")
+	claimKey := fmt.Sprintf(constant.WaitlistClaimKey, tokenHash)
+	claim := `{"event_id":1,"category_id":9,"name":"Jane Doe","email":"jane@example.com","expired_at":"2023-01-01T00:10:00Z"}`
+	claimExpiry := float64(time.Date(2023, 1, 1, 0, 10, 0, 0, time.UTC).Unix())
+
+	tests := []struct {
+		name           string
+		reqBody        string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+
+		defaultPaymentMethod string
+	}{
+		{
+			name:           "invalid json",
+			reqBody:        `{invalid json`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:    "validation error - missing payment method without default",
+			reqBody: `{}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetVal(claim)
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, tokenHash).SetVal(1)
+				s.CacheMock.ExpectZAdd(constant.WaitlistClaimsKey, redis.Z{Score: claimExpiry, Member: tokenHash}).SetVal(1)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"PaymentMethod":"required"}}`,
+		},
+		{
+			name:    "get claim error",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetErr(fmt.Errorf("redis error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "claim not found",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).RedisNil()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Claim not found"}`,
+		},
+		{
+			name:    "claim expired",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).
+					SetVal(`{"event_id":1,"category_id":9,"name":"Jane Doe","email":"jane@example.com","expired_at":"2022-12-31T23:59:59Z"}`)
+			},
+			expectedStatus: http.StatusGone,
+			expectedBody:   `{"error":"Claim expired"}`,
+		},
+		{
+			name:    "claim already used",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetVal(claim)
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, tokenHash).SetVal(0)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Claim already used"}`,
+		},
+		{
+			name:    "order failed restores claim",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetVal(claim)
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, tokenHash).SetVal(1)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "jane@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(false)
+				s.CacheMock.ExpectZAdd(constant.WaitlistClaimsKey, redis.Z{Score: claimExpiry, Member: tokenHash}).SetVal(1)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Email already ordered"}`,
+		},
+		{
+			name:    "success without taking stock again",
+			reqBody: `{"payment_method": "bca_va"}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetVal(claim)
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, tokenHash).SetVal(1)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "jane@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "jane@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "39358000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),            // event_id
+						int16(9),            // category_id
+						int32(1),            // quantity
+						pgxmock.AnyArg(),    // external_id
+						"Jane Doe",          // name
+						"jane@example.com",  // email
+						"39358000000000001", // payment_code
+						"bca_va",            // payment_method
+						pgxmock.AnyArg(),    // cancel_token_hash
+						pgxmock.AnyArg(),    // expired_at
+						pgxmock.AnyArg(),    // base_price
+						pgxmock.AnyArg(),    // platform_fee
+						pgxmock.AnyArg(),    // vat_amount
+						pgxmock.AnyArg(),    // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.CacheMock.ExpectDel(claimKey).SetVal(1)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"payment_code":"39358000000000001"`,
+		},
+		{
+			name:    "success with default payment method",
+			reqBody: `{}`,
+			setupMock: func() {
+				s.CacheMock.ExpectGet(claimKey).SetVal(claim)
+				s.CacheMock.ExpectZRem(constant.WaitlistClaimsKey, tokenHash).SetVal(1)
+				s.CacheMock.ExpectSetNX(fmt.Sprintf(constant.OrderEmailLock, int16(1), "jane@example.com"), true, constant.OrderEmailLockDefaultTTL).
+					SetVal(true)
+				s.PgxMock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM orders WHERE event_id = \$1 AND email = \$2 AND status = 'pending'\) AS "exists"`).
+					WithArgs(int16(1), "jane@example.com").
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+
+				s.PaymentGateway.EXPECT().CreateCharge(gomock.Any(), gomock.Any()).
+					Return(payment.Charge{PaymentCode: "39358000000000001"}, nil)
+
+				s.PgxMock.ExpectBegin()
+				s.PgxMock.ExpectQuery("INSERT INTO orders").
+					WithArgs(
+						int16(1),            // event_id
+						int16(9),            // category_id
+						int32(1),            // quantity
+						pgxmock.AnyArg(),    // external_id
+						"Jane Doe",          // name
+						"jane@example.com",  // email
+						"39358000000000001", // payment_code
+						"bca_va",            // payment_method
+						pgxmock.AnyArg(),    // cancel_token_hash
+						pgxmock.AnyArg(),    // expired_at
+						pgxmock.AnyArg(),    // base_price
+						pgxmock.AnyArg(),    // platform_fee
+						pgxmock.AnyArg(),    // vat_amount
+						pgxmock.AnyArg(),    // total_amount
+					).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int32(1)))
+				s.PgxMock.ExpectExec("INSERT INTO outbox").
+					WithArgs(constant.SubjectCreateOrder, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				s.PgxMock.ExpectCommit()
+
+				s.CacheMock.ExpectDel(claimKey).SetVal(1)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"payment_code":"39358000000000001"`,
+
+			defaultPaymentMethod: "bca_va",
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			s.Cfg.Set("payment.default_method", tc.defaultPaymentMethod)
+
+			orderHttp := RegisterOrderHttp(
+				http.NewServeMux(),
+				s.Cfg,
+				s.PgxMock,
+				s.Querier,
+				s.Cache,
+				s.Publisher,
+				s.PaymentGateway,
+				s.Validate,
+				message.NewPrinter(language.Indonesian),
+			)
+			orderHttp.TimeNow = func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 			}
 
-			fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			tc.setupMock(fixedTime)
+			tc.setupMock()
 
-			req := httptest.NewRequest(http.MethodPost, "/api/orders/cancel", nil)
+			req := httptest.NewRequest(http.MethodPost, "/api/waitlist/claims/claim-token", strings.NewReader(tc.reqBody))
+			req.SetPathValue("token", "claim-token")
+			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			orderHttp.cancel(w, req)
+			orderHttp.claimWaitlist(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
 
 			if tc.expectedStatus == http.StatusOK {
 				s.Contains(w.Body.String(), tc.expectedBody, "Response should contain expected text")
@@ -1,40 +1,115 @@
 package http
 
 import (
+	"bytes"
 	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	"concert-ticket/common/errs"
+	"concert-ticket/common/otel"
 	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"crypto/hmac"
 	"encoding/json"
+	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+const maxCallbackBodySize = 1 << 16
+
 type PaymentHttp struct {
+	Querier   *sqlgen.Queries
+	Cache     *redis.Client
 	Publisher jetstream.Publisher
 	Validate  *validator.Validate
+
+	TimeNow func() time.Time
+
+	callbackSecret    []byte
+	callbackTolerance time.Duration
 }
 
 func RegisterPaymentHttp(
 	mux *http.ServeMux,
+	cfg *viper.Viper,
+	querier *sqlgen.Queries,
+	cache *redis.Client,
 	publisher jetstream.Publisher,
 	validate *validator.Validate,
 ) *PaymentHttp {
 	in := &PaymentHttp{
+		Querier:   querier,
+		Cache:     cache,
 		Publisher: publisher,
 		Validate:  validate,
+		TimeNow:   time.Now,
+
+		callbackSecret:    []byte(cfg.GetString("payment.callback.secret")),
+		callbackTolerance: cfg.GetDuration("payment.callback.tolerance"),
 	}
 
 	mux.HandleFunc("POST /api/payments/callback", in.callback)
 
+	adminAuth := AdminAuthMiddleware(cfg.GetString("admin.token"))
+	mux.Handle("GET /admin/payments/{external_id}/callbacks", adminAuth(http.HandlerFunc(in.listCallbacks)))
+
 	return in
 }
 
 func (in PaymentHttp) callback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBodySize))
+	if err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	ctx := r.Context()
+	reason, err := in.verifyCallback(r, body)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to verify payment callback", slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if reason != "" {
+		slog.WarnContext(ctx, "payment callback rejected",
+			slog.Bool(constant.LogFieldSecurity, true),
+			slog.String("reason", reason),
+			slog.String("callback_id", r.Header.Get(constant.HeaderCallbackId)),
+			slog.String("remote_addr", r.RemoteAddr),
+			common.ExtractTraceIDFromCtx(ctx),
+		)
+		writeErrorResponse(w, &errs.HttpError{
+			Code:    http.StatusUnauthorized,
+			Message: "Unauthorized",
+			Data:    map[string]any{"reason": reason},
+		})
+		return
+	}
+
+	// The provider retries a callback that failed on our side with the same
+	// callback id, so the nonce is released again on a server error.
+	callbackId := r.Header.Get(constant.HeaderCallbackId)
+	rec := &responseRecorder{ResponseWriter: w}
+	w = rec
+	defer func() {
+		if rec.status >= http.StatusInternalServerError {
+			in.releaseCallbackNonce(ctx, callbackId)
+		}
+	}()
+
 	var req model.PaymentCallbackRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
 		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
 		return
 	}
@@ -44,13 +119,279 @@ func (in PaymentHttp) callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-	err := common.PublishMessage(ctx, in.Publisher, constant.SubjectCallbackPayment, model.PaymentCallbackRequest{ExternalId: req.ExternalId})
+	status, err := in.findOrderStatus(ctx, req.ExternalId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find order status", slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if status == "" {
+		slog.WarnContext(ctx, "payment callback for unknown order",
+			slog.String("external_id", req.ExternalId),
+			slog.String("provider_transaction_id", req.ProviderTransactionId),
+			common.ExtractTraceIDFromCtx(ctx),
+		)
+
+		if _, err := in.recordCallback(ctx, r, req, body, constant.PaymentCallbackOutcomeNotFound); err != nil {
+			slog.ErrorContext(ctx, "failed to record payment callback", slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Order not found"})
+		return
+	}
+
+	fresh, err := in.recordCallback(ctx, r, req, body, constant.PaymentCallbackOutcomeReceived)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record payment callback", slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if !fresh {
+		slog.InfoContext(ctx, "duplicate payment callback ignored",
+			slog.String("provider_transaction_id", req.ProviderTransactionId),
+			common.ExtractTraceIDFromCtx(ctx),
+		)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !callbackAccepted(status, req.Status) {
+		slog.WarnContext(ctx, "payment callback for order that is no longer payable",
+			slog.String("external_id", req.ExternalId),
+			slog.String("order_status", string(status)),
+			slog.String("payment_status", req.Status),
+			common.ExtractTraceIDFromCtx(ctx),
+		)
+		in.updateCallbackOutcome(ctx, callbackId, constant.PaymentCallbackOutcomeRejected)
+		writeErrorResponse(w, &errs.HttpError{
+			Code:    http.StatusConflict,
+			Message: "Order is no longer payable",
+			Data:    map[string]any{"status": status},
+		})
+		return
+	}
+
+	err = common.PublishMessage(ctx, in.Publisher, constant.SubjectCallbackPayment, req)
 	if err != nil {
 		slog.ErrorContext(ctx, "error publish message when callback payment", slog.Any(constant.LogFieldErr, err))
+		in.updateCallbackOutcome(ctx, callbackId, constant.PaymentCallbackOutcomePublishFailed)
 		writeErrorResponse(w, err)
 		return
 	}
 
+	in.updateCallbackOutcome(ctx, callbackId, constant.PaymentCallbackOutcomeQueued)
 	w.WriteHeader(http.StatusOK)
 }
+
+// findOrderStatus returns the status of the order a callback refers to, or an
+// empty status when the order does not exist. Only final statuses are cached,
+// pending and cancelled orders still change, see terminalOrderStatus, so they
+// are always read from the database.
+func (in PaymentHttp) findOrderStatus(ctx context.Context, externalId string) (sqlgen.OrderStatus, error) {
+	key := fmt.Sprintf(constant.OrderStatusIndexKey, externalId)
+
+	cached, err := in.Cache.Get(ctx, key).Result()
+	if err == nil {
+		return sqlgen.OrderStatus(cached), nil
+	}
+
+	if err != redis.Nil {
+		slog.WarnContext(ctx, "failed to get cached order status, falling back to database", slog.Any(constant.LogFieldErr, err))
+	}
+
+	status, err := in.Querier.FindOrderStatusByExternalId(ctx, externalId)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !terminalOrderStatus(status.OrderStatus) {
+		return status.OrderStatus, nil
+	}
+
+	err = in.Cache.Set(ctx, key, string(status.OrderStatus), constant.OrderStatusIndexTTL).Err()
+	if err != nil {
+		slog.WarnContext(ctx, "failed to cache order status", slog.Any(constant.LogFieldErr, err))
+	}
+
+	return status.OrderStatus, nil
+}
+
+// terminalOrderStatus reports whether status is final. A pending order is
+// completed or cancelled, and a cancelled one is reinstated or marked for
+// refund by a late payment.
+func terminalOrderStatus(status sqlgen.OrderStatus) bool {
+	return status == sqlgen.OrderStatusCompleted || status == sqlgen.OrderStatusRefundRequired
+}
+
+// callbackAccepted reports whether a callback may still change the order. A
+// cancelled order only takes paid callbacks, which are handled as late
+// payments.
+func callbackAccepted(status sqlgen.OrderStatus, paymentStatus string) bool {
+	switch status {
+	case sqlgen.OrderStatusPending:
+		return true
+	case sqlgen.OrderStatusCancelled:
+		return paymentStatus == constant.PaymentStatusPaid
+	default:
+		return false
+	}
+}
+
+// recordCallback appends the delivery to the ledger, keyed by its callback id,
+// before it is published and reports whether it is new. A delivery for a
+// provider transaction id that is already received or queued under another
+// callback id is recorded as a duplicate. A retry of a callback id whose
+// publish failed is evaluated again, the raw body is signed so the row is kept
+// as is. Two deliveries racing for the same transaction hit the partial unique
+// index, the loser gets a server error and is retried by the provider.
+func (in PaymentHttp) recordCallback(ctx context.Context, r *http.Request, req model.PaymentCallbackRequest, body []byte, outcome string) (bool, error) {
+	headers, err := json.Marshal(r.Header)
+	if err != nil {
+		return false, err
+	}
+
+	recorded, err := in.Querier.InsertPaymentCallback(ctx, sqlgen.InsertPaymentCallbackParams{
+		ProviderTransactionID: req.ProviderTransactionId,
+		ExternalID:            req.ExternalId,
+		CallbackID:            r.Header.Get(constant.HeaderCallbackId),
+		Status:                req.Status,
+		Payload:               string(body),
+		Headers:               headers,
+		ReceivedAt:            pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+		Outcome:               outcome,
+	})
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return recorded == constant.PaymentCallbackOutcomeReceived, nil
+}
+
+// updateCallbackOutcome is best effort, the callback has already been answered
+// by the time the outcome is known and the ledger row stays as received.
+func (in PaymentHttp) updateCallbackOutcome(ctx context.Context, callbackId, outcome string) {
+	err := in.Querier.UpdatePaymentCallbackOutcome(ctx, sqlgen.UpdatePaymentCallbackOutcomeParams{
+		Outcome:    outcome,
+		UpdatedAt:  pgtype.Timestamp{Time: in.TimeNow(), Valid: true},
+		CallbackID: callbackId,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update payment callback outcome",
+			slog.String("callback_id", callbackId),
+			slog.String("outcome", outcome),
+			slog.Any(constant.LogFieldErr, err),
+		)
+	}
+}
+
+func (in PaymentHttp) listCallbacks(w http.ResponseWriter, r *http.Request) {
+	externalId := r.PathValue("external_id")
+	if externalId == "" {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "PaymentHttp.listCallbacks")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+
+	callbacks, err := in.Querier.FindPaymentCallbacksByExternalId(ctx, externalId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find payment callbacks", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	resp := make([]model.PaymentCallbackResponse, 0, len(callbacks))
+	for _, callback := range callbacks {
+		item := model.PaymentCallbackResponse{
+			Id:                    callback.ID,
+			ProviderTransactionId: callback.ProviderTransactionID,
+			CallbackId:            callback.CallbackID,
+			Status:                callback.Status,
+			Outcome:               callback.Outcome,
+			Payload:               callback.Payload,
+			ReceivedAt:            callback.ReceivedAt.Time.Format(time.RFC3339),
+		}
+
+		if err := json.Unmarshal(callback.Headers, &item.Headers); err != nil {
+			slog.ErrorContext(ctx, "failed to unmarshal payment callback headers", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if callback.UpdatedAt.Valid {
+			updatedAt := callback.UpdatedAt.Time.Format(time.RFC3339)
+			item.UpdatedAt = &updatedAt
+		}
+
+		resp = append(resp, item)
+	}
+
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+// verifyCallback checks the signature headers of a gateway callback and returns
+// the rejection reason, or an empty string when the callback is authentic. The
+// callback id is only recorded as seen once the signature is valid, so forged
+// requests cannot burn the nonce of a real callback.
+func (in PaymentHttp) verifyCallback(r *http.Request, body []byte) (string, error) {
+	callbackId := r.Header.Get(constant.HeaderCallbackId)
+	timestamp := r.Header.Get(constant.HeaderCallbackTimestamp)
+	signature := r.Header.Get(constant.HeaderCallbackSignature)
+
+	if callbackId == "" || timestamp == "" || signature == "" {
+		return "missing_signature", nil
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "invalid_timestamp", nil
+	}
+
+	age := in.TimeNow().Sub(time.Unix(unix, 0))
+	if age > in.callbackTolerance || age < -in.callbackTolerance {
+		return "stale_timestamp", nil
+	}
+
+	expected := common.SignPaymentCallback(in.callbackSecret, callbackId, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "invalid_signature", nil
+	}
+
+	// The nonce outlives the accepted timestamp window on both sides, after
+	// that the callback is rejected as stale anyway.
+	fresh, err := in.Cache.SetNX(r.Context(), fmt.Sprintf(constant.PaymentCallbackNonceKey, callbackId), true, 2*in.callbackTolerance).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if !fresh {
+		return "replayed_callback", nil
+	}
+
+	return "", nil
+}
+
+func (in PaymentHttp) releaseCallbackNonce(ctx context.Context, callbackId string) {
+	err := in.Cache.Del(ctx, fmt.Sprintf(constant.PaymentCallbackNonceKey, callbackId)).Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to release payment callback nonce",
+			slog.String("callback_id", callbackId),
+			slog.Any(constant.LogFieldErr, err),
+		)
+	}
+}
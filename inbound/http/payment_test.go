@@ -1,22 +1,40 @@
 package http
 
 import (
+	"concert-ticket/common"
 	"concert-ticket/common/constant"
 	jetsteamMock "concert-ticket/common/jetstream/mocks"
+	"concert-ticket/outbound/sqlgen"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 type PaymentHttpTestSuite struct {
 	suite.Suite
 
+	Cfg *viper.Viper
+
+	Querier *sqlgen.Queries
+	PgxMock pgxmock.PgxPoolIface
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
 	Validate  *validator.Validate
 	Publisher *jetsteamMock.MockPublisher
 }
@@ -24,60 +42,501 @@ type PaymentHttpTestSuite struct {
 func (s *PaymentHttpTestSuite) SetupTest() {
 	ctrl := gomock.NewController(s.T())
 
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.Querier = sqlgen.New(pool)
+
 	s.Validate = validator.New()
 	s.Publisher = jetsteamMock.NewMockPublisher(ctrl)
 
+	s.Cfg = viper.New()
+	s.Cfg.Set("payment.callback.secret", "test-secret")
+	s.Cfg.Set("payment.callback.tolerance", "5m")
+	s.Cfg.Set("admin.token", "admin-token")
+
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 }
 
+func (s *PaymentHttpTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
 func TestPaymentHttpTestSuite(t *testing.T) {
 	suite.Run(t, new(PaymentHttpTestSuite))
 }
 
 func (s *PaymentHttpTestSuite) TestCallback() {
+	fixedTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	timestamp := strconv.FormatInt(fixedTime.Unix(), 10)
+	nonceKey := fmt.Sprintf(constant.PaymentCallbackNonceKey, "callback-1")
+	fixedTimestamp := pgtype.Timestamp{Time: fixedTime, Valid: true}
+	statusKey := fmt.Sprintf(constant.OrderStatusIndexKey, "test-id-123")
+	failedBody := `{"external_id":"test-id-123","provider_transaction_id":"trx-1","status":"failed","amount":0,"currency":"IDR"}`
+
+	validBody := `{"external_id":"test-id-123","provider_transaction_id":"trx-1","status":"paid","amount":11000000,"currency":"IDR"}`
+
+	sign := func(timestamp, body string) string {
+		return common.SignPaymentCallback([]byte("test-secret"), "callback-1", timestamp, []byte(body))
+	}
+
 	tests := []struct {
 		name           string
 		reqBody        string
+		headers        map[string]string
 		setupMock      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
-			name:           "invalid json",
-			reqBody:        `{invalid json`,
+			name:           "missing signature",
+			reqBody:        validBody,
+			headers:        map[string]string{},
+			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"missing_signature"}}`,
+		},
+		{
+			name:    "invalid timestamp",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: "yesterday",
+				constant.HeaderCallbackSignature: sign("yesterday", validBody),
+			},
+			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"invalid_timestamp"}}`,
+		},
+		{
+			name:    "stale timestamp",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: strconv.FormatInt(fixedTime.Add(-6*time.Minute).Unix(), 10),
+				constant.HeaderCallbackSignature: sign(strconv.FormatInt(fixedTime.Add(-6*time.Minute).Unix(), 10), validBody),
+			},
 			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"stale_timestamp"}}`,
+		},
+		{
+			name:    "invalid signature",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, `{"external_id":"other-id","provider_transaction_id":"trx-1","status":"paid","amount":11000000,"currency":"IDR"}`),
+			},
+			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"invalid_signature"}}`,
+		},
+		{
+			name:    "callback id not covered by the signature",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-2",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"invalid_signature"}}`,
+		},
+		{
+			name:    "nonce error",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetErr(redis.ErrClosed)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "replayed callback",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(false)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized","data":{"reason":"replayed_callback"}}`,
+		},
+		{
+			name:    "invalid json",
+			reqBody: `{invalid json`,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, `{invalid json`),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"error":"Invalid request"}`,
 		},
 		{
-			name:           "validation error - missing external_id",
-			reqBody:        `{}`,
-			setupMock:      func() {},
+			name:    "validation error - missing external_id",
+			reqBody: `{"provider_transaction_id":"trx-1","status":"paid","amount":11000000,"currency":"IDR"}`,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, `{"provider_transaction_id":"trx-1","status":"paid","amount":11000000,"currency":"IDR"}`),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"error":"Validation failed","data":{"ExternalId":"required"}}`,
 		},
+		{
+			name:    "validation error - unknown status",
+			reqBody: `{"external_id":"test-id-123","provider_transaction_id":"trx-1","status":"refunded","amount":11000000,"currency":"IDR"}`,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, `{"external_id":"test-id-123","provider_transaction_id":"trx-1","status":"refunded","amount":11000000,"currency":"IDR"}`),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Status":"oneof"}}`,
+		},
+		{
+			name:    "order status lookup error",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnError(fmt.Errorf("database error"))
+
+				s.CacheMock.ExpectDel(nonceKey).SetVal(1)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "unknown order",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnError(pgx.ErrNoRows)
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeNotFound).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeNotFound))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Order not found"}`,
+		},
+		{
+			name:    "unknown order record error",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnError(pgx.ErrNoRows)
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeNotFound).
+					WillReturnError(fmt.Errorf("database error"))
+
+				s.CacheMock.ExpectDel(nonceKey).SetVal(1)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "completed order",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCompleted, Valid: true}))
+				s.CacheMock.ExpectSet(statusKey, "completed", constant.OrderStatusIndexTTL).SetVal("OK")
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomeRejected, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Order is no longer payable","data":{"status":"completed"}}`,
+		},
+		{
+			name:    "order marked for refund from cache",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).SetVal("refund_required")
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomeRejected, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Order is no longer payable","data":{"status":"refund_required"}}`,
+		},
+		{
+			name:    "failed callback for cancelled order",
+			reqBody: failedBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, failedBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCancelled, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "failed", failedBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomeRejected, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Order is no longer payable","data":{"status":"cancelled"}}`,
+		},
+		{
+			name:    "paid callback for cancelled order is a late payment",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).SetErr(redis.ErrClosed)
+
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusCancelled, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+
+				s.Publisher.EXPECT().Publish(
+					gomock.Any(),
+					constant.SubjectCallbackPayment,
+					[]byte(validBody),
+				).Return(nil, nil)
+
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomeQueued, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   ``,
+		},
+		{
+			name:    "record callback error",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnError(fmt.Errorf("database error"))
+
+				s.CacheMock.ExpectDel(nonceKey).SetVal(1)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "duplicate callback",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeDuplicate))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "callback id already handled",
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
+			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnError(pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:    "publish message error",
-			reqBody: `{"external_id": "test-id-123"}`,
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
 			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectCallbackPayment,
 					gomock.Any(),
 				).Return(nil, fmt.Errorf("publish error"))
+
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomePublishFailed, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+				s.CacheMock.ExpectDel(nonceKey).SetVal(1)
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"Internal Server Error"}`,
 		},
 		{
 			name:    "success",
-			reqBody: `{"external_id": "test-id-123"}`,
+			reqBody: validBody,
+			headers: map[string]string{
+				constant.HeaderCallbackId:        "callback-1",
+				constant.HeaderCallbackTimestamp: timestamp,
+				constant.HeaderCallbackSignature: sign(timestamp, validBody),
+			},
 			setupMock: func() {
+				s.CacheMock.ExpectSetNX(nonceKey, true, 10*time.Minute).SetVal(true)
+				s.CacheMock.ExpectGet(statusKey).RedisNil()
+				s.PgxMock.ExpectQuery("SELECT status FROM orders").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows([]string{"status"}).
+						AddRow(sqlgen.NullOrderStatus{OrderStatus: sqlgen.OrderStatusPending, Valid: true}))
+
+				s.PgxMock.ExpectQuery("INSERT INTO payment_callbacks").
+					WithArgs("trx-1", "test-id-123", "callback-1", "paid", validBody, pgxmock.AnyArg(), fixedTimestamp, constant.PaymentCallbackOutcomeReceived).
+					WillReturnRows(pgxmock.NewRows([]string{"outcome"}).AddRow(constant.PaymentCallbackOutcomeReceived))
+
 				s.Publisher.EXPECT().Publish(
 					gomock.Any(),
 					constant.SubjectCallbackPayment,
-					gomock.Any(),
+					[]byte(validBody),
 				).Return(nil, nil)
+
+				s.PgxMock.ExpectExec("UPDATE payment_callbacks").
+					WithArgs(constant.PaymentCallbackOutcomeQueued, fixedTimestamp, "callback-1").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -87,14 +546,23 @@ func (s *PaymentHttpTestSuite) TestCallback() {
 		s.Run(tc.name, func() {
 			paymentHttp := RegisterPaymentHttp(
 				http.NewServeMux(),
+				s.Cfg,
+				s.Querier,
+				s.Cache,
 				s.Publisher,
 				s.Validate,
 			)
+			paymentHttp.TimeNow = func() time.Time {
+				return fixedTime
+			}
 
 			tc.setupMock()
 
 			req := httptest.NewRequest(http.MethodPost, "/api/payments/callback", strings.NewReader(tc.reqBody))
 			req.Header.Set("Content-Type", "application/json")
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
 			w := httptest.NewRecorder()
 
 			paymentHttp.callback(w, req)
@@ -107,6 +575,135 @@ func (s *PaymentHttpTestSuite) TestCallback() {
 			} else {
 				s.Empty(w.Body.String())
 			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *PaymentHttpTestSuite) TestListCallbacks() {
+	callbackColumns := []string{"id", "provider_transaction_id", "external_id", "callback_id", "status", "payload", "headers", "outcome", "received_at", "updated_at"}
+	receivedAt := pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	updatedAt := pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 0, 1, 0, time.UTC), Valid: true}
+
+	tests := []struct {
+		name           string
+		externalId     string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing external id",
+			externalId:     "",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:       "database error",
+			externalId: "test-id-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM payment_callbacks").
+					WithArgs("test-id-123").
+					WillReturnError(fmt.Errorf("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:       "no callbacks",
+			externalId: "test-id-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM payment_callbacks").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows(callbackColumns))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+		},
+		{
+			name:       "success",
+			externalId: "test-id-123",
+			setupMock: func() {
+				s.PgxMock.ExpectQuery("SELECT (.+) FROM payment_callbacks").
+					WithArgs("test-id-123").
+					WillReturnRows(pgxmock.NewRows(callbackColumns).
+						AddRow(int64(1), "trx-1", "test-id-123", "callback-1", "paid",
+							`{"external_id":"test-id-123","provider_transaction_id":"trx-1","status":"paid","amount":11000000,"currency":"IDR"}`,
+							[]byte(`{"X-Callback-Id":["callback-1"]}`),
+							constant.PaymentCallbackOutcomeQueued, receivedAt, updatedAt))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":1,"provider_transaction_id":"trx-1","callback_id":"callback-1","status":"paid","outcome":"queued","payload":"{\"external_id\":\"test-id-123\",\"provider_transaction_id\":\"trx-1\",\"status\":\"paid\",\"amount\":11000000,\"currency\":\"IDR\"}","headers":{"X-Callback-Id":["callback-1"]},"received_at":"2023-01-01T00:00:00Z","updated_at":"2023-01-01T00:00:01Z"}]`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			paymentHttp := RegisterPaymentHttp(
+				http.NewServeMux(),
+				s.Cfg,
+				s.Querier,
+				s.Cache,
+				s.Publisher,
+				s.Validate,
+			)
+
+			tc.setupMock()
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/payments/"+tc.externalId+"/callbacks", nil)
+			req.SetPathValue("external_id", tc.externalId)
+			w := httptest.NewRecorder()
+
+			paymentHttp.listCallbacks(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func (s *PaymentHttpTestSuite) TestListCallbacksRequiresAdmin() {
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "without credentials",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:           "with a non admin token",
+			authorization:  "Bearer cancel-token",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Forbidden"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			mux := http.NewServeMux()
+			RegisterPaymentHttp(mux, s.Cfg, s.Querier, s.Cache, s.Publisher, s.Validate)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/payments/test-id-123/callbacks", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			CorsMiddleware(mux).ServeHTTP(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+
+			s.NoError(s.PgxMock.ExpectationsWereMet())
 		})
 	}
 }
@@ -0,0 +1,255 @@
+package http
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/common/contract"
+	"concert-ticket/common/errs"
+	"concert-ticket/common/otel"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"concert-ticket/outbound/sqlgen"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// SeatHttp places seat holds. The seats of a hold are marked held in the
+// database until held_until, so auto allocation skips them, and the hold
+// record is kept in cache for the order that consumes it.
+type SeatHttp struct {
+	Db       contract.DbConn
+	Querier  *sqlgen.Queries
+	Cache    *redis.Client
+	Validate *validator.Validate
+
+	TimeNow func() time.Time
+
+	// holdTTL follows the longest expiry of the enabled payment methods, the
+	// method is only picked when the hold is ordered, so a hold lasts as long
+	// as the buyer could have to pay for the order it ends up in.
+	holdTTL              time.Duration
+	maxSeats             int
+	selectableCategories []int16
+}
+
+func RegisterSeatHttp(mux *http.ServeMux, cfg *viper.Viper, db contract.DbConn, querier *sqlgen.Queries, cache *redis.Client, validate *validator.Validate) *SeatHttp {
+	in := &SeatHttp{
+		Db:       db,
+		Querier:  querier,
+		Cache:    cache,
+		Validate: validate,
+		TimeNow:  time.Now,
+
+		holdTTL:              longestExpiredAfter(cfg),
+		maxSeats:             cfg.GetInt("order.max_quantity"),
+		selectableCategories: loadSelectableCategories(cfg),
+	}
+
+	mux.HandleFunc("POST /api/seats/hold", in.hold)
+
+	return in
+}
+
+// longestExpiredAfter returns the longest expiry of the enabled payment
+// methods, or order.expired_after when no method is enabled.
+func longestExpiredAfter(cfg *viper.Viper) time.Duration {
+	methods := loadPaymentMethods(cfg)
+	if len(methods) == 0 {
+		return cfg.GetDuration("order.expired_after")
+	}
+
+	var longest time.Duration
+	for _, expiredAfter := range methods {
+		longest = max(longest, expiredAfter)
+	}
+
+	return longest
+}
+
+func (in SeatHttp) hold(w http.ResponseWriter, r *http.Request) {
+	var req model.HoldSeatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	if err := in.validateHoldSeatsRequest(req); err != nil {
+		writeErrorResponse(w, err)
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "SeatHttp.hold")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+	slog.InfoContext(ctx, "hold seats receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
+
+	holdId := ulid.Make().String()
+	hold, err := json.Marshal(model.SeatHold{CategoryId: req.CategoryId, Seats: req.Seats})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal seat hold", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	tx, err := in.Db.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != pgx.ErrTxClosed {
+			slog.ErrorContext(ctx, "failed to rollback transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		}
+	}()
+
+	expiredAt := in.TimeNow().Add(in.holdTTL)
+	rows, cols := seatPositions(req.Seats)
+	placed, err := in.Querier.WithTx(tx).PlaceSeatHold(ctx, sqlgen.PlaceSeatHoldParams{
+		HoldID:     pgtype.Text{String: holdId, Valid: true},
+		HeldUntil:  pgtype.Timestamp{Time: expiredAt, Valid: true},
+		CategoryID: req.CategoryId,
+		Rows:       rows,
+		Cols:       cols,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to place seat hold", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	if placed != int64(len(req.Seats)) {
+		slog.DebugContext(ctx, "seats are not available", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Seats are not available"})
+		return
+	}
+
+	err = in.Cache.Set(ctx, fmt.Sprintf(constant.SeatHoldKey, holdId), string(hold), in.holdTTL).Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to cache seat hold", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to commit transaction", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	slog.InfoContext(ctx, "hold seats success", traceIdAttr, slog.String("hold_id", holdId))
+
+	writeJSONResponse(w, http.StatusOK, model.HoldSeatsResponse{
+		HoldId:     holdId,
+		CategoryId: req.CategoryId,
+		Seats:      req.Seats,
+		ExpiredAt:  expiredAt.Format(time.RFC3339),
+	})
+}
+
+func (in SeatHttp) validateHoldSeatsRequest(req model.HoldSeatsRequest) error {
+	if err := in.Validate.Struct(req); err != nil {
+		return err
+	}
+
+	if _, ok := vars.GetCategory(req.CategoryId); !ok {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"CategoryId": "not found",
+			},
+		}
+	}
+
+	if !slices.Contains(in.selectableCategories, req.CategoryId) {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"CategoryId": "not selectable",
+			},
+		}
+	}
+
+	if len(req.Seats) > in.maxSeats {
+		return &errs.HttpError{
+			Code:    http.StatusBadRequest,
+			Message: "Validation failed",
+			Data: map[string]any{
+				"Seats": "max",
+			},
+		}
+	}
+
+	seen := make(map[model.SeatPosition]bool, len(req.Seats))
+	for _, seat := range req.Seats {
+		if seen[seat] {
+			return &errs.HttpError{
+				Code:    http.StatusBadRequest,
+				Message: "Validation failed",
+				Data: map[string]any{
+					"Seats": "duplicate",
+				},
+			}
+		}
+
+		seen[seat] = true
+	}
+
+	return nil
+}
+
+// getSeatHold returns the hold, or redis.Nil when it does not exist or has expired.
+func getSeatHold(ctx context.Context, cache *redis.Client, holdId string) (model.SeatHold, error) {
+	var hold model.SeatHold
+
+	data, err := cache.Get(ctx, fmt.Sprintf(constant.SeatHoldKey, holdId)).Result()
+	if err != nil {
+		return hold, err
+	}
+
+	err = json.Unmarshal([]byte(data), &hold)
+	return hold, err
+}
+
+// releaseSeatHold drops the hold from cache once its seats are tracked by an order.
+func releaseSeatHold(ctx context.Context, cache *redis.Client, holdId string) error {
+	return cache.Del(ctx, fmt.Sprintf(constant.SeatHoldKey, holdId)).Err()
+}
+
+func seatPositions(seats []model.SeatPosition) ([]int32, []int32) {
+	rows := make([]int32, len(seats))
+	cols := make([]int32, len(seats))
+	for i, seat := range seats {
+		rows[i] = seat.Row
+		cols[i] = seat.Col
+	}
+
+	return rows, cols
+}
+
+// loadSelectableCategories reads the categories whose buyers pick their own
+// seats, every other category keeps seats assigned after payment.
+func loadSelectableCategories(cfg *viper.Viper) []int16 {
+	var categories []int16
+	for _, categoryId := range cfg.GetIntSlice("seat.selectable_categories") {
+		categories = append(categories, int16(categoryId))
+	}
+
+	return categories
+}
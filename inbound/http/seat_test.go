@@ -0,0 +1,285 @@
+package http
+
+import (
+	"concert-ticket/common/vars"
+	"concert-ticket/outbound/sqlgen"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redismock/v9"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SeatHttpTestSuite struct {
+	suite.Suite
+
+	Cfg *viper.Viper
+
+	Querier *sqlgen.Queries
+	PgxMock pgxmock.PgxPoolIface
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
+	Validate *validator.Validate
+}
+
+func (s *SeatHttpTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		s.T().Fatalf("failed to create pgxmock pool: %v", err)
+	}
+
+	s.PgxMock = pool
+	s.Querier = sqlgen.New(pool)
+
+	s.Validate = validator.New()
+
+	s.Cfg = viper.New()
+	s.Cfg.Set("order.expired_after", "10m")
+	s.Cfg.Set("payment.methods.bca_va.expired_after", "15m")
+	s.Cfg.Set("payment.methods.qris.expired_after", "5m")
+	s.Cfg.Set("order.max_quantity", 4)
+	s.Cfg.Set("seat.selectable_categories", []int{1})
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *SeatHttpTestSuite) TearDownTest() {
+	s.PgxMock.Close()
+
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestSeatHttpTestSuite(t *testing.T) {
+	suite.Run(t, new(SeatHttpTestSuite))
+}
+
+func (s *SeatHttpTestSuite) TestLongestExpiredAfter() {
+	tests := []struct {
+		name     string
+		methods  map[string]any
+		expected time.Duration
+	}{
+		{
+			name:     "no payment methods",
+			expected: 10 * time.Minute,
+		},
+		{
+			name:     "longest payment method",
+			methods:  map[string]any{"bca_va": map[string]any{"expired_after": "15m"}, "qris": map[string]any{"expired_after": "5m"}},
+			expected: 15 * time.Minute,
+		},
+		{
+			name:     "payment methods shorter than order.expired_after",
+			methods:  map[string]any{"qris": map[string]any{"expired_after": "30s"}, "ewallet": map[string]any{"expired_after": "45s"}},
+			expected: 45 * time.Second,
+		},
+		{
+			name:     "payment method without its own expiry",
+			methods:  map[string]any{"qris": map[string]any{"expired_after": "30s"}, "bni_va": map[string]any{}},
+			expected: 10 * time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			cfg := viper.New()
+			cfg.Set("order.expired_after", "10m")
+			if tc.methods != nil {
+				cfg.Set("payment.methods", tc.methods)
+			}
+
+			s.Equal(tc.expected, longestExpiredAfter(cfg))
+		})
+	}
+}
+
+func (s *SeatHttpTestSuite) TestHold() {
+	placeSeatHoldQuery := `UPDATE seats SET status = 'held', hold_id = \$1, held_until = \$2, updated_at = NOW\(\) WHERE category_id = \$3 AND \(row, col\) IN \(SELECT UNNEST\(\$4::integer\[\]\), UNNEST\(\$5::integer\[\]\)\) AND status = 'available'`
+	holdRecord := `{"category_id":1,"seats":[{"row":1,"col":1},{"row":1,"col":2}]}`
+	heldUntil := pgtype.Timestamp{Time: time.Date(2023, 1, 1, 0, 15, 0, 0, time.UTC), Valid: true}
+
+	expectPlaceSeatHold := func() *pgxmock.ExpectedExec {
+		return s.PgxMock.ExpectExec(placeSeatHoldQuery).
+			WithArgs(pgxmock.AnyArg(), heldUntil, int16(1), []int32{1, 1}, []int32{1, 2})
+	}
+
+	expectCacheSeatHold := func() *redismock.ExpectedStatus {
+		return s.CacheMock.Regexp().ExpectSet(`^seat:hold:[0-9A-Z]{26}$`, fmt.Sprintf("^%s$", regexp.QuoteMeta(holdRecord)), 15*time.Minute)
+	}
+
+	tests := []struct {
+		name           string
+		reqBody        string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "invalid json",
+			reqBody:        `{invalid json`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "validation error - missing seats",
+			reqBody:        `{"category_id": 1}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Seats":"required"}}`,
+		},
+		{
+			name:           "validation error - invalid row",
+			reqBody:        `{"category_id": 1, "seats": [{"row": 0, "col": 1}]}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Row":"min"}}`,
+		},
+		{
+			name:           "validation error - category not found",
+			reqBody:        `{"category_id": 99, "seats": [{"row": 1, "col": 1}]}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"CategoryId":"not found"}}`,
+		},
+		{
+			name:           "validation error - category not selectable",
+			reqBody:        `{"category_id": 2, "seats": [{"row": 1, "col": 1}]}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"CategoryId":"not selectable"}}`,
+		},
+		{
+			name:           "validation error - seats above max",
+			reqBody:        `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}, {"row": 1, "col": 3}, {"row": 1, "col": 4}, {"row": 1, "col": 5}]}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Seats":"max"}}`,
+		},
+		{
+			name:           "validation error - duplicate seats",
+			reqBody:        `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 1}]}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Seats":"duplicate"}}`,
+		},
+		{
+			name:    "begin transaction error",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin().WillReturnError(fmt.Errorf("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "place seat hold error",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				expectPlaceSeatHold().WillReturnError(fmt.Errorf("database error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			// Auto allocation claims available seats only, once a seat is
+			// sold to such an order the hold of it is refused as a whole.
+			name:    "seat sold to an auto allocated order",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				expectPlaceSeatHold().WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				s.PgxMock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Seats are not available"}`,
+		},
+		{
+			name:    "seats held by another buyer",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				expectPlaceSeatHold().WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+				s.PgxMock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Seats are not available"}`,
+		},
+		{
+			name:    "cache seat hold error",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				expectPlaceSeatHold().WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+				expectCacheSeatHold().SetErr(fmt.Errorf("redis error"))
+				s.PgxMock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:    "success",
+			reqBody: `{"category_id": 1, "seats": [{"row": 1, "col": 1}, {"row": 1, "col": 2}]}`,
+			setupMock: func() {
+				s.PgxMock.ExpectBegin()
+				expectPlaceSeatHold().WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+				expectCacheSeatHold().SetVal("OK")
+				s.PgxMock.ExpectCommit()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"category_id":1,"seats":[{"row":1,"col":1},{"row":1,"col":2}],"expired_at":"2023-01-01T00:15:00Z"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			seatHttp := RegisterSeatHttp(http.NewServeMux(), s.Cfg, s.PgxMock, s.Querier, s.Cache, s.Validate)
+			seatHttp.TimeNow = func() time.Time {
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			}
+
+			tc.setupMock()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/seats/hold", strings.NewReader(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			seatHttp.hold(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus == http.StatusOK {
+				s.Contains(w.Body.String(), tc.expectedBody, "Response should contain expected text")
+			} else {
+				actual := strings.TrimSpace(w.Body.String())
+				s.Equal(tc.expectedBody, actual)
+			}
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+			s.NoError(s.PgxMock.ExpectationsWereMet())
+		})
+	}
+}
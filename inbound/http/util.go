@@ -1,13 +1,15 @@
 package http
 
 import (
+	"bytes"
 	"concert-ticket/common/errs"
 	"concert-ticket/model"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"github.com/go-playground/validator/v10"
-	"github.com/oklog/ulid/v2"
 	"net/http"
-	"time"
+	"strings"
 )
 
 func writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
@@ -58,7 +60,39 @@ func writeErrorResponse(w http.ResponseWriter, err error) {
 	}
 }
 
-func generateDummyPaymentCode(externalId string, price int64) string {
-	time.Sleep(10 * time.Millisecond)
-	return ulid.Make().String()
+// responseRecorder passes writes through to the wrapped writer while keeping a
+// copy of the status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.status = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func hashRequest(req any) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
 }
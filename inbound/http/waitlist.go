@@ -0,0 +1,133 @@
+package http
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/common/errs"
+	"concert-ticket/common/otel"
+	"concert-ticket/common/vars"
+	"concert-ticket/model"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// joinWaitlistScript appends the buyer to the waitlist while the category is
+// sold out. KEYS are the counter, the waitlist and its emails, ARGV is the
+// email and the waitlist entry. It returns the position of the buyer, 0 when
+// the email is already waiting and -1 when tickets are still on sale.
+var joinWaitlistScript = redis.NewScript(`
+if tonumber(redis.call("GET", KEYS[1]) or "0") > 0 then
+	return -1
+end
+if redis.call("SADD", KEYS[3], ARGV[1]) == 0 then
+	return 0
+end
+return redis.call("RPUSH", KEYS[2], ARGV[2])
+`)
+
+type WaitlistHttp struct {
+	Cache    *redis.Client
+	Validate *validator.Validate
+
+	TimeNow func() time.Time
+}
+
+func RegisterWaitlistHttp(mux *http.ServeMux, cache *redis.Client, validate *validator.Validate) *WaitlistHttp {
+	in := &WaitlistHttp{
+		Cache:    cache,
+		Validate: validate,
+		TimeNow:  time.Now,
+	}
+
+	mux.HandleFunc("POST /api/categories/{id}/waitlist", in.join)
+
+	return in
+}
+
+// join puts the buyer at the end of the waitlist of a sold out category, each
+// restocked ticket is reserved for the head of the waitlist by serve-queue:waitlist.
+func (in WaitlistHttp) join(w http.ResponseWriter, r *http.Request) {
+	categoryId, err := strconv.ParseInt(r.PathValue("id"), 10, 16)
+	if err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	var req model.JoinWaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusBadRequest, Message: "Invalid request"})
+		return
+	}
+
+	if err := in.Validate.Struct(req); err != nil {
+		writeErrorResponse(w, err)
+		return
+	}
+
+	category, ok := vars.GetCategory(int16(categoryId))
+	if !ok {
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusNotFound, Message: "Category not found"})
+		return
+	}
+
+	now := in.TimeNow()
+	if err := checkOnSale(category, now); err != nil {
+		writeErrorResponse(w, err)
+		return
+	}
+
+	ctx, span := otel.Tracer.Start(r.Context(), "WaitlistHttp.join")
+	defer span.End()
+
+	traceIdAttr := common.ExtractTraceIDFromCtx(ctx)
+	slog.InfoContext(ctx, "join waitlist receive request", slog.Any(constant.LogFieldPayload, req), traceIdAttr)
+
+	entry, err := json.Marshal(model.WaitlistEntry{
+		Name:     req.Name,
+		Email:    req.Email,
+		JoinedAt: now.Format(time.RFC3339),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal waitlist entry", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	position, err := joinWaitlistScript.Run(ctx, in.Cache,
+		[]string{
+			fmt.Sprintf(constant.EachCategoryQuantityKey, category.EventId, category.Id),
+			fmt.Sprintf(constant.WaitlistKey, category.EventId, category.Id),
+			fmt.Sprintf(constant.WaitlistEmailsKey, category.EventId, category.Id),
+		},
+		req.Email, string(entry),
+	).Int64()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to join waitlist", traceIdAttr, slog.Any(constant.LogFieldErr, err))
+		writeErrorResponse(w, err)
+		return
+	}
+
+	switch position {
+	case -1:
+		slog.DebugContext(ctx, "category is not sold out", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Category is not sold out"})
+		return
+	case 0:
+		slog.DebugContext(ctx, "email already on the waitlist", traceIdAttr)
+		writeErrorResponse(w, &errs.HttpError{Code: http.StatusConflict, Message: "Email already on the waitlist"})
+		return
+	}
+
+	slog.InfoContext(ctx, "join waitlist success", traceIdAttr, slog.Any(constant.LogFieldResponse, position))
+
+	writeJSONResponse(w, http.StatusOK, model.JoinWaitlistResponse{
+		CategoryId: category.Id,
+		Position:   position,
+	})
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"concert-ticket/common/constant"
+	"concert-ticket/common/vars"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type WaitlistHttpTestSuite struct {
+	suite.Suite
+
+	Cache     *redis.Client
+	CacheMock redismock.ClientMock
+
+	Validate *validator.Validate
+}
+
+func (s *WaitlistHttpTestSuite) SetupTest() {
+	vars.SetEvents(testEvents)
+	vars.SetCategories(testCategories)
+
+	rdb, mock := redismock.NewClientMock()
+	s.Cache = rdb
+	s.CacheMock = mock
+
+	s.Validate = validator.New()
+
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+}
+
+func (s *WaitlistHttpTestSuite) TearDownTest() {
+	if err := s.Cache.Close(); err != nil {
+		s.T().Fatalf("failed to close redis mock: %v", err)
+	}
+}
+
+func TestWaitlistHttpTestSuite(t *testing.T) {
+	suite.Run(t, new(WaitlistHttpTestSuite))
+}
+
+func (s *WaitlistHttpTestSuite) TestJoin() {
+	expectJoinWaitlist := func() *redismock.ExpectedCmd {
+		return s.CacheMock.ExpectEvalSha(joinWaitlistScript.Hash(), []string{
+			fmt.Sprintf(constant.EachCategoryQuantityKey, 1, 2),
+			fmt.Sprintf(constant.WaitlistKey, 1, 2),
+			fmt.Sprintf(constant.WaitlistEmailsKey, 1, 2),
+		}, "jane@example.com", `{"name":"Jane Doe","email":"jane@example.com","joined_at":"2023-01-01T00:00:00Z"}`)
+	}
+
+	tests := []struct {
+		name           string
+		categoryId     string
+		reqBody        string
+		now            time.Time
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "invalid category id",
+			categoryId:     "abc",
+			reqBody:        `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "invalid json",
+			categoryId:     "2",
+			reqBody:        `{invalid json`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid request"}`,
+		},
+		{
+			name:           "validation error - invalid email",
+			categoryId:     "2",
+			reqBody:        `{"name": "Jane Doe", "email": "jane"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Validation failed","data":{"Email":"email"}}`,
+		},
+		{
+			name:           "category not found",
+			categoryId:     "99",
+			reqBody:        `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock:      func() {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Category not found"}`,
+		},
+		{
+			name:           "event sale ended",
+			categoryId:     "2",
+			reqBody:        `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			now:            time.Date(2101, 1, 1, 0, 0, 0, 0, time.UTC),
+			setupMock:      func() {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_ended"}}`,
+		},
+		{
+			name:       "category not on sale",
+			categoryId: "2",
+			reqBody:    `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock: func() {
+				setCategorySaleWindow(s.T(), 2, testSaleStartsAt, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Category is not on sale","data":{"reason":"sale_ended"}}`,
+		},
+		{
+			name:       "join waitlist error",
+			categoryId: "2",
+			reqBody:    `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock: func() {
+				expectJoinWaitlist().SetErr(fmt.Errorf("redis error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal Server Error"}`,
+		},
+		{
+			name:       "category not sold out",
+			categoryId: "2",
+			reqBody:    `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock: func() {
+				expectJoinWaitlist().SetVal(int64(-1))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Category is not sold out"}`,
+		},
+		{
+			name:       "email already on the waitlist",
+			categoryId: "2",
+			reqBody:    `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock: func() {
+				expectJoinWaitlist().SetVal(int64(0))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Email already on the waitlist"}`,
+		},
+		{
+			name:       "success",
+			categoryId: "2",
+			reqBody:    `{"name": "Jane Doe", "email": "jane@example.com"}`,
+			setupMock: func() {
+				expectJoinWaitlist().SetVal(int64(3))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"category_id":2,"position":3}`,
+		},
+	}
+
+	for _, tc := range tests {
+		s.Run(tc.name, func() {
+			waitlistHttp := RegisterWaitlistHttp(http.NewServeMux(), s.Cache, s.Validate)
+			waitlistHttp.TimeNow = func() time.Time {
+				if !tc.now.IsZero() {
+					return tc.now
+				}
+				return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			}
+
+			tc.setupMock()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/categories/"+tc.categoryId+"/waitlist", strings.NewReader(tc.reqBody))
+			req.SetPathValue("id", tc.categoryId)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			waitlistHttp.join(w, req)
+
+			s.Equal(tc.expectedStatus, w.Code)
+			s.Equal(tc.expectedBody, strings.TrimSpace(w.Body.String()))
+
+			s.NoError(s.CacheMock.ExpectationsWereMet())
+		})
+	}
+}
@@ -4,6 +4,7 @@ import {check, fail} from "k6";
 import {Counter} from 'k6/metrics';
 
 const BaseUrl = 'http://localhost:8080/api';
+const EventId = 1;
 
 const CounterTicketRush = new Counter('ticket_rush');
 const CounterSameEmail = new Counter('same_email');
@@ -31,7 +32,7 @@ const data = new SharedArray('some name', function () {
 const emailLength = data.length;
 
 export default function () {
-    const categoriesRes = http.get(`${BaseUrl}/categories`, {
+    const categoriesRes = http.get(`${BaseUrl}/events/${EventId}/categories`, {
         tags: {ListCategories: 'get'},
     });
 
@@ -40,7 +41,7 @@ export default function () {
         fail('Failed to list tickets');
     }
 
-    const categories = categoriesRes.json();
+    const categories = categoriesRes.json().categories;
     let randomCategory;
 
     // Higher probability (70%) for selecting categories with ID > 5
@@ -74,7 +75,7 @@ export default function () {
         'category_id': randomCategory.id,
     };
 
-    const orderRes = http.post(`${BaseUrl}/orders`, JSON.stringify(createOrderReq), {
+    const orderRes = http.post(`${BaseUrl}/events/${EventId}/orders`, JSON.stringify(createOrderReq), {
         headers: {'Content-Type': 'application/json'}, tags: {CreateOrder: 'post'},
     });
 
@@ -3,6 +3,7 @@ package main
 import (
 	"concert-ticket/cmd"
 	_ "go.uber.org/automaxprocs"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -1 +1,2 @@
-mockgen -package=mocks -destination=common/jetstream/mocks/mock_jetstream_publisher.go github.com/nats-io/nats.go/jetstream Publisher
\ No newline at end of file
+mockgen -package=mocks -destination=common/jetstream/mocks/mock_jetstream_publisher.go github.com/nats-io/nats.go/jetstream Publisher
+mockgen -package=mocks -destination=outbound/payment/mocks/mock_payment_gateway.go concert-ticket/outbound/payment PaymentGateway
\ No newline at end of file
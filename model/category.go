@@ -1,13 +1,31 @@
 package model
 
+import "time"
+
+// CategoryResponse is one category as kept in the registry. SaleStartsAt and
+// SaleEndsAt are the window the category is sold in, within the sale window of
+// its event, OnSale is only filled in when categories are listed.
 type CategoryResponse struct {
-	Id       int16  `json:"id"`
-	Name     string `json:"name"`
-	Price    int32  `json:"price"`
-	Quantity int32  `json:"quantity"`
+	Id           int16     `json:"id"`
+	EventId      int16     `json:"event_id"`
+	Name         string    `json:"name"`
+	Price        int32     `json:"price"`
+	Quantity     int32     `json:"quantity"`
+	SeatingType  string    `json:"seating_type"`
+	SaleStartsAt time.Time `json:"sale_starts_at"`
+	SaleEndsAt   time.Time `json:"sale_ends_at"`
+	OnSale       bool      `json:"on_sale"`
+}
+
+// OnSaleAt reports whether the category can be ordered at t.
+func (c CategoryResponse) OnSaleAt(t time.Time) bool {
+	return !t.Before(c.SaleStartsAt) && t.Before(c.SaleEndsAt)
 }
 
+// ListCategoriesResponse carries the server time so clients count down to a
+// sale window with the clock of the server rather than their own.
 type ListCategoriesResponse struct {
+	ServerTime string             `json:"server_time"`
 	Categories []CategoryResponse `json:"categories"`
 }
 
@@ -15,3 +33,27 @@ type IncrementCategoryQuantityEventMessage struct {
 	ID       int16 `json:"id"`
 	Quantity int32 `json:"quantity"`
 }
+
+// SeatMapResponse is the availability grid of a category, Seats holds one
+// string per row with one character per column, see constant.SeatMapAvailable.
+// Layout places the same seats by the section, labels and coordinates of the
+// imported venue layout.
+type SeatMapResponse struct {
+	CategoryId int16         `json:"category_id"`
+	Rows       int32         `json:"rows"`
+	Cols       int32         `json:"cols"`
+	Available  int32         `json:"available"`
+	Seats      []string      `json:"seats"`
+	Layout     []SeatMapSeat `json:"layout"`
+}
+
+type SeatMapSeat struct {
+	Section   string  `json:"section"`
+	RowLabel  string  `json:"row_label"`
+	SeatLabel string  `json:"seat_label"`
+	Row       int32   `json:"row"`
+	Col       int32   `json:"col"`
+	X         float32 `json:"x"`
+	Y         float32 `json:"y"`
+	Status    string  `json:"status"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Event is one row of the events table as kept in the registry, Location is
+// loaded from Timezone and used for every time shown to buyers of the event.
+type Event struct {
+	Id           int16
+	Name         string
+	Venue        string
+	City         string
+	Timezone     string
+	Location     *time.Location
+	StartsAt     time.Time
+	SaleStartsAt time.Time
+	SaleEndsAt   time.Time
+	OrderPrefix  string
+	BrandName    string
+	SupportEmail string
+}
+
+type EventResponse struct {
+	Id           int16  `json:"id"`
+	Name         string `json:"name"`
+	Venue        string `json:"venue"`
+	City         string `json:"city"`
+	Timezone     string `json:"timezone"`
+	StartsAt     string `json:"starts_at"`
+	SaleStartsAt string `json:"sale_starts_at"`
+	SaleEndsAt   string `json:"sale_ends_at"`
+}
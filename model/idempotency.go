@@ -0,0 +1,7 @@
+package model
+
+type IdempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
@@ -0,0 +1,32 @@
+package model
+
+type InventoryReportEntry struct {
+	EventId    int16  `json:"event_id"`
+	CategoryId int16  `json:"category_id"`
+	Capacity   int32  `json:"capacity"`
+	Reserved   int32  `json:"reserved"`
+	Waitlisted int32  `json:"waitlisted"`
+	Expected   int32  `json:"expected"`
+	Cache      int64  `json:"cache"`
+	CacheDrift int64  `json:"cache_drift"`
+	Db         int32  `json:"db"`
+	DbDrift    int32  `json:"db_drift"`
+	IdleSince  string `json:"idle_since"`
+	Repaired   bool   `json:"repaired"`
+}
+
+// InventorySnapshot is the figures of a category seen by the last reconcile
+// and since when they have not changed.
+type InventorySnapshot struct {
+	Cache      int64  `json:"cache"`
+	Reserved   int32  `json:"reserved"`
+	Waitlisted int32  `json:"waitlisted"`
+	Db         int32  `json:"db"`
+	SeenAt     string `json:"seen_at"`
+}
+
+type InventoryReport struct {
+	CheckedAt  string                 `json:"checked_at"`
+	Drifted    int                    `json:"drifted"`
+	Categories []InventoryReportEntry `json:"categories"`
+}
@@ -1,30 +1,79 @@
 package model
 
+// CreateOrderRequest is the body of an order, EventId is taken from the path
+// and a missing PaymentMethod is defaulted before the request is validated or
+// hashed for idempotency.
 type CreateOrderRequest struct {
-	Name       string `json:"name" validate:"required,max=100"`
-	Email      string `json:"email" validate:"required,email"`
-	CategoryId int16  `json:"category_id" validate:"required"`
+	EventId       int16  `json:"event_id"`
+	Name          string `json:"name" validate:"required,max=100"`
+	Email         string `json:"email" validate:"required,email"`
+	CategoryId    int16  `json:"category_id" validate:"required"`
+	Quantity      int32  `json:"quantity" validate:"omitempty,min=1"`
+	PaymentMethod string `json:"payment_method" validate:"required"`
+	HoldId        string `json:"hold_id,omitempty"`
 }
 
 type CreateOrderResponse struct {
-	Id          int32  `json:"id"`
-	ExternalId  string `json:"external_id"`
-	PaymentCode string `json:"payment_code"`
+	Id            int32          `json:"id"`
+	ExternalId    string         `json:"external_id"`
+	PaymentMethod string         `json:"payment_method"`
+	PaymentCode   string         `json:"payment_code"`
+	ExpiredAt     string         `json:"expired_at"`
+	CancelToken   string         `json:"cancel_token,omitempty"`
+	Price         PriceBreakdown `json:"price"`
 }
 
 type CreateOrderEventMessage struct {
-	ID          int32  `json:"id"`
-	CategoryID  int16  `json:"category_id"`
-	ExternalID  string `json:"external_id"`
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	PaymentCode string `json:"payment_code"`
-	ExpiredAt   string `json:"expired_at"`
+	ID            int32          `json:"id"`
+	CategoryID    int16          `json:"category_id"`
+	Quantity      int32          `json:"quantity"`
+	ExternalID    string         `json:"external_id"`
+	Name          string         `json:"name"`
+	Email         string         `json:"email"`
+	PaymentMethod string         `json:"payment_method"`
+	PaymentCode   string         `json:"payment_code"`
+	ExpiredAt     string         `json:"expired_at"`
+	Price         PriceBreakdown `json:"price"`
 }
 
 type AssignOrderTicketRowCol struct {
-	ID         int32  `json:"id"`
-	CategoryId int16  `json:"category_id"`
-	Email      string `json:"email"`
-	Name       string `json:"name"`
+	ID         int32          `json:"id"`
+	CategoryId int16          `json:"category_id"`
+	Quantity   int32          `json:"quantity"`
+	Email      string         `json:"email"`
+	Name       string         `json:"name"`
+	Price      PriceBreakdown `json:"price"`
+}
+
+type GetOrderResponse struct {
+	Id            int32                 `json:"id"`
+	ExternalId    string                `json:"external_id"`
+	Status        string                `json:"status"`
+	EventId       int16                 `json:"event_id"`
+	EventName     string                `json:"event_name"`
+	CategoryId    int16                 `json:"category_id"`
+	CategoryName  string                `json:"category_name"`
+	SeatingType   string                `json:"seating_type"`
+	Quantity      int32                 `json:"quantity"`
+	PaymentMethod string                `json:"payment_method"`
+	PaymentCode   string                `json:"payment_code"`
+	Price         PriceBreakdown        `json:"price"`
+	ExpiredAt     string                `json:"expired_at"`
+	TicketRow     *int32                `json:"ticket_row,omitempty"`
+	TicketCol     *int32                `json:"ticket_col,omitempty"`
+	Tickets       []OrderTicketResponse `json:"tickets,omitempty"`
+}
+
+type OrderTicketResponse struct {
+	Row             int32 `json:"row,omitempty"`
+	Col             int32 `json:"col,omitempty"`
+	AdmissionNumber int32 `json:"admission_number,omitempty"`
+}
+
+type PriceBreakdown struct {
+	BasePrice   int64 `json:"base_price"`
+	Subtotal    int64 `json:"subtotal"`
+	PlatformFee int64 `json:"platform_fee"`
+	Vat         int64 `json:"vat"`
+	Total       int64 `json:"total"`
 }
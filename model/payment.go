@@ -1,5 +1,28 @@
 package model
 
 type PaymentCallbackRequest struct {
-	ExternalId string `json:"external_id" validate:"required"`
+	ExternalId            string `json:"external_id" validate:"required,max=36"`
+	ProviderTransactionId string `json:"provider_transaction_id" validate:"required,max=100"`
+	Status                string `json:"status" validate:"required,oneof=paid failed expired"`
+	Amount                int64  `json:"amount" validate:"gte=0"`
+	Currency              string `json:"currency" validate:"required,len=3"`
+}
+
+type RefundPaymentEventMessage struct {
+	OrderId               int32  `json:"order_id"`
+	ExternalId            string `json:"external_id"`
+	ProviderTransactionId string `json:"provider_transaction_id"`
+	Amount                int64  `json:"amount"`
+}
+
+type PaymentCallbackResponse struct {
+	Id                    int64               `json:"id"`
+	ProviderTransactionId string              `json:"provider_transaction_id"`
+	CallbackId            string              `json:"callback_id"`
+	Status                string              `json:"status"`
+	Outcome               string              `json:"outcome"`
+	Payload               string              `json:"payload"`
+	Headers               map[string][]string `json:"headers"`
+	ReceivedAt            string              `json:"received_at"`
+	UpdatedAt             *string             `json:"updated_at,omitempty"`
 }
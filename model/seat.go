@@ -0,0 +1,24 @@
+package model
+
+type SeatPosition struct {
+	Row int32 `json:"row" validate:"min=1"`
+	Col int32 `json:"col" validate:"min=1"`
+}
+
+type HoldSeatsRequest struct {
+	CategoryId int16          `json:"category_id" validate:"required"`
+	Seats      []SeatPosition `json:"seats" validate:"required,min=1,dive"`
+}
+
+type HoldSeatsResponse struct {
+	HoldId     string         `json:"hold_id"`
+	CategoryId int16          `json:"category_id"`
+	Seats      []SeatPosition `json:"seats"`
+	ExpiredAt  string         `json:"expired_at"`
+}
+
+// SeatHold is the hold stored in cache until an order consumes it.
+type SeatHold struct {
+	CategoryId int16          `json:"category_id"`
+	Seats      []SeatPosition `json:"seats"`
+}
@@ -0,0 +1,34 @@
+package model
+
+type SettlementRow struct {
+	Line                  int
+	Reference             string
+	PaymentCode           string
+	ExternalId            string
+	ProviderTransactionId string
+	Amount                int64
+}
+
+type SettlementReportEntry struct {
+	Line                  int    `json:"line,omitempty"`
+	Reference             string `json:"reference,omitempty"`
+	OrderId               int32  `json:"order_id,omitempty"`
+	ExternalId            string `json:"external_id,omitempty"`
+	PaymentCode           string `json:"payment_code,omitempty"`
+	ProviderTransactionId string `json:"provider_transaction_id,omitempty"`
+	OrderStatus           string `json:"order_status,omitempty"`
+	ExpectedAmount        int64  `json:"expected_amount"`
+	SettledAmount         int64  `json:"settled_amount"`
+}
+
+type SettlementReport struct {
+	File               string                  `json:"file"`
+	From               string                  `json:"from"`
+	To                 string                  `json:"to"`
+	Matched            []SettlementReportEntry `json:"matched"`
+	PaidNotCompleted   []SettlementReportEntry `json:"paid_not_completed"`
+	CompletedUnsettled []SettlementReportEntry `json:"completed_unsettled"`
+	AmountMismatch     []SettlementReportEntry `json:"amount_mismatch"`
+	Unmatched          []SettlementReportEntry `json:"unmatched"`
+	Published          int                     `json:"published"`
+}
@@ -0,0 +1,61 @@
+package model
+
+// VenueLayout is the seating plan of an event read by the import-venue
+// command, a reserved category gets one ticket per seat of its sections while
+// a general admission category only has a capacity.
+type VenueLayout struct {
+	EventId    int16            `json:"event_id"`
+	Categories []LayoutCategory `json:"categories"`
+	Sections   []LayoutSection  `json:"sections"`
+}
+
+// LayoutCategory is a category of a venue layout. SaleStartsAt and SaleEndsAt
+// are RFC 3339 times narrowing the sale window of the event for the category,
+// either may be left empty.
+type LayoutCategory struct {
+	Id           int16  `json:"id"`
+	Name         string `json:"name"`
+	Price        int32  `json:"price"`
+	SeatingType  string `json:"seating_type"`
+	Capacity     int32  `json:"capacity"`
+	SaleStartsAt string `json:"sale_starts_at,omitempty"`
+	SaleEndsAt   string `json:"sale_ends_at,omitempty"`
+}
+
+type LayoutSection struct {
+	Name       string      `json:"name"`
+	CategoryId int16       `json:"category_id"`
+	Rows       []LayoutRow `json:"rows"`
+}
+
+type LayoutRow struct {
+	Label string       `json:"label"`
+	Seats []LayoutSeat `json:"seats"`
+}
+
+// LayoutSeat is placed on the seat map grid of its category by Row and Col,
+// X and Y are where the seat is drawn on the venue plan.
+type LayoutSeat struct {
+	Label string  `json:"label"`
+	Row   int32   `json:"row"`
+	Col   int32   `json:"col"`
+	X     float32 `json:"x"`
+	Y     float32 `json:"y"`
+}
+
+type VenueImportSummaryEntry struct {
+	CategoryId  int16  `json:"category_id"`
+	Name        string `json:"name"`
+	SeatingType string `json:"seating_type"`
+	Sections    int    `json:"sections"`
+	Rows        int    `json:"rows"`
+	Capacity    int32  `json:"capacity"`
+}
+
+type VenueImportSummary struct {
+	File       string                    `json:"file"`
+	EventId    int16                     `json:"event_id"`
+	DryRun     bool                      `json:"dry_run"`
+	Capacity   int32                     `json:"capacity"`
+	Categories []VenueImportSummaryEntry `json:"categories"`
+}
@@ -0,0 +1,42 @@
+package model
+
+type JoinWaitlistRequest struct {
+	Name  string `json:"name" validate:"required,max=100"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+type JoinWaitlistResponse struct {
+	CategoryId int16 `json:"category_id"`
+	Position   int64 `json:"position"`
+}
+
+// WaitlistEntry is a buyer waiting for one ticket of a sold out category.
+type WaitlistEntry struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	JoinedAt string `json:"joined_at"`
+}
+
+// WaitlistClaim is a ticket reserved for the head of a waitlist, it can be
+// turned into a pending order until ExpiredAt.
+type WaitlistClaim struct {
+	EventId    int16  `json:"event_id"`
+	CategoryId int16  `json:"category_id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	ExpiredAt  string `json:"expired_at"`
+}
+
+// ClaimWaitlistRequest picks the payment method of the claimed order, a missing
+// one is defaulted like it is for CreateOrderRequest.
+type ClaimWaitlistRequest struct {
+	PaymentMethod string `json:"payment_method"`
+}
+
+// WaitlistRestockEventMessage tells the waitlist consumer that released tickets
+// of a category were handed to buyers on its waitlist.
+type WaitlistRestockEventMessage struct {
+	EventId    int16 `json:"event_id"`
+	CategoryId int16 `json:"category_id"`
+	Quantity   int32 `json:"quantity"`
+}
@@ -0,0 +1,271 @@
+package payment
+
+import (
+	"bytes"
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"concert-ticket/model"
+	"encoding/json"
+	"fmt"
+	"github.com/oklog/ulid/v2"
+	"github.com/spf13/viper"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A settled charge takes the payment status reported in its callback.
+const (
+	ChargeStatusPending   = "pending"
+	ChargeStatusCancelled = "cancelled"
+
+	RefundStatusSucceeded = "succeeded"
+)
+
+// FakeGateway is an in-memory payment gateway for local development. It
+// issues virtual account numbers, QRIS and e-wallet codes and, on demand,
+// fires the payment callback the way a real provider would once the buyer
+// pays.
+type FakeGateway struct {
+	Cfg     *viper.Viper
+	TimeNow func() time.Time
+
+	mu             sync.Mutex
+	charges        map[string]*Charge
+	refunds        map[string]Refund
+	seq            int64
+	vaPrefixes     map[string]string
+	callbackUrl    string
+	callbackSecret []byte
+	client         *http.Client
+}
+
+func (fake *FakeGateway) Init() {
+	fake.charges = make(map[string]*Charge)
+	fake.refunds = make(map[string]Refund)
+	fake.vaPrefixes = fake.Cfg.GetStringMapString("fake_gateway.va_prefixes")
+	fake.callbackUrl = fake.Cfg.GetString("fake_gateway.callback_url")
+	fake.callbackSecret = []byte(fake.Cfg.GetString("payment.callback.secret"))
+	fake.client = &http.Client{Timeout: fake.Cfg.GetDuration("fake_gateway.callback_timeout")}
+}
+
+func (fake *FakeGateway) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /charges", fake.create)
+	mux.HandleFunc("GET /charges/{payment_code}", fake.get)
+	mux.HandleFunc("DELETE /charges/{payment_code}", fake.cancel)
+	mux.HandleFunc("POST /charges/{payment_code}/pay", fake.pay)
+	mux.HandleFunc("POST /refunds", fake.refund)
+}
+
+func (fake *FakeGateway) create(w http.ResponseWriter, r *http.Request) {
+	var req CreateChargeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ExternalId == "" || req.Amount <= 0 {
+		writeFakeResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	fake.mu.Lock()
+	fake.seq++
+	paymentCode, ok := fake.paymentCode(req.Method, fake.seq)
+	if !ok {
+		fake.mu.Unlock()
+		writeFakeResponse(w, http.StatusBadRequest, map[string]string{"error": "Unsupported payment method"})
+		return
+	}
+
+	charge := &Charge{
+		ExternalId:  req.ExternalId,
+		Method:      req.Method,
+		PaymentCode: paymentCode,
+		Amount:      req.Amount,
+		ExpiredAt:   req.ExpiredAt,
+		Customer:    req.Customer,
+		Status:      ChargeStatusPending,
+	}
+	fake.charges[charge.PaymentCode] = charge
+	fake.mu.Unlock()
+
+	slog.Info("fake gateway charge created", slog.String("payment_code", charge.PaymentCode), slog.String("external_id", charge.ExternalId))
+	writeFakeResponse(w, http.StatusCreated, charge)
+}
+
+// paymentCode formats the code the buyer pays with: a virtual account number
+// under the bank prefix, a QRIS payload reference or an e-wallet payment code.
+func (fake *FakeGateway) paymentCode(method string, seq int64) (string, bool) {
+	switch method {
+	case constant.PaymentMethodQris:
+		return fmt.Sprintf("QRIS%016d", seq), true
+	case constant.PaymentMethodEwallet:
+		return fmt.Sprintf("EW%014d", seq), true
+	}
+
+	prefix, ok := fake.vaPrefixes[method]
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s%012d", prefix, seq), true
+}
+
+func (fake *FakeGateway) get(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	charge, ok := fake.charges[r.PathValue("payment_code")]
+	var snapshot Charge
+	if ok {
+		snapshot = *charge
+	}
+	fake.mu.Unlock()
+
+	if !ok {
+		writeFakeResponse(w, http.StatusNotFound, map[string]string{"error": "Charge not found"})
+		return
+	}
+
+	writeFakeResponse(w, http.StatusOK, snapshot)
+}
+
+func (fake *FakeGateway) cancel(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	charge, ok := fake.charges[r.PathValue("payment_code")]
+	if ok && charge.Status == ChargeStatusPending {
+		charge.Status = ChargeStatusCancelled
+	}
+	fake.mu.Unlock()
+
+	if !ok {
+		writeFakeResponse(w, http.StatusNotFound, map[string]string{"error": "Charge not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pay settles the charge and delivers the callback to the ticket service, the
+// callback response status is passed through to the caller. The status and
+// amount query parameters override what is reported, to simulate failed,
+// expired or mismatched payments.
+func (fake *FakeGateway) pay(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	charge, ok := fake.charges[r.PathValue("payment_code")]
+	var snapshot Charge
+	if ok {
+		snapshot = *charge
+	}
+	fake.mu.Unlock()
+
+	if !ok {
+		writeFakeResponse(w, http.StatusNotFound, map[string]string{"error": "Charge not found"})
+		return
+	}
+
+	if snapshot.Status != ChargeStatusPending {
+		writeFakeResponse(w, http.StatusConflict, map[string]string{"error": "Charge is not pending"})
+		return
+	}
+
+	callback := model.PaymentCallbackRequest{
+		ExternalId:            snapshot.ExternalId,
+		ProviderTransactionId: ulid.Make().String(),
+		Status:                constant.PaymentStatusPaid,
+		Amount:                snapshot.Amount,
+		Currency:              constant.PaymentCurrencyIDR,
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		callback.Status = status
+	}
+
+	if amount := r.URL.Query().Get("amount"); amount != "" {
+		parsed, err := strconv.ParseInt(amount, 10, 64)
+		if err != nil {
+			writeFakeResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid amount"})
+			return
+		}
+		callback.Amount = parsed
+	}
+
+	if callback.Status == constant.PaymentStatusPaid && fake.TimeNow().After(snapshot.ExpiredAt) {
+		writeFakeResponse(w, http.StatusConflict, map[string]string{"error": "Charge expired"})
+		return
+	}
+
+	status, err := fake.sendCallback(r, callback)
+	if err != nil {
+		slog.Error("fake gateway callback failed", slog.String("payment_code", snapshot.PaymentCode), slog.Any("err", err))
+		writeFakeResponse(w, http.StatusBadGateway, map[string]string{"error": "Callback failed"})
+		return
+	}
+
+	if status == http.StatusOK {
+		fake.mu.Lock()
+		charge.Status = callback.Status
+		fake.mu.Unlock()
+	}
+
+	slog.Info("fake gateway callback sent", slog.String("payment_code", snapshot.PaymentCode), slog.Int("callback_status", status))
+	writeFakeResponse(w, http.StatusOK, map[string]any{
+		"callback_status":         status,
+		"provider_transaction_id": callback.ProviderTransactionId,
+	})
+}
+
+// refund always succeeds, a repeated request for the same provider transaction
+// returns the refund issued the first time.
+func (fake *FakeGateway) refund(w http.ResponseWriter, r *http.Request) {
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProviderTransactionId == "" || req.Amount <= 0 {
+		writeFakeResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	fake.mu.Lock()
+	refund, ok := fake.refunds[req.ProviderTransactionId]
+	if !ok {
+		refund = Refund{
+			RefundId:              ulid.Make().String(),
+			ProviderTransactionId: req.ProviderTransactionId,
+			Amount:                req.Amount,
+			Status:                RefundStatusSucceeded,
+		}
+		fake.refunds[req.ProviderTransactionId] = refund
+	}
+	fake.mu.Unlock()
+
+	slog.Info("fake gateway refund issued", slog.String("refund_id", refund.RefundId), slog.String("external_id", req.ExternalId))
+	writeFakeResponse(w, http.StatusCreated, refund)
+}
+
+func (fake *FakeGateway) sendCallback(r *http.Request, callback model.PaymentCallbackRequest) (int, error) {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, fake.callbackUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	callbackId := ulid.Make().String()
+	timestamp := strconv.FormatInt(fake.TimeNow().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constant.HeaderCallbackId, callbackId)
+	req.Header.Set(constant.HeaderCallbackTimestamp, timestamp)
+	req.Header.Set(constant.HeaderCallbackSignature, common.SignPaymentCallback(fake.callbackSecret, callbackId, timestamp, body))
+
+	resp, err := fake.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func writeFakeResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,106 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+)
+
+// HttpPaymentGateway talks to a payment gateway over its REST API, for local
+// development it points to the serve-fake-gateway server.
+type HttpPaymentGateway struct {
+	Cfg     *viper.Viper
+	client  *http.Client
+	baseUrl string
+}
+
+func (out *HttpPaymentGateway) Init() {
+	out.baseUrl = out.Cfg.GetString("payment.gateway.url")
+	out.client = &http.Client{Timeout: out.Cfg.GetDuration("payment.gateway.timeout")}
+}
+
+func (out *HttpPaymentGateway) CreateCharge(ctx context.Context, req CreateChargeRequest) (Charge, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Charge{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, out.baseUrl+"/charges", bytes.NewReader(body))
+	if err != nil {
+		return Charge{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := out.client.Do(httpReq)
+	if err != nil {
+		return Charge{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return Charge{}, fmt.Errorf("create charge: unexpected status %d", resp.StatusCode)
+	}
+
+	var charge Charge
+	if err := json.NewDecoder(resp.Body).Decode(&charge); err != nil {
+		return Charge{}, err
+	}
+
+	return charge, nil
+}
+
+func (out *HttpPaymentGateway) CancelCharge(ctx context.Context, paymentCode string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, out.baseUrl+"/charges/"+url.PathEscape(paymentCode), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := out.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ErrChargeNotFound
+	default:
+		return fmt.Errorf("cancel charge: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func (out *HttpPaymentGateway) Refund(ctx context.Context, req RefundRequest) (Refund, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, out.baseUrl+"/refunds", bytes.NewReader(body))
+	if err != nil {
+		return Refund{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := out.client.Do(httpReq)
+	if err != nil {
+		return Refund{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return Refund{}, fmt.Errorf("refund: unexpected status %d", resp.StatusCode)
+	}
+
+	var refund Refund
+	if err := json.NewDecoder(resp.Body).Decode(&refund); err != nil {
+		return Refund{}, err
+	}
+
+	return refund, nil
+}
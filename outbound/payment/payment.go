@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"concert-ticket/common"
+	"concert-ticket/common/constant"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrChargeNotFound = errors.New("charge not found")
+
+// PaymentGateway issues and voids the payment instruments buyers pay orders
+// with, and returns money for payments that can no longer be honoured.
+type PaymentGateway interface {
+	CreateCharge(ctx context.Context, req CreateChargeRequest) (Charge, error)
+	CancelCharge(ctx context.Context, paymentCode string) error
+	Refund(ctx context.Context, req RefundRequest) (Refund, error)
+}
+
+// CancelOpenCharge voids the charge of an order that is already cancelled. A
+// charge that stays open can still be paid, the payment then goes through the
+// late payment flow, which reinstates the order or marks it for refund, so a
+// failure is only logged. A charge the gateway no longer has is already closed.
+func CancelOpenCharge(ctx context.Context, gateway PaymentGateway, paymentCode string) {
+	err := gateway.CancelCharge(ctx, paymentCode)
+	if err != nil && err != ErrChargeNotFound {
+		slog.ErrorContext(ctx, "failed to cancel payment charge", common.ExtractTraceIDFromCtx(ctx), slog.String("payment_code", paymentCode), slog.Any(constant.LogFieldErr, err))
+	}
+}
+
+type Customer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type CreateChargeRequest struct {
+	ExternalId string    `json:"external_id"`
+	Method     string    `json:"method"`
+	Amount     int64     `json:"amount"`
+	ExpiredAt  time.Time `json:"expired_at"`
+	Customer   Customer  `json:"customer"`
+}
+
+type Charge struct {
+	ExternalId  string    `json:"external_id"`
+	Method      string    `json:"method"`
+	PaymentCode string    `json:"payment_code"`
+	Amount      int64     `json:"amount"`
+	ExpiredAt   time.Time `json:"expired_at"`
+	Customer    Customer  `json:"customer"`
+	Status      string    `json:"status"`
+}
+
+type RefundRequest struct {
+	ExternalId            string `json:"external_id"`
+	ProviderTransactionId string `json:"provider_transaction_id"`
+	Amount                int64  `json:"amount"`
+	Reason                string `json:"reason"`
+}
+
+type Refund struct {
+	RefundId              string `json:"refund_id"`
+	ProviderTransactionId string `json:"provider_transaction_id"`
+	Amount                int64  `json:"amount"`
+	Status                string `json:"status"`
+}
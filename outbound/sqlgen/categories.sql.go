@@ -7,63 +7,82 @@ package sqlgen
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const bulkIncrementCategoryQuantity = `-- name: BulkIncrementCategoryQuantity :exec
-UPDATE categories
-SET quantity = quantity + CASE
-                              WHEN id = 1 THEN $1::integer
-                              WHEN id = 2 THEN $2::integer
-                              WHEN id = 3 THEN $3::integer
-                              WHEN id = 4 THEN $4::integer
-                              WHEN id = 5 THEN $5::integer
-                              WHEN id = 6 THEN $6::integer
-                              WHEN id = 7 THEN $7::integer
-                              WHEN id = 8 THEN $8::integer
-                              WHEN id = 9 THEN $9::integer
-    END
-WHERE id IN (1, 2, 3, 4, 5, 6, 7, 8, 9)
+UPDATE categories c
+SET quantity = c.quantity + i.quantity
+FROM (SELECT UNNEST($1::smallint[]) AS id,
+             UNNEST($2::integer[]) AS quantity) i
+WHERE c.id = i.id
 `
 
 type BulkIncrementCategoryQuantityParams struct {
-	Column1 int32
-	Column2 int32
-	Column3 int32
-	Column4 int32
-	Column5 int32
-	Column6 int32
-	Column7 int32
-	Column8 int32
-	Column9 int32
+	Ids        []int16
+	Quantities []int32
 }
 
 func (q *Queries) BulkIncrementCategoryQuantity(ctx context.Context, arg BulkIncrementCategoryQuantityParams) error {
-	_, err := q.db.Exec(ctx, bulkIncrementCategoryQuantity,
-		arg.Column1,
-		arg.Column2,
-		arg.Column3,
-		arg.Column4,
-		arg.Column5,
-		arg.Column6,
-		arg.Column7,
-		arg.Column8,
-		arg.Column9,
-	)
+	_, err := q.db.Exec(ctx, bulkIncrementCategoryQuantity, arg.Ids, arg.Quantities)
 	return err
 }
 
-const findAllCategories = `-- name: FindAllCategories :many
-SELECT id, name, price, max_row, max_col, quantity
+const deleteCategoriesByEventId = `-- name: DeleteCategoriesByEventId :many
+DELETE
 FROM categories
+WHERE event_id = $1
+RETURNING id
+`
+
+func (q *Queries) DeleteCategoriesByEventId(ctx context.Context, eventID int16) ([]int16, error) {
+	rows, err := q.db.Query(ctx, deleteCategoriesByEventId, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int16
+	for rows.Next() {
+		var id int16
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAllCategories = `-- name: FindAllCategories :many
+SELECT c.id,
+       c.event_id,
+       c.name,
+       c.price,
+       c.max_row,
+       c.max_col,
+       c.quantity,
+       c.seating_type,
+       GREATEST(c.sale_starts_at, e.sale_starts_at)::timestamptz AS sale_starts_at,
+       LEAST(c.sale_ends_at, e.sale_ends_at)::timestamptz        AS sale_ends_at
+FROM categories c
+         JOIN events e ON e.id = c.event_id
+ORDER BY c.id
 `
 
 type FindAllCategoriesRow struct {
-	ID       int16
-	Name     string
-	Price    int32
-	MaxRow   int32
-	MaxCol   int32
-	Quantity int32
+	ID           int16
+	EventID      int16
+	Name         string
+	Price        int32
+	MaxRow       int32
+	MaxCol       int32
+	Quantity     int32
+	SeatingType  SeatingType
+	SaleStartsAt pgtype.Timestamptz
+	SaleEndsAt   pgtype.Timestamptz
 }
 
 func (q *Queries) FindAllCategories(ctx context.Context) ([]FindAllCategoriesRow, error) {
@@ -77,11 +96,99 @@ func (q *Queries) FindAllCategories(ctx context.Context) ([]FindAllCategoriesRow
 		var i FindAllCategoriesRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.EventID,
 			&i.Name,
 			&i.Price,
 			&i.MaxRow,
 			&i.MaxCol,
 			&i.Quantity,
+			&i.SeatingType,
+			&i.SaleStartsAt,
+			&i.SaleEndsAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findCategoriesOfOtherEvents = `-- name: FindCategoriesOfOtherEvents :many
+SELECT id, event_id
+FROM categories
+WHERE id = ANY ($1::smallint[])
+  AND event_id <> $2
+ORDER BY id
+`
+
+type FindCategoriesOfOtherEventsParams struct {
+	Ids     []int16
+	EventID int16
+}
+
+type FindCategoriesOfOtherEventsRow struct {
+	ID      int16
+	EventID int16
+}
+
+func (q *Queries) FindCategoriesOfOtherEvents(ctx context.Context, arg FindCategoriesOfOtherEventsParams) ([]FindCategoriesOfOtherEventsRow, error) {
+	rows, err := q.db.Query(ctx, findCategoriesOfOtherEvents, arg.Ids, arg.EventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindCategoriesOfOtherEventsRow
+	for rows.Next() {
+		var i FindCategoriesOfOtherEventsRow
+		if err := rows.Scan(&i.ID, &i.EventID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findCategoryInventory = `-- name: FindCategoryInventory :many
+SELECT c.id,
+       c.event_id,
+       c.quantity,
+       c.capacity,
+       COALESCE(SUM(o.quantity), 0)::integer AS reserved
+FROM categories c
+         LEFT JOIN orders o ON o.category_id = c.id AND o.status IN ('pending', 'completed')
+GROUP BY c.id
+ORDER BY c.id
+`
+
+type FindCategoryInventoryRow struct {
+	ID       int16
+	EventID  int16
+	Quantity int32
+	Capacity int32
+	Reserved int32
+}
+
+func (q *Queries) FindCategoryInventory(ctx context.Context) ([]FindCategoryInventoryRow, error) {
+	rows, err := q.db.Query(ctx, findCategoryInventory)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindCategoryInventoryRow
+	for rows.Next() {
+		var i FindCategoryInventoryRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.Quantity,
+			&i.Capacity,
+			&i.Reserved,
 		); err != nil {
 			return nil, err
 		}
@@ -92,3 +199,101 @@ func (q *Queries) FindAllCategories(ctx context.Context) ([]FindAllCategoriesRow
 	}
 	return items, nil
 }
+
+const findCategoryLayoutById = `-- name: FindCategoryLayoutById :one
+SELECT max_row, max_col
+FROM categories
+WHERE id = $1
+`
+
+type FindCategoryLayoutByIdRow struct {
+	MaxRow int32
+	MaxCol int32
+}
+
+func (q *Queries) FindCategoryLayoutById(ctx context.Context, id int16) (FindCategoryLayoutByIdRow, error) {
+	row := q.db.QueryRow(ctx, findCategoryLayoutById, id)
+	var i FindCategoryLayoutByIdRow
+	err := row.Scan(&i.MaxRow, &i.MaxCol)
+	return i, err
+}
+
+const insertCategories = `-- name: InsertCategories :exec
+INSERT INTO categories (id, event_id, name, price, quantity, capacity, max_row, max_col, seating_type, sale_starts_at,
+                        sale_ends_at)
+SELECT UNNEST($1::smallint[]),
+       $2::smallint,
+       UNNEST($3::varchar[]),
+       UNNEST($4::integer[]),
+       UNNEST($5::integer[]),
+       UNNEST($5::integer[]),
+       UNNEST($6::integer[]),
+       UNNEST($7::integer[]),
+       UNNEST($8::text[])::seating_type,
+       UNNEST($9::timestamptz[]),
+       UNNEST($10::timestamptz[])
+`
+
+type InsertCategoriesParams struct {
+	Ids           []int16
+	EventID       int16
+	Names         []string
+	Prices        []int32
+	Capacities    []int32
+	MaxRows       []int32
+	MaxCols       []int32
+	SeatingTypes  []string
+	SaleStartsAts []pgtype.Timestamptz
+	SaleEndsAts   []pgtype.Timestamptz
+}
+
+func (q *Queries) InsertCategories(ctx context.Context, arg InsertCategoriesParams) error {
+	_, err := q.db.Exec(ctx, insertCategories,
+		arg.Ids,
+		arg.EventID,
+		arg.Names,
+		arg.Prices,
+		arg.Capacities,
+		arg.MaxRows,
+		arg.MaxCols,
+		arg.SeatingTypes,
+		arg.SaleStartsAts,
+		arg.SaleEndsAts,
+	)
+	return err
+}
+
+const issueAdmissionNumbers = `-- name: IssueAdmissionNumbers :one
+UPDATE categories
+SET last_admission_number = last_admission_number + $1::integer
+WHERE id = $2
+RETURNING last_admission_number
+`
+
+type IssueAdmissionNumbersParams struct {
+	Quantity int32
+	ID       int16
+}
+
+func (q *Queries) IssueAdmissionNumbers(ctx context.Context, arg IssueAdmissionNumbersParams) (int32, error) {
+	row := q.db.QueryRow(ctx, issueAdmissionNumbers, arg.Quantity, arg.ID)
+	var last_admission_number int32
+	err := row.Scan(&last_admission_number)
+	return last_admission_number, err
+}
+
+const updateCategoryQuantity = `-- name: UpdateCategoryQuantity :exec
+UPDATE categories
+SET quantity = $2
+WHERE id = $1
+`
+
+type UpdateCategoryQuantityParams struct {
+	ID       int16
+	Quantity int32
+}
+
+func (q *Queries) UpdateCategoryQuantity(ctx context.Context, arg UpdateCategoryQuantityParams) error {
+	_, err := q.db.Exec(ctx, updateCategoryQuantity, arg.ID, arg.Quantity)
+	return err
+}
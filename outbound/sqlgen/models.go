@@ -14,9 +14,10 @@ import (
 type OrderStatus string
 
 const (
-	OrderStatusPending   OrderStatus = "pending"
-	OrderStatusCompleted OrderStatus = "completed"
-	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusPending        OrderStatus = "pending"
+	OrderStatusCompleted      OrderStatus = "completed"
+	OrderStatusCancelled      OrderStatus = "cancelled"
+	OrderStatusRefundRequired OrderStatus = "refund_required"
 )
 
 func (e *OrderStatus) Scan(src interface{}) error {
@@ -54,33 +55,219 @@ func (ns NullOrderStatus) Value() (driver.Value, error) {
 	return string(ns.OrderStatus), nil
 }
 
+type SeatStatus string
+
+const (
+	SeatStatusAvailable SeatStatus = "available"
+	SeatStatusHeld      SeatStatus = "held"
+	SeatStatusSold      SeatStatus = "sold"
+)
+
+func (e *SeatStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SeatStatus(s)
+	case string:
+		*e = SeatStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SeatStatus: %T", src)
+	}
+	return nil
+}
+
+type NullSeatStatus struct {
+	SeatStatus SeatStatus
+	Valid      bool // Valid is true if SeatStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSeatStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.SeatStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SeatStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSeatStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SeatStatus), nil
+}
+
+type SeatingType string
+
+const (
+	SeatingTypeReserved         SeatingType = "reserved"
+	SeatingTypeGeneralAdmission SeatingType = "general_admission"
+)
+
+func (e *SeatingType) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SeatingType(s)
+	case string:
+		*e = SeatingType(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SeatingType: %T", src)
+	}
+	return nil
+}
+
+type NullSeatingType struct {
+	SeatingType SeatingType
+	Valid       bool // Valid is true if SeatingType is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSeatingType) Scan(value interface{}) error {
+	if value == nil {
+		ns.SeatingType, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SeatingType.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSeatingType) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SeatingType), nil
+}
+
 type Category struct {
-	ID       int16
-	Name     string
-	Price    int32
-	Quantity int32
-	MaxRow   int32
-	MaxCol   int32
+	ID                  int16
+	EventID             int16
+	Name                string
+	Price               int32
+	Quantity            int32
+	Capacity            int32
+	MaxRow              int32
+	MaxCol              int32
+	SeatingType         SeatingType
+	SaleStartsAt        pgtype.Timestamptz
+	SaleEndsAt          pgtype.Timestamptz
+	LastAdmissionNumber int32
+}
+
+type Event struct {
+	ID           int16
+	VenueID      int16
+	Name         string
+	Timezone     string
+	StartsAt     pgtype.Timestamptz
+	SaleStartsAt pgtype.Timestamptz
+	SaleEndsAt   pgtype.Timestamptz
+	OrderPrefix  string
+	BrandName    string
+	SupportEmail string
+}
+
+type LatePayment struct {
+	ID                    int32
+	OrderID               int32
+	ExternalID            string
+	ProviderTransactionID string
+	Amount                int64
+	Outcome               string
+	RefundID              pgtype.Text
+	RefundedAt            pgtype.Timestamp
+	CreatedAt             pgtype.Timestamp
 }
 
-type CategoryQuantity struct {
+type Order struct {
+	ID              int32
+	EventID         int16
+	CategoryID      int16
+	Quantity        int32
+	ExternalID      string
+	Name            string
+	Email           string
+	Status          NullOrderStatus
+	PaymentCode     string
+	PaymentMethod   string
+	BasePrice       int64
+	PlatformFee     int64
+	VatAmount       int64
+	TotalAmount     int64
+	CancelTokenHash string
+	ExpiredAt       pgtype.Timestamp
+	TicketRow       pgtype.Int4
+	TicketCol       pgtype.Int4
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+}
+
+type OrderItem struct {
+	ID              int32
+	OrderID         int32
+	TicketRow       pgtype.Int4
+	TicketCol       pgtype.Int4
+	AdmissionNumber pgtype.Int4
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+}
+
+type Outbox struct {
+	ID        int64
+	Subject   string
+	Payload   []byte
+	Attempts  int32
+	LastError pgtype.Text
+	CreatedAt pgtype.Timestamp
+	SentAt    pgtype.Timestamp
+}
+
+type PaymentCallback struct {
+	ID                    int64
+	ProviderTransactionID string
+	ExternalID            string
+	CallbackID            string
+	Status                string
+	Payload               string
+	Headers               []byte
+	Outcome               string
+	ReceivedAt            pgtype.Timestamp
+	UpdatedAt             pgtype.Timestamp
+}
+
+type PaymentException struct {
+	ID                    int32
+	OrderID               int32
+	ExternalID            string
+	ProviderTransactionID string
+	Reason                string
+	ExpectedAmount        int64
+	PaidAmount            int64
+	Currency              string
+	Payload               []byte
+	CreatedAt             pgtype.Timestamp
+}
+
+type Seat struct {
 	ID         int32
 	CategoryID int16
 	Row        int32
 	Col        int32
+	Section    string
+	RowLabel   string
+	SeatLabel  string
+	X          float32
+	Y          float32
+	Status     SeatStatus
+	OrderID    pgtype.Int4
+	HoldID     pgtype.Text
+	HeldUntil  pgtype.Timestamp
+	UpdatedAt  pgtype.Timestamp
 }
 
-type Order struct {
-	ID          int32
-	CategoryID  int16
-	ExternalID  string
-	Name        string
-	Email       string
-	Status      NullOrderStatus
-	PaymentCode string
-	ExpiredAt   pgtype.Timestamp
-	TicketRow   pgtype.Int4
-	TicketCol   pgtype.Int4
-	CreatedAt   pgtype.Timestamp
-	UpdatedAt   pgtype.Timestamp
+type Venue struct {
+	ID   int16
+	Name string
+	City string
 }
@@ -20,8 +20,9 @@ WHERE id IN (SELECT id
              FROM orders
              WHERE status = 'pending'
                AND expired_at < $2
+             ORDER BY expired_at
              LIMIT $1)
-RETURNING id, category_id, name, email
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount
 `
 
 type BulkCancelOrdersParams struct {
@@ -30,10 +31,14 @@ type BulkCancelOrdersParams struct {
 }
 
 type BulkCancelOrdersRow struct {
-	ID         int32
-	CategoryID int16
-	Name       string
-	Email      string
+	ID          int32
+	EventID     int16
+	CategoryID  int16
+	Quantity    int32
+	Name        string
+	Email       string
+	PaymentCode string
+	TotalAmount int64
 }
 
 func (q *Queries) BulkCancelOrders(ctx context.Context, arg BulkCancelOrdersParams) ([]BulkCancelOrdersRow, error) {
@@ -47,9 +52,147 @@ func (q *Queries) BulkCancelOrders(ctx context.Context, arg BulkCancelOrdersPara
 		var i BulkCancelOrdersRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.EventID,
 			&i.CategoryID,
+			&i.Quantity,
 			&i.Name,
 			&i.Email,
+			&i.PaymentCode,
+			&i.TotalAmount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const cancelOrderByExternalIdAndCancelToken = `-- name: CancelOrderByExternalIdAndCancelToken :one
+UPDATE orders
+SET status     = 'cancelled',
+    updated_at = $3
+WHERE external_id = $1
+  AND cancel_token_hash = $2
+  AND status = 'pending'
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount
+`
+
+type CancelOrderByExternalIdAndCancelTokenParams struct {
+	ExternalID      string
+	CancelTokenHash string
+	UpdatedAt       pgtype.Timestamp
+}
+
+type CancelOrderByExternalIdAndCancelTokenRow struct {
+	ID          int32
+	EventID     int16
+	CategoryID  int16
+	Quantity    int32
+	Name        string
+	Email       string
+	PaymentCode string
+	TotalAmount int64
+}
+
+func (q *Queries) CancelOrderByExternalIdAndCancelToken(ctx context.Context, arg CancelOrderByExternalIdAndCancelTokenParams) (CancelOrderByExternalIdAndCancelTokenRow, error) {
+	row := q.db.QueryRow(ctx, cancelOrderByExternalIdAndCancelToken, arg.ExternalID, arg.CancelTokenHash, arg.UpdatedAt)
+	var i CancelOrderByExternalIdAndCancelTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.CategoryID,
+		&i.Quantity,
+		&i.Name,
+		&i.Email,
+		&i.PaymentCode,
+		&i.TotalAmount,
+	)
+	return i, err
+}
+
+const cancelOrderByIdAndStatusPending = `-- name: CancelOrderByIdAndStatusPending :one
+UPDATE orders
+SET status     = 'cancelled',
+    updated_at = $2
+WHERE id = $1
+  AND status = 'pending'
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount
+`
+
+type CancelOrderByIdAndStatusPendingParams struct {
+	ID        int32
+	UpdatedAt pgtype.Timestamp
+}
+
+type CancelOrderByIdAndStatusPendingRow struct {
+	ID          int32
+	EventID     int16
+	CategoryID  int16
+	Quantity    int32
+	Name        string
+	Email       string
+	PaymentCode string
+	TotalAmount int64
+}
+
+func (q *Queries) CancelOrderByIdAndStatusPending(ctx context.Context, arg CancelOrderByIdAndStatusPendingParams) (CancelOrderByIdAndStatusPendingRow, error) {
+	row := q.db.QueryRow(ctx, cancelOrderByIdAndStatusPending, arg.ID, arg.UpdatedAt)
+	var i CancelOrderByIdAndStatusPendingRow
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.CategoryID,
+		&i.Quantity,
+		&i.Name,
+		&i.Email,
+		&i.PaymentCode,
+		&i.TotalAmount,
+	)
+	return i, err
+}
+
+const findCompletedOrdersUpdatedBetween = `-- name: FindCompletedOrdersUpdatedBetween :many
+SELECT id, category_id, quantity, external_id, payment_code, total_amount
+FROM orders
+WHERE status = 'completed'
+  AND updated_at >= $1
+  AND updated_at < $2
+ORDER BY id
+`
+
+type FindCompletedOrdersUpdatedBetweenParams struct {
+	UpdatedFrom pgtype.Timestamp
+	UpdatedTo   pgtype.Timestamp
+}
+
+type FindCompletedOrdersUpdatedBetweenRow struct {
+	ID          int32
+	CategoryID  int16
+	Quantity    int32
+	ExternalID  string
+	PaymentCode string
+	TotalAmount int64
+}
+
+func (q *Queries) FindCompletedOrdersUpdatedBetween(ctx context.Context, arg FindCompletedOrdersUpdatedBetweenParams) ([]FindCompletedOrdersUpdatedBetweenRow, error) {
+	rows, err := q.db.Query(ctx, findCompletedOrdersUpdatedBetween, arg.UpdatedFrom, arg.UpdatedTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindCompletedOrdersUpdatedBetweenRow
+	for rows.Next() {
+		var i FindCompletedOrdersUpdatedBetweenRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CategoryID,
+			&i.Quantity,
+			&i.ExternalID,
+			&i.PaymentCode,
+			&i.TotalAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -64,25 +207,166 @@ func (q *Queries) BulkCancelOrders(ctx context.Context, arg BulkCancelOrdersPara
 const findOrderByEmailAndStatusPending = `-- name: FindOrderByEmailAndStatusPending :one
 SELECT EXISTS (SELECT 1
                FROM orders
-               WHERE email = $1
+               WHERE event_id = $1
+                 AND email = $2
                  AND status = 'pending') AS "exists"
 `
 
-func (q *Queries) FindOrderByEmailAndStatusPending(ctx context.Context, email string) (bool, error) {
-	row := q.db.QueryRow(ctx, findOrderByEmailAndStatusPending, email)
+type FindOrderByEmailAndStatusPendingParams struct {
+	EventID int16
+	Email   string
+}
+
+func (q *Queries) FindOrderByEmailAndStatusPending(ctx context.Context, arg FindOrderByEmailAndStatusPendingParams) (bool, error) {
+	row := q.db.QueryRow(ctx, findOrderByEmailAndStatusPending, arg.EventID, arg.Email)
 	var exists bool
 	err := row.Scan(&exists)
 	return exists, err
 }
 
+const findOrderByEventId = `-- name: FindOrderByEventId :one
+SELECT EXISTS (SELECT 1
+               FROM orders
+               WHERE event_id = $1) AS "exists"
+`
+
+func (q *Queries) FindOrderByEventId(ctx context.Context, eventID int16) (bool, error) {
+	row := q.db.QueryRow(ctx, findOrderByEventId, eventID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const findOrderByExternalId = `-- name: FindOrderByExternalId :one
+SELECT id,
+       event_id,
+       category_id,
+       quantity,
+       external_id,
+       status,
+       payment_code,
+       payment_method,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount,
+       expired_at,
+       ticket_row,
+       ticket_col,
+       created_at,
+       updated_at
+FROM orders
+WHERE external_id = $1
+`
+
+type FindOrderByExternalIdRow struct {
+	ID            int32
+	EventID       int16
+	CategoryID    int16
+	Quantity      int32
+	ExternalID    string
+	Status        NullOrderStatus
+	PaymentCode   string
+	PaymentMethod string
+	BasePrice     int64
+	PlatformFee   int64
+	VatAmount     int64
+	TotalAmount   int64
+	ExpiredAt     pgtype.Timestamp
+	TicketRow     pgtype.Int4
+	TicketCol     pgtype.Int4
+	CreatedAt     pgtype.Timestamp
+	UpdatedAt     pgtype.Timestamp
+}
+
+func (q *Queries) FindOrderByExternalId(ctx context.Context, externalID string) (FindOrderByExternalIdRow, error) {
+	row := q.db.QueryRow(ctx, findOrderByExternalId, externalID)
+	var i FindOrderByExternalIdRow
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.CategoryID,
+		&i.Quantity,
+		&i.ExternalID,
+		&i.Status,
+		&i.PaymentCode,
+		&i.PaymentMethod,
+		&i.BasePrice,
+		&i.PlatformFee,
+		&i.VatAmount,
+		&i.TotalAmount,
+		&i.ExpiredAt,
+		&i.TicketRow,
+		&i.TicketCol,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const findOrderByExternalIdAndStatusCancelled = `-- name: FindOrderByExternalIdAndStatusCancelled :one
+SELECT id,
+       event_id,
+       category_id,
+       quantity,
+       external_id,
+       name,
+       email,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount
+FROM orders
+WHERE external_id = $1
+  AND status = 'cancelled'
+`
+
+type FindOrderByExternalIdAndStatusCancelledRow struct {
+	ID          int32
+	EventID     int16
+	CategoryID  int16
+	Quantity    int32
+	ExternalID  string
+	Name        string
+	Email       string
+	BasePrice   int64
+	PlatformFee int64
+	VatAmount   int64
+	TotalAmount int64
+}
+
+func (q *Queries) FindOrderByExternalIdAndStatusCancelled(ctx context.Context, externalID string) (FindOrderByExternalIdAndStatusCancelledRow, error) {
+	row := q.db.QueryRow(ctx, findOrderByExternalIdAndStatusCancelled, externalID)
+	var i FindOrderByExternalIdAndStatusCancelledRow
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.CategoryID,
+		&i.Quantity,
+		&i.ExternalID,
+		&i.Name,
+		&i.Email,
+		&i.BasePrice,
+		&i.PlatformFee,
+		&i.VatAmount,
+		&i.TotalAmount,
+	)
+	return i, err
+}
+
 const findOrderByExternalIdAndStatusPending = `-- name: FindOrderByExternalIdAndStatusPending :one
 SELECT id,
        category_id,
+       quantity,
        external_id,
        name,
        email,
        payment_code,
-       expired_at
+       expired_at,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount
 FROM orders
 WHERE external_id = $1
   AND status = 'pending'
@@ -91,11 +375,16 @@ WHERE external_id = $1
 type FindOrderByExternalIdAndStatusPendingRow struct {
 	ID          int32
 	CategoryID  int16
+	Quantity    int32
 	ExternalID  string
 	Name        string
 	Email       string
 	PaymentCode string
 	ExpiredAt   pgtype.Timestamp
+	BasePrice   int64
+	PlatformFee int64
+	VatAmount   int64
+	TotalAmount int64
 }
 
 func (q *Queries) FindOrderByExternalIdAndStatusPending(ctx context.Context, externalID string) (FindOrderByExternalIdAndStatusPendingRow, error) {
@@ -104,44 +393,176 @@ func (q *Queries) FindOrderByExternalIdAndStatusPending(ctx context.Context, ext
 	err := row.Scan(
 		&i.ID,
 		&i.CategoryID,
+		&i.Quantity,
 		&i.ExternalID,
 		&i.Name,
 		&i.Email,
 		&i.PaymentCode,
 		&i.ExpiredAt,
+		&i.BasePrice,
+		&i.PlatformFee,
+		&i.VatAmount,
+		&i.TotalAmount,
 	)
 	return i, err
 }
 
-const insertOrder = `-- name: InsertOrder :one
-INSERT INTO orders(category_id, external_id, name, email, payment_code, expired_at)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id
+const findOrderStatusByExternalId = `-- name: FindOrderStatusByExternalId :one
+SELECT status
+FROM orders
+WHERE external_id = $1
 `
 
-type InsertOrderParams struct {
+func (q *Queries) FindOrderStatusByExternalId(ctx context.Context, externalID string) (NullOrderStatus, error) {
+	row := q.db.QueryRow(ctx, findOrderStatusByExternalId, externalID)
+	var status NullOrderStatus
+	err := row.Scan(&status)
+	return status, err
+}
+
+const findOrderStatusByExternalIdAndCancelToken = `-- name: FindOrderStatusByExternalIdAndCancelToken :one
+SELECT status
+FROM orders
+WHERE external_id = $1
+  AND cancel_token_hash = $2
+`
+
+type FindOrderStatusByExternalIdAndCancelTokenParams struct {
+	ExternalID      string
+	CancelTokenHash string
+}
+
+func (q *Queries) FindOrderStatusByExternalIdAndCancelToken(ctx context.Context, arg FindOrderStatusByExternalIdAndCancelTokenParams) (NullOrderStatus, error) {
+	row := q.db.QueryRow(ctx, findOrderStatusByExternalIdAndCancelToken, arg.ExternalID, arg.CancelTokenHash)
+	var status NullOrderStatus
+	err := row.Scan(&status)
+	return status, err
+}
+
+const findOrdersByPaymentCodesOrExternalIds = `-- name: FindOrdersByPaymentCodesOrExternalIds :many
+SELECT id, category_id, quantity, external_id, status, payment_code, total_amount
+FROM orders
+WHERE payment_code = ANY ($1::varchar[])
+   OR external_id = ANY ($2::varchar[])
+`
+
+type FindOrdersByPaymentCodesOrExternalIdsParams struct {
+	PaymentCodes []string
+	ExternalIds  []string
+}
+
+type FindOrdersByPaymentCodesOrExternalIdsRow struct {
+	ID          int32
 	CategoryID  int16
+	Quantity    int32
 	ExternalID  string
-	Name        string
-	Email       string
+	Status      NullOrderStatus
 	PaymentCode string
-	ExpiredAt   pgtype.Timestamp
+	TotalAmount int64
+}
+
+func (q *Queries) FindOrdersByPaymentCodesOrExternalIds(ctx context.Context, arg FindOrdersByPaymentCodesOrExternalIdsParams) ([]FindOrdersByPaymentCodesOrExternalIdsRow, error) {
+	rows, err := q.db.Query(ctx, findOrdersByPaymentCodesOrExternalIds, arg.PaymentCodes, arg.ExternalIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindOrdersByPaymentCodesOrExternalIdsRow
+	for rows.Next() {
+		var i FindOrdersByPaymentCodesOrExternalIdsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CategoryID,
+			&i.Quantity,
+			&i.ExternalID,
+			&i.Status,
+			&i.PaymentCode,
+			&i.TotalAmount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertOrder = `-- name: InsertOrder :one
+WITH inserted_order AS (
+    INSERT INTO orders (event_id, category_id, quantity, external_id, name, email, payment_code, payment_method,
+                        cancel_token_hash, expired_at, base_price, platform_fee, vat_amount, total_amount)
+        VALUES ((SELECT id FROM events WHERE id = $1 FOR KEY SHARE), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+                $13, $14)
+        RETURNING id),
+     inserted_items AS (
+         INSERT INTO order_items (order_id)
+             SELECT inserted_order.id
+             FROM inserted_order,
+                  GENERATE_SERIES(1, $3::integer)
+             RETURNING order_id)
+SELECT id
+FROM inserted_order
+`
+
+type InsertOrderParams struct {
+	EventID         int16
+	CategoryID      int16
+	Quantity        int32
+	ExternalID      string
+	Name            string
+	Email           string
+	PaymentCode     string
+	PaymentMethod   string
+	CancelTokenHash string
+	ExpiredAt       pgtype.Timestamp
+	BasePrice       int64
+	PlatformFee     int64
+	VatAmount       int64
+	TotalAmount     int64
 }
 
 func (q *Queries) InsertOrder(ctx context.Context, arg InsertOrderParams) (int32, error) {
 	row := q.db.QueryRow(ctx, insertOrder,
+		arg.EventID,
 		arg.CategoryID,
+		arg.Quantity,
 		arg.ExternalID,
 		arg.Name,
 		arg.Email,
 		arg.PaymentCode,
+		arg.PaymentMethod,
+		arg.CancelTokenHash,
 		arg.ExpiredAt,
+		arg.BasePrice,
+		arg.PlatformFee,
+		arg.VatAmount,
+		arg.TotalAmount,
 	)
 	var id int32
 	err := row.Scan(&id)
 	return id, err
 }
 
+const updateOrderStatusFromCancelled = `-- name: UpdateOrderStatusFromCancelled :execresult
+UPDATE orders
+SET status     = $1,
+    updated_at = $2
+WHERE id = $3
+  AND status = 'cancelled'
+`
+
+type UpdateOrderStatusFromCancelledParams struct {
+	Status    NullOrderStatus
+	UpdatedAt pgtype.Timestamp
+	ID        int32
+}
+
+func (q *Queries) UpdateOrderStatusFromCancelled(ctx context.Context, arg UpdateOrderStatusFromCancelledParams) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, updateOrderStatusFromCancelled, arg.Status, arg.UpdatedAt, arg.ID)
+}
+
 const updateOrderStatusToCompleted = `-- name: UpdateOrderStatusToCompleted :execresult
 UPDATE orders
 SET status     = 'completed',
@@ -1,18 +1,76 @@
 -- name: FindAllCategories :many
-SELECT id, name, price, max_row, max_col, quantity
-FROM categories;
+SELECT c.id,
+       c.event_id,
+       c.name,
+       c.price,
+       c.max_row,
+       c.max_col,
+       c.quantity,
+       c.seating_type,
+       GREATEST(c.sale_starts_at, e.sale_starts_at)::timestamptz AS sale_starts_at,
+       LEAST(c.sale_ends_at, e.sale_ends_at)::timestamptz        AS sale_ends_at
+FROM categories c
+         JOIN events e ON e.id = c.event_id
+ORDER BY c.id;
 
 -- name: BulkIncrementCategoryQuantity :exec
+UPDATE categories c
+SET quantity = c.quantity + i.quantity
+FROM (SELECT UNNEST(sqlc.arg(ids)::smallint[]) AS id,
+             UNNEST(sqlc.arg(quantities)::integer[]) AS quantity) i
+WHERE c.id = i.id;
+
+-- name: FindCategoriesOfOtherEvents :many
+SELECT id, event_id
+FROM categories
+WHERE id = ANY (sqlc.arg(ids)::smallint[])
+  AND event_id <> sqlc.arg(event_id)
+ORDER BY id;
+
+-- name: FindCategoryInventory :many
+SELECT c.id,
+       c.event_id,
+       c.quantity,
+       c.capacity,
+       COALESCE(SUM(o.quantity), 0)::integer AS reserved
+FROM categories c
+         LEFT JOIN orders o ON o.category_id = c.id AND o.status IN ('pending', 'completed')
+GROUP BY c.id
+ORDER BY c.id;
+
+-- name: FindCategoryLayoutById :one
+SELECT max_row, max_col
+FROM categories
+WHERE id = $1;
+
+-- name: IssueAdmissionNumbers :one
 UPDATE categories
-SET quantity = quantity + CASE
-                              WHEN id = 1 THEN $1::integer
-                              WHEN id = 2 THEN $2::integer
-                              WHEN id = 3 THEN $3::integer
-                              WHEN id = 4 THEN $4::integer
-                              WHEN id = 5 THEN $5::integer
-                              WHEN id = 6 THEN $6::integer
-                              WHEN id = 7 THEN $7::integer
-                              WHEN id = 8 THEN $8::integer
-                              WHEN id = 9 THEN $9::integer
-    END
-WHERE id IN (1, 2, 3, 4, 5, 6, 7, 8, 9);
\ No newline at end of file
+SET last_admission_number = last_admission_number + sqlc.arg(quantity)::integer
+WHERE id = sqlc.arg(id)
+RETURNING last_admission_number;
+
+-- name: UpdateCategoryQuantity :exec
+UPDATE categories
+SET quantity = $2
+WHERE id = $1;
+
+-- name: DeleteCategoriesByEventId :many
+DELETE
+FROM categories
+WHERE event_id = $1
+RETURNING id;
+
+-- name: InsertCategories :exec
+INSERT INTO categories (id, event_id, name, price, quantity, capacity, max_row, max_col, seating_type, sale_starts_at,
+                        sale_ends_at)
+SELECT UNNEST(sqlc.arg(ids)::smallint[]),
+       sqlc.arg(event_id)::smallint,
+       UNNEST(sqlc.arg(names)::varchar[]),
+       UNNEST(sqlc.arg(prices)::integer[]),
+       UNNEST(sqlc.arg(capacities)::integer[]),
+       UNNEST(sqlc.arg(capacities)::integer[]),
+       UNNEST(sqlc.arg(max_rows)::integer[]),
+       UNNEST(sqlc.arg(max_cols)::integer[]),
+       UNNEST(sqlc.arg(seating_types)::text[])::seating_type,
+       UNNEST(sqlc.arg(sale_starts_ats)::timestamptz[]),
+       UNNEST(sqlc.arg(sale_ends_ats)::timestamptz[]);
\ No newline at end of file
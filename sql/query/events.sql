@@ -0,0 +1,20 @@
+-- name: FindAllEvents :many
+SELECT e.id,
+       e.name,
+       e.timezone,
+       e.starts_at,
+       e.sale_starts_at,
+       e.sale_ends_at,
+       e.order_prefix,
+       e.brand_name,
+       e.support_email,
+       v.name AS venue_name,
+       v.city AS venue_city
+FROM events e
+         JOIN venues v ON v.id = e.venue_id
+ORDER BY e.id;
+
+-- name: FindEventSaleWindowForUpdate :one
+SELECT sale_starts_at, sale_ends_at
+FROM events
+WHERE id = $1 FOR UPDATE;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- name: InsertLatePayment :exec
+INSERT INTO late_payments (order_id, external_id, provider_transaction_id, amount, outcome)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: UpdateLatePaymentRefunded :exec
+UPDATE late_payments
+SET refund_id   = $2,
+    refunded_at = $3
+WHERE provider_transaction_id = $1;
\ No newline at end of file
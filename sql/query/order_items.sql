@@ -0,0 +1,28 @@
+-- name: FindOrderItemsByOrderId :many
+SELECT ticket_row, ticket_col, admission_number
+FROM order_items
+WHERE order_id = $1
+ORDER BY id;
+
+-- name: UpdateOrderItemAdmissionNumber :execresult
+UPDATE order_items
+SET admission_number = $1,
+    updated_at       = NOW()
+WHERE id = (SELECT id
+            FROM order_items
+            WHERE order_id = $2
+              AND admission_number IS NULL
+            ORDER BY id
+            LIMIT 1);
+
+-- name: UpdateOrderItemTicketRowCol :execresult
+UPDATE order_items
+SET ticket_row = $1,
+    ticket_col = $2,
+    updated_at = NOW()
+WHERE id = (SELECT id
+            FROM order_items
+            WHERE order_id = $3
+              AND ticket_row IS NULL
+            ORDER BY id
+            LIMIT 1);
\ No newline at end of file
@@ -1,22 +1,60 @@
 -- name: InsertOrder :one
-INSERT INTO orders(category_id, external_id, name, email, payment_code, expired_at)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id;
+WITH inserted_order AS (
+    INSERT INTO orders (event_id, category_id, quantity, external_id, name, email, payment_code, payment_method,
+                        cancel_token_hash, expired_at, base_price, platform_fee, vat_amount, total_amount)
+        VALUES ((SELECT id FROM events WHERE id = $1 FOR KEY SHARE), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+                $13, $14)
+        RETURNING id),
+     inserted_items AS (
+         INSERT INTO order_items (order_id)
+             SELECT inserted_order.id
+             FROM inserted_order,
+                  GENERATE_SERIES(1, $3::integer)
+             RETURNING order_id)
+SELECT id
+FROM inserted_order;
 
 -- name: FindOrderByEmailAndStatusPending :one
 SELECT EXISTS (SELECT 1
                FROM orders
-               WHERE email = $1
+               WHERE event_id = $1
+                 AND email = $2
                  AND status = 'pending') AS "exists";
 
+-- name: FindOrderByExternalId :one
+SELECT id,
+       event_id,
+       category_id,
+       quantity,
+       external_id,
+       status,
+       payment_code,
+       payment_method,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount,
+       expired_at,
+       ticket_row,
+       ticket_col,
+       created_at,
+       updated_at
+FROM orders
+WHERE external_id = $1;
+
 -- name: FindOrderByExternalIdAndStatusPending :one
 SELECT id,
        category_id,
+       quantity,
        external_id,
        name,
        email,
        payment_code,
-       expired_at
+       expired_at,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount
 FROM orders
 WHERE external_id = $1
   AND status = 'pending';
@@ -43,5 +81,76 @@ WHERE id IN (SELECT id
              FROM orders
              WHERE status = 'pending'
                AND expired_at < $2
+             ORDER BY expired_at
              LIMIT $1)
-RETURNING id, category_id, name, email;
\ No newline at end of file
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount;
+
+-- name: CancelOrderByExternalIdAndCancelToken :one
+UPDATE orders
+SET status     = 'cancelled',
+    updated_at = $3
+WHERE external_id = $1
+  AND cancel_token_hash = $2
+  AND status = 'pending'
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount;
+
+-- name: FindOrderStatusByExternalId :one
+SELECT status
+FROM orders
+WHERE external_id = $1;
+
+-- name: FindOrderStatusByExternalIdAndCancelToken :one
+SELECT status
+FROM orders
+WHERE external_id = $1
+  AND cancel_token_hash = $2;
+
+-- name: CancelOrderByIdAndStatusPending :one
+UPDATE orders
+SET status     = 'cancelled',
+    updated_at = $2
+WHERE id = $1
+  AND status = 'pending'
+RETURNING id, event_id, category_id, quantity, name, email, payment_code, total_amount;
+
+-- name: FindOrderByExternalIdAndStatusCancelled :one
+SELECT id,
+       event_id,
+       category_id,
+       quantity,
+       external_id,
+       name,
+       email,
+       base_price,
+       platform_fee,
+       vat_amount,
+       total_amount
+FROM orders
+WHERE external_id = $1
+  AND status = 'cancelled';
+
+-- name: UpdateOrderStatusFromCancelled :execresult
+UPDATE orders
+SET status     = sqlc.arg(status),
+    updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+  AND status = 'cancelled';
+
+-- name: FindOrdersByPaymentCodesOrExternalIds :many
+SELECT id, category_id, quantity, external_id, status, payment_code, total_amount
+FROM orders
+WHERE payment_code = ANY (sqlc.arg(payment_codes)::varchar[])
+   OR external_id = ANY (sqlc.arg(external_ids)::varchar[]);
+
+-- name: FindCompletedOrdersUpdatedBetween :many
+SELECT id, category_id, quantity, external_id, payment_code, total_amount
+FROM orders
+WHERE status = 'completed'
+  AND updated_at >= sqlc.arg(updated_from)
+  AND updated_at < sqlc.arg(updated_to)
+ORDER BY id;
+
+-- name: FindOrderByEventId :one
+SELECT EXISTS (SELECT 1
+               FROM orders
+               WHERE event_id = $1) AS "exists";
\ No newline at end of file
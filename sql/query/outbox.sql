@@ -0,0 +1,21 @@
+-- name: InsertOutbox :exec
+INSERT INTO outbox (subject, payload)
+VALUES ($1, $2);
+
+-- name: FindPendingOutboxForUpdate :many
+SELECT id, subject, payload
+FROM outbox
+WHERE sent_at IS NULL
+ORDER BY id
+LIMIT $1 FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateOutboxSent :exec
+UPDATE outbox
+SET sent_at = sqlc.arg(sent_at)
+WHERE id = ANY (sqlc.arg(ids)::bigint[]);
+
+-- name: UpdateOutboxFailed :exec
+UPDATE outbox
+SET attempts   = attempts + 1,
+    last_error = $2
+WHERE id = $1;
\ No newline at end of file
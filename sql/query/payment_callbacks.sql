@@ -0,0 +1,39 @@
+-- name: InsertPaymentCallback :one
+INSERT INTO payment_callbacks (provider_transaction_id, external_id, callback_id, status, payload, headers, outcome,
+                               received_at)
+VALUES ($1, $2, $3, $4, $5, $6,
+        CASE
+            WHEN sqlc.arg(outcome)::varchar = 'received' AND EXISTS (SELECT 1
+                                                                      FROM payment_callbacks p
+                                                                      WHERE p.provider_transaction_id = $1
+                                                                        AND p.callback_id <> $3
+                                                                        AND p.outcome IN ('received', 'queued'))
+                THEN 'duplicate'
+            ELSE sqlc.arg(outcome)::varchar
+            END, $7)
+ON CONFLICT (callback_id) DO UPDATE
+    SET outcome    = EXCLUDED.outcome,
+        updated_at = NULL
+WHERE payment_callbacks.outcome = 'publish_failed'
+RETURNING outcome;
+
+-- name: UpdatePaymentCallbackOutcome :exec
+UPDATE payment_callbacks
+SET outcome    = $1,
+    updated_at = $2
+WHERE callback_id = $3;
+
+-- name: FindPaymentCallbacksByExternalId :many
+SELECT id,
+       provider_transaction_id,
+       external_id,
+       callback_id,
+       status,
+       payload,
+       headers,
+       outcome,
+       received_at,
+       updated_at
+FROM payment_callbacks
+WHERE external_id = $1
+ORDER BY received_at, id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- name: InsertPaymentException :exec
+INSERT INTO payment_exceptions (order_id, external_id, provider_transaction_id, reason, expected_amount, paid_amount,
+                                currency, payload)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (provider_transaction_id) DO NOTHING;
\ No newline at end of file
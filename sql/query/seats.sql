@@ -0,0 +1,83 @@
+-- name: ClaimSeats :many
+UPDATE seats
+SET status     = 'sold',
+    order_id   = sqlc.arg(order_id)::integer,
+    updated_at = NOW()
+WHERE id IN (SELECT id
+             FROM seats
+             WHERE category_id = sqlc.arg(category_id)
+               AND status = 'available'
+             ORDER BY sqlc.arg(row_weight)::integer * row,
+                      sqlc.arg(row_center_weight)::integer * (2 * row - sqlc.arg(max_row)::integer - 1) * (2 * row - sqlc.arg(max_row)::integer - 1) +
+                      sqlc.arg(col_center_weight)::integer * (2 * col - sqlc.arg(max_col)::integer - 1) * (2 * col - sqlc.arg(max_col)::integer - 1),
+                      CASE WHEN sqlc.arg(shuffle)::boolean THEN RANDOM() ELSE 0 END,
+                      row, col
+             LIMIT sqlc.arg(quantity)::integer FOR UPDATE SKIP LOCKED)
+  AND status = 'available'
+RETURNING row, col;
+
+-- name: ConfirmHeldSeats :many
+UPDATE seats
+SET status     = 'sold',
+    updated_at = NOW()
+WHERE order_id = sqlc.arg(order_id)::integer
+  AND status = 'held'
+RETURNING row, col;
+
+-- name: FindSeatsByCategoryId :many
+SELECT row, col, section, row_label, seat_label, x, y, status
+FROM seats
+WHERE category_id = $1
+ORDER BY row, col;
+
+-- name: HoldSeats :execresult
+UPDATE seats
+SET order_id   = sqlc.arg(order_id)::integer,
+    hold_id    = NULL,
+    held_until = NULL,
+    updated_at = NOW()
+WHERE category_id = sqlc.arg(category_id)
+  AND hold_id = sqlc.arg(hold_id)
+  AND status = 'held'
+  AND order_id IS NULL;
+
+-- name: PlaceSeatHold :execrows
+UPDATE seats
+SET status     = 'held',
+    hold_id    = sqlc.arg(hold_id),
+    held_until = sqlc.arg(held_until),
+    updated_at = NOW()
+WHERE category_id = sqlc.arg(category_id)
+  AND (row, col) IN (SELECT UNNEST(sqlc.arg(rows)::integer[]), UNNEST(sqlc.arg(cols)::integer[]))
+  AND status = 'available';
+
+-- name: ReleaseStaleSeatHolds :execrows
+UPDATE seats
+SET status     = 'available',
+    order_id   = NULL,
+    hold_id    = NULL,
+    held_until = NULL,
+    updated_at = NOW()
+WHERE status = 'held'
+  AND (order_id IN (SELECT id
+                    FROM orders
+                    WHERE status IN ('cancelled', 'refund_required'))
+    OR (order_id IS NULL AND held_until < sqlc.arg(now)));
+
+-- name: DeleteSeatsByEventId :exec
+DELETE
+FROM seats
+WHERE category_id IN (SELECT id
+                      FROM categories
+                      WHERE event_id = $1);
+
+-- name: InsertSeats :execrows
+INSERT INTO seats (category_id, row, col, section, row_label, seat_label, x, y)
+SELECT UNNEST(sqlc.arg(category_ids)::smallint[]),
+       UNNEST(sqlc.arg(rows)::integer[]),
+       UNNEST(sqlc.arg(cols)::integer[]),
+       UNNEST(sqlc.arg(sections)::varchar[]),
+       UNNEST(sqlc.arg(row_labels)::varchar[]),
+       UNNEST(sqlc.arg(seat_labels)::varchar[]),
+       UNNEST(sqlc.arg(xs)::real[]),
+       UNNEST(sqlc.arg(ys)::real[]);
\ No newline at end of file
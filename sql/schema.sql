@@ -1,40 +1,161 @@
 SET TIME ZONE 'Asia/Jakarta';
 
+CREATE TABLE IF NOT EXISTS venues
+(
+    id   SMALLINT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    city VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events
+(
+    id             SMALLINT PRIMARY KEY,
+    venue_id       SMALLINT     NOT NULL,
+    name           VARCHAR(100) NOT NULL,
+    timezone       VARCHAR(50)  NOT NULL,
+    starts_at      TIMESTAMPTZ  NOT NULL,
+    sale_starts_at TIMESTAMPTZ  NOT NULL,
+    sale_ends_at   TIMESTAMPTZ  NOT NULL,
+    order_prefix   VARCHAR(10)  NOT NULL,
+    brand_name     VARCHAR(100) NOT NULL,
+    support_email  VARCHAR(255) NOT NULL
+);
+
+CREATE TYPE seating_type AS ENUM ('reserved', 'general_admission');
 CREATE TABLE IF NOT EXISTS categories
 (
-    id       SMALLINT PRIMARY KEY,
-    name     VARCHAR(50) NOT NULL,
-    price    INT         NOT NULL,
-    quantity INT         NOT NULL,
-    max_row  INT         NOT NULL,
-    max_col  INT         NOT NULL
+    id                    SMALLINT PRIMARY KEY,
+    event_id              SMALLINT     NOT NULL,
+    name                  VARCHAR(50)  NOT NULL,
+    price                 INT          NOT NULL,
+    quantity              INT          NOT NULL,
+    capacity              INT          NOT NULL,
+    max_row               INT          NOT NULL,
+    max_col               INT          NOT NULL,
+    seating_type          seating_type NOT NULL DEFAULT 'reserved',
+    sale_starts_at        TIMESTAMPTZ,
+    sale_ends_at          TIMESTAMPTZ,
+    last_admission_number INT          NOT NULL DEFAULT 0
 );
+CREATE INDEX IF NOT EXISTS idx_categories_event_id ON categories (event_id);
 
-CREATE TABLE IF NOT EXISTS category_quantities
+CREATE TYPE seat_status AS ENUM ('available', 'held', 'sold');
+CREATE TABLE IF NOT EXISTS seats
 (
     id          INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-    category_id SMALLINT NOT NULL,
-    row         INT      NOT NULL,
-    col         INT      NOT NULL
+    category_id SMALLINT    NOT NULL,
+    row         INT         NOT NULL,
+    col         INT         NOT NULL,
+    section     VARCHAR(50) NOT NULL,
+    row_label   VARCHAR(10) NOT NULL,
+    seat_label  VARCHAR(10) NOT NULL,
+    x           REAL        NOT NULL,
+    y           REAL        NOT NULL,
+    status      seat_status NOT NULL DEFAULT 'available',
+    order_id    INT,
+    hold_id     VARCHAR(26),
+    held_until  TIMESTAMP,
+    updated_at  TIMESTAMP
 );
-CREATE INDEX IF NOT EXISTS idx_category_quantities_row ON category_quantities (row);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seats_category_row_col ON seats (category_id, row, col);
+CREATE INDEX IF NOT EXISTS idx_seats_available ON seats (category_id, row, col) WHERE status = 'available';
+CREATE INDEX IF NOT EXISTS idx_seats_order_id ON seats (order_id);
 
-CREATE TYPE order_status AS ENUM ('pending', 'completed', 'cancelled');
+CREATE TYPE order_status AS ENUM ('pending', 'completed', 'cancelled', 'refund_required');
 CREATE TABLE IF NOT EXISTS orders
 (
-    id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-    category_id  SMALLINT     NOT NULL,
-    external_id  VARCHAR(36)  NOT NULL,
-    name         VARCHAR(100) NOT NULL,
-    email        VARCHAR(255) NOT NULL,
-    status       order_status DEFAULT 'pending',
-    payment_code VARCHAR(50)  NOT NULL,
-    expired_at   TIMESTAMP    NOT NULL,
-    ticket_row   INT,
-    ticket_col   INT,
-    created_at   TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
-    updated_at   TIMESTAMP
-);
-CREATE INDEX IF NOT EXISTS idx_order_email ON orders (email);
+    id                INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_id          SMALLINT     NOT NULL,
+    category_id       SMALLINT     NOT NULL,
+    quantity          INT          NOT NULL DEFAULT 1,
+    external_id       VARCHAR(36)  NOT NULL,
+    name              VARCHAR(100) NOT NULL,
+    email             VARCHAR(255) NOT NULL,
+    status            order_status DEFAULT 'pending',
+    payment_code      VARCHAR(50)  NOT NULL,
+    payment_method    VARCHAR(20)  NOT NULL,
+    base_price        BIGINT       NOT NULL,
+    platform_fee      BIGINT       NOT NULL,
+    vat_amount        BIGINT       NOT NULL,
+    total_amount      BIGINT       NOT NULL,
+    cancel_token_hash VARCHAR(64)  NOT NULL,
+    expired_at        TIMESTAMP    NOT NULL,
+    ticket_row        INT,
+    ticket_col        INT,
+    created_at        TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_order_event_id_email ON orders (event_id, email);
 CREATE INDEX IF NOT EXISTS idx_order_external_id ON orders (external_id);
-CREATE INDEX IF NOT EXISTS idx_order_status_expired_at_pending ON orders (status, expired_at) WHERE status = 'pending';
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_order_status_expired_at_pending ON orders (status, expired_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS order_items
+(
+    id               INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id         INT NOT NULL,
+    ticket_row       INT,
+    ticket_col       INT,
+    admission_number INT,
+    created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items (order_id);
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id         BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subject    VARCHAR(100) NOT NULL,
+    payload    JSONB        NOT NULL,
+    attempts   INT          NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
+    sent_at    TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE sent_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS payment_exceptions
+(
+    id                      INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id                INT          NOT NULL,
+    external_id             VARCHAR(36)  NOT NULL,
+    provider_transaction_id VARCHAR(100) NOT NULL,
+    reason                  VARCHAR(50)  NOT NULL,
+    expected_amount         BIGINT       NOT NULL,
+    paid_amount             BIGINT       NOT NULL,
+    currency                VARCHAR(3)   NOT NULL,
+    payload                 JSONB        NOT NULL,
+    created_at              TIMESTAMP    DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_exceptions_provider_transaction_id ON payment_exceptions (provider_transaction_id);
+
+CREATE TABLE IF NOT EXISTS late_payments
+(
+    id                      INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id                INT          NOT NULL,
+    external_id             VARCHAR(36)  NOT NULL,
+    provider_transaction_id VARCHAR(100) NOT NULL,
+    amount                  BIGINT       NOT NULL,
+    outcome                 VARCHAR(20)  NOT NULL,
+    refund_id               VARCHAR(100),
+    refunded_at             TIMESTAMP,
+    created_at              TIMESTAMP    DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_late_payments_provider_transaction_id ON late_payments (provider_transaction_id);
+
+CREATE TABLE IF NOT EXISTS payment_callbacks
+(
+    id                      BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    provider_transaction_id VARCHAR(100) NOT NULL,
+    external_id             VARCHAR(36)  NOT NULL,
+    callback_id             VARCHAR(100) NOT NULL,
+    status                  VARCHAR(20)  NOT NULL,
+    payload                 TEXT         NOT NULL,
+    headers                 JSONB        NOT NULL,
+    outcome                 VARCHAR(20)  NOT NULL,
+    received_at             TIMESTAMP    NOT NULL,
+    updated_at              TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_callbacks_callback_id ON payment_callbacks (callback_id);
+CREATE INDEX IF NOT EXISTS idx_payment_callbacks_provider_transaction_id ON payment_callbacks (provider_transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_callbacks_provider_transaction_id_live ON payment_callbacks (provider_transaction_id) WHERE outcome IN ('received', 'queued');
+CREATE INDEX IF NOT EXISTS idx_payment_callbacks_external_id ON payment_callbacks (external_id);
\ No newline at end of file
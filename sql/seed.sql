@@ -1,17 +1,33 @@
-INSERT INTO categories (id, name, price, quantity, max_row, max_col)
-VALUES (1, 'Ultimate Experience', 11000000, 500, 50, 10),
-       (2, 'My Universe', 7500000, 1000, 50, 20),
-       (3, 'CAT 1', 5800000, 3000, 100, 30),
-       (4, 'CAT 2', 5200000, 4000, 100, 40),
-       (5, 'CAT 3', 4600000, 5000, 100, 50),
-       (6, 'CAT 4', 3800000, 6000, 100, 60),
-       (7, 'CAT 5', 3000000, 7000, 100, 70),
-       (8, 'CAT 6', 1500000, 10000, 100, 100),
-       (9, 'Festival', 2500000, 15000, 150, 100);
+INSERT INTO venues (id, name, city)
+VALUES (1, 'Gelora Bung Karno Stadium', 'Jakarta');
 
-INSERT INTO category_quantities (category_id, row, col)
+INSERT INTO events (id, venue_id, name, timezone, starts_at, sale_starts_at, sale_ends_at, order_prefix, brand_name,
+                    support_email)
+VALUES (1, 1, 'Coldplay Music of the Spheres World Tour', 'Asia/Jakarta', '2027-11-15 19:00:00+07',
+        '2026-01-01 10:00:00+07', '2027-11-15 17:00:00+07', 'CLDPLY', 'Concert Ticket Team',
+        'support@concert-ticket.com');
+
+INSERT INTO categories (id, event_id, name, price, quantity, capacity, max_row, max_col, seating_type)
+VALUES (1, 1, 'Ultimate Experience', 11000000, 500, 500, 50, 10, 'reserved'),
+       (2, 1, 'My Universe', 7500000, 1000, 1000, 50, 20, 'reserved'),
+       (3, 1, 'CAT 1', 5800000, 3000, 3000, 100, 30, 'reserved'),
+       (4, 1, 'CAT 2', 5200000, 4000, 4000, 100, 40, 'reserved'),
+       (5, 1, 'CAT 3', 4600000, 5000, 5000, 100, 50, 'reserved'),
+       (6, 1, 'CAT 4', 3800000, 6000, 6000, 100, 60, 'reserved'),
+       (7, 1, 'CAT 5', 3000000, 7000, 7000, 100, 70, 'reserved'),
+       (8, 1, 'CAT 6', 1500000, 10000, 10000, 100, 100, 'reserved'),
+       (9, 1, 'Festival', 2500000, 15000, 15000, 150, 100, 'general_admission');
+
+INSERT INTO seats (category_id, row, col, section, row_label, seat_label, x, y)
 SELECT c.id          AS category_id,
        row_num       AS row,
-       c.max_col + 1 AS col
+       col_num       AS col,
+       c.name        AS section,
+       row_num::text AS row_label,
+       col_num::text AS seat_label,
+       col_num       AS x,
+       row_num       AS y
 FROM categories c,
-     GENERATE_SERIES(1, c.max_row + 1) AS row_num;
\ No newline at end of file
+     GENERATE_SERIES(1, c.max_row) AS row_num,
+     GENERATE_SERIES(1, c.max_col) AS col_num
+WHERE c.seating_type = 'reserved';
\ No newline at end of file